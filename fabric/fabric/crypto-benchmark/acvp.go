@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto-benchmark/acvp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runACVP implements the acvp command and returns the process exit code
+func runACVP(args []string) int {
+	fs := flag.NewFlagSet("acvp", flag.ExitOnError)
+	jsonOut := fs.String("json", "", "Also write the report as JSON to this file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: benchmark acvp [flags] <vector-directory>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	report, err := acvp.Run(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("ACVP validation failed: %v", err)
+	}
+	acvp.PrintReport(report)
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+		if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		fmt.Printf("Report saved to: %s\n", *jsonOut)
+	}
+
+	if err := checkACVPReport(report); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// validateACVP checks the implementations against the vectors in dir
+func validateACVP(dir string) error {
+	report, err := acvp.Run(dir)
+	if err != nil {
+		return err
+	}
+	acvp.PrintReport(report)
+	return checkACVPReport(report)
+}
+
+// checkACVPReport fails if any vector failed or if no vector could be checked,
+// since a directory of only skipped vectors proves nothing
+func checkACVPReport(report acvp.Report) error {
+	passed, failed, _ := report.Totals()
+	if failed > 0 {
+		return fmt.Errorf("%d ACVP test cases failed", failed)
+	}
+	if passed == 0 {
+		return fmt.Errorf("no ACVP test cases could be checked")
+	}
+	return nil
+}
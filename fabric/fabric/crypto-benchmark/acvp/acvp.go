@@ -0,0 +1,430 @@
+// Package acvp checks the signature implementations against NIST ACVP test
+// vectors, as published by the ACVP-Server project in its gen-val/json-files
+// directory. Each vector set is a directory holding prompt.json and
+// expectedResults.json, or a single internalProjection.json, optionally gzipped.
+package acvp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Vector set files, in order of preference
+const (
+	fileInternalProjection = "internalProjection.json"
+	filePrompt             = "prompt.json"
+	fileExpectedResults    = "expectedResults.json"
+)
+
+// HexBytes is a byte string encoded as hex in ACVP JSON
+type HexBytes []byte
+
+// UnmarshalJSON decodes a hex string
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// VectorSet is one ACVP vector set with prompts and expected results merged
+type VectorSet struct {
+	Path       string
+	VsID       int               `json:"vsId"`
+	Algorithm  string            `json:"algorithm"`
+	Mode       string            `json:"mode"`
+	Revision   string            `json:"revision"`
+	TestGroups []json.RawMessage `json:"testGroups"`
+}
+
+// Result summarises the checks of one vector set
+type Result struct {
+	Path       string   `json:"path"`
+	Algorithm  string   `json:"algorithm"`
+	Mode       string   `json:"mode"`
+	Revision   string   `json:"revision"`
+	Passed     int      `json:"passed"`
+	Failed     int      `json:"failed"`
+	Skipped    int      `json:"skipped"`
+	Deviated   int      `json:"deviated"`
+	Failures   []string `json:"failures,omitempty"`
+	Deviations []string `json:"deviations,omitempty"`
+	Notes      []string `json:"notes,omitempty"`
+}
+
+// pass records a passing test case
+func (r *Result) pass() {
+	r.Passed++
+}
+
+// fail records a failing test case
+func (r *Result) fail(tcID int, format string, args ...interface{}) {
+	r.Failed++
+	r.Failures = append(r.Failures, fmt.Sprintf("tcId %d: %s", tcID, fmt.Sprintf(format, args...)))
+}
+
+// deviate records a test case that the MSP decides differently from the
+// vector by design, because it is stricter than the standard, as Fabric's
+// low-S rule is. Deviations are not failures.
+func (r *Result) deviate(tcID int, format string, args ...interface{}) {
+	r.Deviated++
+	r.Deviations = append(r.Deviations, fmt.Sprintf("tcId %d: %s", tcID, fmt.Sprintf(format, args...)))
+}
+
+// skip records test cases that cannot be checked and why
+func (r *Result) skip(count int, format string, args ...interface{}) {
+	r.Skipped += count
+	note := fmt.Sprintf(format, args...)
+	for _, existing := range r.Notes {
+		if existing == note {
+			return
+		}
+	}
+	r.Notes = append(r.Notes, note)
+}
+
+// Report is the outcome of checking every vector set in a directory
+type Report struct {
+	Directory string   `json:"directory"`
+	Results   []Result `json:"results"`
+}
+
+// Totals returns the passed, failed and skipped test cases across all vector sets
+func (r Report) Totals() (passed, failed, skipped int) {
+	for _, result := range r.Results {
+		passed += result.Passed
+		failed += result.Failed
+		skipped += result.Skipped
+	}
+	return passed, failed, skipped
+}
+
+// checker validates the test groups of one algorithm and mode
+type checker func(vs VectorSet, result *Result) error
+
+// checkers maps "algorithm/mode" to its checker
+var checkers = map[string]checker{
+	"ML-DSA/keyGen": checkMLDSAKeyGen,
+	"ML-DSA/sigGen": checkMLDSASigGen,
+	"ML-DSA/sigVer": checkMLDSASigVer,
+	"ECDSA/keyVer":  checkECDSAKeyVer,
+	"ECDSA/sigVer":  checkECDSASigVer,
+}
+
+// unsupported explains why vector sets of known algorithms cannot be checked
+var unsupported = map[string]string{
+	"ECDSA/keyGen":   "ECDSA key generation is randomised; only the ACVP server can check its output",
+	"ECDSA/sigGen":   "ECDSA signing is randomised; only the ACVP server can check its output",
+	"SLH-DSA/keyGen": "SLH-DSA is not implemented by this benchmark",
+	"SLH-DSA/sigGen": "SLH-DSA is not implemented by this benchmark",
+	"SLH-DSA/sigVer": "SLH-DSA is not implemented by this benchmark",
+}
+
+// Run loads every vector set under dir and checks it against the implementations
+func Run(dir string) (Report, error) {
+	report := Report{Directory: dir}
+
+	sets, err := LoadDir(dir)
+	if err != nil {
+		return report, err
+	}
+	if len(sets) == 0 {
+		return report, fmt.Errorf("no ACVP vector sets found in %s", dir)
+	}
+
+	for _, vs := range sets {
+		result := Result{Path: vs.Path, Algorithm: vs.Algorithm, Mode: vs.Mode, Revision: vs.Revision}
+		key := vs.Algorithm + "/" + vs.Mode
+
+		if check, ok := checkers[key]; ok {
+			if err := check(vs, &result); err != nil {
+				return report, fmt.Errorf("%s: %v", vs.Path, err)
+			}
+		} else {
+			reason, known := unsupported[key]
+			if !known {
+				reason = fmt.Sprintf("no checker for %s %s", vs.Algorithm, vs.Mode)
+			}
+			result.skip(countTests(vs), "%s", reason)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// LoadDir finds and loads every vector set below dir
+func LoadDir(dir string) ([]VectorSet, error) {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if findFile(path, fileInternalProjection) != "" || findFile(path, filePrompt) != "" {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan vector directory: %v", err)
+	}
+	sort.Strings(dirs)
+
+	var sets []VectorSet
+	for _, d := range dirs {
+		vs, err := LoadVectorSet(d)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, vs)
+	}
+	return sets, nil
+}
+
+// LoadVectorSet loads one vector set directory, merging expected results into
+// the prompt test cases by tcId when there is no internal projection
+func LoadVectorSet(dir string) (VectorSet, error) {
+	if path := findFile(dir, fileInternalProjection); path != "" {
+		vs, err := readVectorSet(path)
+		if err != nil {
+			return vs, err
+		}
+		vs.Path = dir
+		return vs, nil
+	}
+
+	promptPath := findFile(dir, filePrompt)
+	expectedPath := findFile(dir, fileExpectedResults)
+	if promptPath == "" || expectedPath == "" {
+		return VectorSet{}, fmt.Errorf("%s: expected %s and %s", dir, filePrompt, fileExpectedResults)
+	}
+
+	vs, err := readVectorSet(promptPath)
+	if err != nil {
+		return vs, err
+	}
+	expected, err := readVectorSet(expectedPath)
+	if err != nil {
+		return vs, err
+	}
+
+	merged, err := mergeGroups(vs.TestGroups, expected.TestGroups)
+	if err != nil {
+		return vs, fmt.Errorf("%s: %v", dir, err)
+	}
+	vs.TestGroups = merged
+	vs.Path = dir
+	return vs, nil
+}
+
+// findFile returns the path of name or name.gz in dir, or "" if neither exists
+func findFile(dir, name string) string {
+	for _, candidate := range []string{name, name + ".gz"} {
+		path := filepath.Join(dir, candidate)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// readVectorSet reads a possibly gzipped ACVP JSON file. Files downloaded from
+// an ACVP server wrap the vector set in an array after a version object.
+func readVectorSet(path string) (VectorSet, error) {
+	var vs VectorSet
+
+	data, err := readFile(path)
+	if err != nil {
+		return vs, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return vs, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		for _, item := range items {
+			var probe struct {
+				TestGroups json.RawMessage `json:"testGroups"`
+			}
+			if json.Unmarshal(item, &probe) == nil && probe.TestGroups != nil {
+				data = item
+				break
+			}
+		}
+	}
+
+	if err := json.Unmarshal(data, &vs); err != nil {
+		return vs, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return vs, nil
+}
+
+// readFile reads a file, decompressing it if its name ends in .gz
+func readFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vector file: %v", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// mergeGroups adds the fields of the expected results to the prompt groups and
+// test cases with the same tgId and tcId
+func mergeGroups(prompt, expected []json.RawMessage) ([]json.RawMessage, error) {
+	expectedGroups := make(map[int]map[string]json.RawMessage)
+	expectedTests := make(map[int]map[string]json.RawMessage)
+
+	for _, raw := range expected {
+		group, tests, err := splitGroup(raw)
+		if err != nil {
+			return nil, err
+		}
+		expectedGroups[intField(group, "tgId")] = group
+		for _, test := range tests {
+			expectedTests[intField(test, "tcId")] = test
+		}
+	}
+
+	merged := make([]json.RawMessage, 0, len(prompt))
+	for _, raw := range prompt {
+		group, tests, err := splitGroup(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range expectedGroups[intField(group, "tgId")] {
+			if _, exists := group[k]; !exists {
+				group[k] = v
+			}
+		}
+
+		rawTests := make([]json.RawMessage, len(tests))
+		for i, test := range tests {
+			tcID := intField(test, "tcId")
+			result, ok := expectedTests[tcID]
+			if !ok {
+				return nil, fmt.Errorf("no expected result for tcId %d", tcID)
+			}
+			for k, v := range result {
+				if _, exists := test[k]; !exists {
+					test[k] = v
+				}
+			}
+			if rawTests[i], err = json.Marshal(test); err != nil {
+				return nil, err
+			}
+		}
+
+		if group["tests"], err = json.Marshal(rawTests); err != nil {
+			return nil, err
+		}
+		out, err := json.Marshal(group)
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, out)
+	}
+	return merged, nil
+}
+
+// splitGroup decodes a test group into its fields and its test cases
+func splitGroup(raw json.RawMessage) (map[string]json.RawMessage, []map[string]json.RawMessage, error) {
+	var group map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &group); err != nil {
+		return nil, nil, fmt.Errorf("invalid test group: %v", err)
+	}
+
+	var tests []map[string]json.RawMessage
+	if rawTests, ok := group["tests"]; ok {
+		if err := json.Unmarshal(rawTests, &tests); err != nil {
+			return nil, nil, fmt.Errorf("invalid tests in group: %v", err)
+		}
+	}
+	return group, tests, nil
+}
+
+// intField decodes an integer field, returning 0 if it is missing or invalid
+func intField(fields map[string]json.RawMessage, name string) int {
+	var v int
+	json.Unmarshal(fields[name], &v)
+	return v
+}
+
+// countTests returns the number of test cases in a vector set
+func countTests(vs VectorSet) int {
+	count := 0
+	for _, raw := range vs.TestGroups {
+		var group struct {
+			Tests []json.RawMessage `json:"tests"`
+		}
+		if json.Unmarshal(raw, &group) == nil {
+			count += len(group.Tests)
+		}
+	}
+	return count
+}
+
+// PrintReport prints one line per vector set with failures and skip reasons
+func PrintReport(report Report) {
+	fmt.Printf("ACVP vectors from %s:\n", report.Directory)
+	for _, r := range report.Results {
+		status := "✓"
+		if r.Failed > 0 {
+			status = "✗"
+		} else if r.Passed == 0 {
+			status = "-"
+		}
+		fmt.Printf("  %s %s %s (%s): %d passed, %d failed, %d skipped, %d deviated\n",
+			status, r.Algorithm, r.Mode, r.Revision, r.Passed, r.Failed, r.Skipped, r.Deviated)
+		for _, failure := range r.Failures {
+			fmt.Printf("      FAIL %s\n", failure)
+		}
+		for _, deviation := range r.Deviations {
+			fmt.Printf("      DEVIATION %s\n", deviation)
+		}
+		for _, note := range r.Notes {
+			fmt.Printf("      skipped: %s\n", note)
+		}
+	}
+
+	passed, failed, skipped := report.Totals()
+	deviated := 0
+	for _, r := range report.Results {
+		deviated += r.Deviated
+	}
+	fmt.Printf("Total: %d passed, %d failed, %d skipped, %d deviated\n", passed, failed, skipped, deviated)
+}
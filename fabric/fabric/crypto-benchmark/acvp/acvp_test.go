@@ -0,0 +1,83 @@
+package acvp
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVendoredVectors checks the MSP against the vector sets in testdata; see
+// testdata/generate.go for where each set comes from
+func TestVendoredVectors(t *testing.T) {
+	report, err := Run("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]struct{ passed, skipped, deviated int }{
+		"ML-DSA-keyGen-FIPS204": {passed: 3},
+		// The ACVP-Server signing sets only test the internal interface
+		"ML-DSA-sigGen-FIPS204":            {skipped: 3},
+		"ML-DSA-sigVer-FIPS204":            {skipped: 6},
+		"generated/ML-DSA-sigGen-external": {passed: 3},
+		"generated/ML-DSA-sigVer-external": {passed: 7},
+		"generated/ECDSA-keyVer":           {passed: 3, skipped: 1},
+		// The high-S signature is valid under FIPS 186-5 but not for Fabric
+		"generated/ECDSA-sigVer": {passed: 2, skipped: 2, deviated: 1},
+	}
+	if len(report.Results) != len(expected) {
+		t.Fatalf("checked %d vector sets, expected %d", len(report.Results), len(expected))
+	}
+	for _, result := range report.Results {
+		name, err := filepath.Rel("testdata", result.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name = filepath.ToSlash(name)
+		want, ok := expected[name]
+		if !ok {
+			t.Errorf("unexpected vector set %s", name)
+			continue
+		}
+		if result.Failed != 0 {
+			t.Errorf("%s: %d failures: %s", name, result.Failed, strings.Join(result.Failures, "; "))
+		}
+		if result.Passed != want.passed || result.Skipped != want.skipped || result.Deviated != want.deviated {
+			t.Errorf("%s: %d passed, %d skipped, %d deviated, expected %d, %d, %d", name,
+				result.Passed, result.Skipped, result.Deviated, want.passed, want.skipped, want.deviated)
+		}
+	}
+}
+
+// TestMismatchFails checks that a vector the MSP disagrees with is reported
+// as a failure, so the passes above are not vacuous
+func TestMismatchFails(t *testing.T) {
+	vs, err := LoadVectorSet(filepath.Join("testdata", "ML-DSA-keyGen-FIPS204"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var group map[string]interface{}
+	if err := json.Unmarshal(vs.TestGroups[0], &group); err != nil {
+		t.Fatal(err)
+	}
+	test := group["tests"].([]interface{})[0].(map[string]interface{})
+	pk := []byte(test["pk"].(string))
+	if pk[0] == '0' {
+		pk[0] = '1'
+	} else {
+		pk[0] = '0'
+	}
+	test["pk"] = string(pk)
+	if vs.TestGroups[0], err = json.Marshal(group); err != nil {
+		t.Fatal(err)
+	}
+
+	var result Result
+	if err := checkMLDSAKeyGen(vs, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 1 || result.Passed != 2 {
+		t.Errorf("%d passed, %d failed, expected 2 and 1", result.Passed, result.Failed)
+	}
+}
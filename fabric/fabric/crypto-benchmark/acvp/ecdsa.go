@@ -0,0 +1,166 @@
+package acvp
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"crypto-benchmark/msp"
+)
+
+// ecdsaGroup holds the test group fields of the ECDSA modes
+type ecdsaGroup struct {
+	TgID        int    `json:"tgId"`
+	Curve       string `json:"curve"`
+	HashAlg     string `json:"hashAlg"`
+	Conformance string `json:"conformance"`
+	Tests       []struct {
+		TcID       int      `json:"tcId"`
+		Message    HexBytes `json:"message"`
+		Qx         HexBytes `json:"qx"`
+		Qy         HexBytes `json:"qy"`
+		R          HexBytes `json:"r"`
+		S          HexBytes `json:"s"`
+		TestPassed *bool    `json:"testPassed"`
+	} `json:"tests"`
+}
+
+// The MSP's only ECDSA configuration: P-256 keys signing SHA-256 hashes
+const (
+	ecdsaCurve = "P-256"
+	ecdsaHash  = "SHA2-256"
+)
+
+// p256PKIXPrefix is the PKIX encoding of a P-256 public key up to the
+// uncompressed point
+var p256PKIXPrefix = []byte{
+	0x30, 0x59, 0x30, 0x13, 0x06, 0x07, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x02, 0x01,
+	0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07, 0x03, 0x42, 0x00,
+}
+
+// decodeECDSAGroups decodes every group of an ECDSA vector set
+func decodeECDSAGroups(vs VectorSet) ([]ecdsaGroup, error) {
+	groups := make([]ecdsaGroup, len(vs.TestGroups))
+	for i, raw := range vs.TestGroups {
+		if err := json.Unmarshal(raw, &groups[i]); err != nil {
+			return nil, fmt.Errorf("invalid ECDSA test group: %v", err)
+		}
+	}
+	return groups, nil
+}
+
+// importECDSAPublicKey imports the point (qx, qy) into a verify-only MSP
+// through its PKIX decoder. Coordinates wider than the field cannot be
+// encoded and are rejected as the decoder would reject them.
+func importECDSAPublicKey(qx, qy []byte) (*msp.EnhancedMSP, error) {
+	x, y := new(big.Int).SetBytes(qx), new(big.Int).SetBytes(qy)
+	if x.BitLen() > 256 || y.BitLen() > 256 {
+		return nil, errors.New("coordinate wider than the P-256 field")
+	}
+	encoded := make([]byte, len(p256PKIXPrefix)+65)
+	copy(encoded, p256PKIXPrefix)
+	point := encoded[len(p256PKIXPrefix):]
+	point[0] = 0x04
+	x.FillBytes(point[1:33])
+	y.FillBytes(point[33:])
+	return msp.NewEnhancedMSPFromPublicKey(msp.ECDSA, encoded)
+}
+
+// checkECDSAKeyVer checks that the MSP imports public keys exactly when they
+// are valid
+func checkECDSAKeyVer(vs VectorSet, result *Result) error {
+	groups, err := decodeECDSAGroups(vs)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		if group.Curve != ecdsaCurve {
+			result.skip(len(group.Tests), "the MSP only imports %s keys", ecdsaCurve)
+			continue
+		}
+
+		for _, test := range group.Tests {
+			if test.TestPassed == nil {
+				result.skip(1, "test case has no expected verdict")
+				continue
+			}
+
+			_, err := importECDSAPublicKey(test.Qx, test.Qy)
+			if valid := err == nil; valid != *test.TestPassed {
+				result.fail(test.TcID, "key import returned %v, expected %v", err, *test.TestPassed)
+				continue
+			}
+			result.pass()
+		}
+	}
+	return nil
+}
+
+// checkECDSASigVer verifies the vector signatures with the MSP and compares
+// the verdict. The MSP rejects high-S signatures that FIPS 186-5 accepts; such
+// a case is a deviation rather than a failure when the low-S form of the same
+// signature verifies.
+func checkECDSASigVer(vs VectorSet, result *Result) error {
+	groups, err := decodeECDSAGroups(vs)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		if group.Curve != ecdsaCurve || group.HashAlg != ecdsaHash {
+			result.skip(len(group.Tests), "the MSP only verifies %s signatures of %s hashes", ecdsaCurve, ecdsaHash)
+			continue
+		}
+		if group.Conformance != "" {
+			result.skip(len(group.Tests), "%s randomized hashing is not supported", group.Conformance)
+			continue
+		}
+
+		for _, test := range group.Tests {
+			if test.TestPassed == nil {
+				result.skip(1, "test case has no expected verdict")
+				continue
+			}
+
+			valid, highS := false, false
+			if verifier, err := importECDSAPublicKey(test.Qx, test.Qy); err == nil {
+				r, s := new(big.Int).SetBytes(test.R), new(big.Int).SetBytes(test.S)
+				valid, highS = verifyECDSA(verifier, test.Message, r, s)
+			}
+
+			switch {
+			case valid == *test.TestPassed:
+				result.pass()
+			case highS:
+				result.deviate(test.TcID, "high-S signature is valid under FIPS 186-5 but rejected by the low-S rule; its low-S form verifies")
+			default:
+				result.fail(test.TcID, "verification returned %v, expected %v", valid, *test.TestPassed)
+			}
+		}
+	}
+	return nil
+}
+
+// verifyECDSA verifies (r, s) in DER with the MSP. When the MSP rejects it as
+// high-S, highS reports whether (r, n-s) verifies instead.
+func verifyECDSA(verifier *msp.EnhancedMSP, message []byte, r, s *big.Int) (valid, highS bool) {
+	signature, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil {
+		return false, false
+	}
+	valid, err = verifier.Verify(message, signature)
+	n := elliptic.P256().Params().N
+	if valid || !errors.Is(err, msp.ErrMalformedSignature) || s.Cmp(n) >= 0 || s.Cmp(new(big.Int).Rsh(n, 1)) <= 0 {
+		return valid, false
+	}
+	lowS, err := asn1.Marshal(struct{ R, S *big.Int }{r, new(big.Int).Sub(n, s)})
+	if err != nil {
+		return false, false
+	}
+	highS, _ = verifier.Verify(message, lowS)
+	return false, highS
+}
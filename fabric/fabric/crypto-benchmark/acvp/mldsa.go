@@ -0,0 +1,201 @@
+package acvp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"crypto-benchmark/msp"
+)
+
+// mldsaGroup holds the test group fields shared by the ML-DSA modes. Older
+// revisions omit signatureInterface and only test the internal functions.
+type mldsaGroup struct {
+	TgID               int      `json:"tgId"`
+	TestType           string   `json:"testType"`
+	ParameterSet       string   `json:"parameterSet"`
+	Deterministic      bool     `json:"deterministic"`
+	SignatureInterface string   `json:"signatureInterface"`
+	PreHash            string   `json:"preHash"`
+	ExternalMu         bool     `json:"externalMu"`
+	Pk                 HexBytes `json:"pk"`
+}
+
+// mldsaTest holds the test case fields of every ML-DSA mode
+type mldsaTest struct {
+	TcID       int      `json:"tcId"`
+	Seed       HexBytes `json:"seed"`
+	Pk         HexBytes `json:"pk"`
+	Sk         HexBytes `json:"sk"`
+	Message    HexBytes `json:"message"`
+	Context    HexBytes `json:"context"`
+	Signature  HexBytes `json:"signature"`
+	TestPassed *bool    `json:"testPassed"`
+}
+
+// mldsaLevel returns the security level of an ACVP parameter set name
+func mldsaLevel(parameterSet string) (int, error) {
+	algorithm, err := msp.ParseSignatureAlgorithm(parameterSet)
+	if err != nil {
+		return 0, err
+	}
+	switch algorithm {
+	case msp.MLDSA44:
+		return 44, nil
+	case msp.MLDSA65:
+		return 65, nil
+	case msp.MLDSA87:
+		return 87, nil
+	default:
+		return 0, fmt.Errorf("not an ML-DSA parameter set: %s", parameterSet)
+	}
+}
+
+// decodeMLDSAGroup decodes a group with its tests and looks up its security level
+func decodeMLDSAGroup(raw json.RawMessage) (mldsaGroup, []mldsaTest, int, error) {
+	var group struct {
+		mldsaGroup
+		Tests []mldsaTest `json:"tests"`
+	}
+	if err := json.Unmarshal(raw, &group); err != nil {
+		return group.mldsaGroup, nil, 0, fmt.Errorf("invalid ML-DSA test group: %v", err)
+	}
+
+	level, err := mldsaLevel(group.ParameterSet)
+	if err != nil {
+		return group.mldsaGroup, nil, 0, fmt.Errorf("tgId %d: %v", group.TgID, err)
+	}
+	return group.mldsaGroup, group.Tests, level, nil
+}
+
+// unsupportedInterface explains why a signing or verification group cannot be
+// checked through the FIPS 204 interface of msp.WorkingMLDSAKeyPair, or
+// returns "" if it can
+func (g mldsaGroup) unsupportedInterface() string {
+	switch {
+	case g.SignatureInterface != "external":
+		return "ML-DSA.Sign_internal/Verify_internal are not exposed by the MSP"
+	case g.PreHash != "" && g.PreHash != "pure":
+		return "HashML-DSA pre-hash signing is not supported by the MSP"
+	case g.ExternalMu:
+		return "external mu is not supported by the MSP"
+	default:
+		return ""
+	}
+}
+
+// checkMLDSAKeyGen derives key pairs from the vector seeds as seeded key
+// generation does
+func checkMLDSAKeyGen(vs VectorSet, result *Result) error {
+	for _, raw := range vs.TestGroups {
+		_, tests, level, err := decodeMLDSAGroup(raw)
+		if err != nil {
+			return err
+		}
+
+		for _, test := range tests {
+			keyPair, err := msp.NewWorkingMLDSAKeyPairFromSeed(level, test.Seed)
+			if err != nil {
+				result.fail(test.TcID, "seed rejected: %v", err)
+				continue
+			}
+
+			switch {
+			case !bytes.Equal(keyPair.GetPublicKeyBytes(), test.Pk):
+				result.fail(test.TcID, "public key does not match")
+			case !bytes.Equal(keyPair.GetPrivateKeyBytes(), test.Sk):
+				result.fail(test.TcID, "private key does not match")
+			default:
+				result.pass()
+			}
+		}
+	}
+	return nil
+}
+
+// checkMLDSASigGen imports the vector keys as the MSP does and signs the
+// vector messages. Only deterministic signing is reproducible, because hedged
+// signing draws its own rnd.
+func checkMLDSASigGen(vs VectorSet, result *Result) error {
+	for _, raw := range vs.TestGroups {
+		group, tests, level, err := decodeMLDSAGroup(raw)
+		if err != nil {
+			return err
+		}
+
+		if reason := group.unsupportedInterface(); reason != "" {
+			result.skip(len(tests), "%s", reason)
+			continue
+		}
+		if !group.Deterministic {
+			result.skip(len(tests), "hedged signing cannot take the vector's rnd")
+			continue
+		}
+
+		for _, test := range tests {
+			if len(test.Sk) == 0 {
+				result.skip(1, "test case has no private key")
+				continue
+			}
+			keyPair, err := msp.NewWorkingMLDSAKeyPairFromPrivateKey(level, test.Sk)
+			if err != nil {
+				result.fail(test.TcID, "private key rejected: %v", err)
+				continue
+			}
+
+			signature, err := keyPair.SignWithContext(test.Message, test.Context)
+			keyPair.Destroy()
+			switch {
+			case err != nil:
+				result.fail(test.TcID, "signing failed: %v", err)
+			case !bytes.Equal(signature, test.Signature):
+				result.fail(test.TcID, "signature does not match")
+			default:
+				result.pass()
+			}
+		}
+	}
+	return nil
+}
+
+// checkMLDSASigVer imports the vector public keys as the MSP does, verifies
+// the vector signatures and compares the verdict
+func checkMLDSASigVer(vs VectorSet, result *Result) error {
+	for _, raw := range vs.TestGroups {
+		group, tests, level, err := decodeMLDSAGroup(raw)
+		if err != nil {
+			return err
+		}
+
+		if reason := group.unsupportedInterface(); reason != "" {
+			result.skip(len(tests), "%s", reason)
+			continue
+		}
+
+		for _, test := range tests {
+			if test.TestPassed == nil {
+				result.skip(1, "test case has no expected verdict")
+				continue
+			}
+
+			// Newer revisions carry the public key per test case
+			pkBytes := test.Pk
+			if len(pkBytes) == 0 {
+				pkBytes = group.Pk
+			}
+			keyPair, err := msp.NewWorkingMLDSAKeyPairFromPublicKey(level, pkBytes)
+			if err != nil {
+				result.fail(test.TcID, "public key rejected: %v", err)
+				continue
+			}
+
+			valid := keyPair.VerifyWithContext(test.Message, test.Signature, test.Context)
+			if valid != *test.TestPassed {
+				result.fail(test.TcID, "verification returned %v, expected %v", valid, *test.TestPassed)
+				continue
+			}
+			result.pass()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+//go:build ignore
+
+// Generate writes the vector sets under acvp/testdata. Run it from the module
+// root with
+//
+//	go run ./acvp/testdata/generate.go
+//
+// The ML-DSA-*-FIPS204 directories are subsets of the ACVP-Server vector sets
+// that circl vendors in sign/mldsa/testdata, kept as published apart from the
+// dropped test cases. Those sigGen and sigVer sets only test the internal
+// interface, so the external interface the MSP signs with is covered by the
+// sets under generated, which are signed and checked with the standard
+// library's crypto/mldsa and crypto/ecdsa rather than with circl. Rerunning
+// the generator draws new ECDSA keys; everything else is reproducible.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/mldsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+)
+
+const outDir = "acvp/testdata"
+
+func main() {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/cloudflare/circl").Output()
+	if err != nil {
+		log.Fatalf("failed to locate circl: %v", err)
+	}
+	circl := filepath.Join(strings.TrimSpace(string(out)), "sign", "mldsa", "testdata")
+
+	// One key pair per parameter set, only deterministic signing, and one
+	// valid and one invalid signature per parameter set
+	subset(circl, "ML-DSA-keyGen-FIPS204", func(group, test map[string]any, kept int) bool {
+		return kept < 1
+	})
+	subset(circl, "ML-DSA-sigGen-FIPS204", func(group, test map[string]any, kept int) bool {
+		return group["deterministic"] == true && kept < 1
+	})
+	verdicts := map[float64]map[bool]bool{}
+	subset(circl, "ML-DSA-sigVer-FIPS204", func(group, test map[string]any, kept int) bool {
+		tgID, passed := group["tgId"].(float64), test["testPassed"].(bool)
+		if verdicts[tgID] == nil {
+			verdicts[tgID] = map[bool]bool{}
+		}
+		if verdicts[tgID][passed] {
+			return false
+		}
+		verdicts[tgID][passed] = true
+		return true
+	})
+
+	mldsaSigGen, mldsaSigVer := externalMLDSA()
+	write(filepath.Join(outDir, "generated", "ML-DSA-sigGen-external", "internalProjection.json"), mldsaSigGen)
+	write(filepath.Join(outDir, "generated", "ML-DSA-sigVer-external", "internalProjection.json"), mldsaSigVer)
+	write(filepath.Join(outDir, "generated", "ECDSA-keyVer", "internalProjection.json"), ecdsaKeyVer())
+	write(filepath.Join(outDir, "generated", "ECDSA-sigVer", "internalProjection.json"), ecdsaSigVer())
+}
+
+// subset copies the tests of an ACVP-Server vector set that keep accepts, given
+// how many of the group's tests it has kept so far, with their expected results
+func subset(circl, name string, keep func(group, test map[string]any, kept int) bool) {
+	prompt := read(filepath.Join(circl, name, "prompt.json.gz"))
+	expected := read(filepath.Join(circl, name, "expectedResults.json.gz"))
+
+	results := map[float64]map[string]any{}
+	for _, group := range expected["testGroups"].([]any) {
+		for _, test := range group.(map[string]any)["tests"].([]any) {
+			test := test.(map[string]any)
+			results[test["tcId"].(float64)] = test
+		}
+	}
+
+	kept := map[float64]bool{}
+	var promptGroups []any
+	for _, group := range prompt["testGroups"].([]any) {
+		group := group.(map[string]any)
+		var tests []any
+		for _, test := range group["tests"].([]any) {
+			test := test.(map[string]any)
+			merged := map[string]any{}
+			for k, v := range test {
+				merged[k] = v
+			}
+			for k, v := range results[test["tcId"].(float64)] {
+				merged[k] = v
+			}
+			if keep(group, merged, len(tests)) {
+				tests = append(tests, test)
+				kept[test["tcId"].(float64)] = true
+			}
+		}
+		if len(tests) > 0 {
+			group["tests"] = tests
+			promptGroups = append(promptGroups, group)
+		}
+	}
+	prompt["testGroups"] = promptGroups
+
+	var expectedGroups []any
+	for _, group := range expected["testGroups"].([]any) {
+		group := group.(map[string]any)
+		var tests []any
+		for _, test := range group["tests"].([]any) {
+			if kept[test.(map[string]any)["tcId"].(float64)] {
+				tests = append(tests, test)
+			}
+		}
+		if len(tests) > 0 {
+			group["tests"] = tests
+			expectedGroups = append(expectedGroups, group)
+		}
+	}
+	expected["testGroups"] = expectedGroups
+
+	write(filepath.Join(outDir, name, "prompt.json"), prompt)
+	write(filepath.Join(outDir, name, "expectedResults.json"), expected)
+}
+
+// mldsaParameterSet pairs a standard library parameter set with circl's,
+// which is only used to expand the seed into the FIPS 204 private key encoding
+// that the standard library does not export
+type mldsaParameterSet struct {
+	name   string
+	params mldsa.Parameters
+	scheme sign.Scheme
+}
+
+var mldsaParameterSets = []mldsaParameterSet{
+	{"ML-DSA-44", mldsa.MLDSA44(), mldsa44.Scheme()},
+	{"ML-DSA-65", mldsa.MLDSA65(), mldsa65.Scheme()},
+	{"ML-DSA-87", mldsa.MLDSA87(), mldsa87.Scheme()},
+}
+
+// externalMLDSA signs with the external interface of the standard library and
+// returns a deterministic sigGen set and a sigVer set
+func externalMLDSA() (sigGen, sigVer map[string]any) {
+	message := []byte("ACVP external interface test message")
+	contextString := []byte("crypto-benchmark")
+
+	var genGroups, verGroups []any
+	tcID := 0
+	for i, ps := range mldsaParameterSets {
+		seed := sha256.Sum256([]byte("acvp testdata " + ps.name))
+		key, err := mldsa.NewPrivateKey(ps.params, seed[:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		pk, sk := ps.scheme.DeriveKey(seed[:])
+		pkBytes, _ := pk.MarshalBinary()
+		skBytes, _ := sk.MarshalBinary()
+		if !bytes.Equal(pkBytes, key.PublicKey().Bytes()) {
+			log.Fatalf("%s: circl and crypto/mldsa derive different public keys", ps.name)
+		}
+
+		signature, err := key.SignDeterministic(message, &mldsa.Options{Context: string(contextString)})
+		if err != nil {
+			log.Fatal(err)
+		}
+		tcID++
+		genGroups = append(genGroups, map[string]any{
+			"tgId": i + 1, "testType": "AFT", "parameterSet": ps.name,
+			"deterministic": true, "signatureInterface": "external", "preHash": "pure", "externalMu": false,
+			"tests": []any{map[string]any{
+				"tcId": tcID, "sk": hexString(skBytes), "message": hexString(message),
+				"context": hexString(contextString), "signature": hexString(signature),
+			}},
+		})
+
+		// A valid signature, the same signature under another context and,
+		// for one parameter set, a signature with a changed byte in z
+		tampered := append([]byte(nil), signature...)
+		tampered[100] ^= 1
+		cases := []struct {
+			context   []byte
+			signature []byte
+		}{{contextString, signature}, {[]byte("another context"), signature}}
+		if i == 0 {
+			cases = append(cases, struct {
+				context   []byte
+				signature []byte
+			}{contextString, tampered})
+		}
+		var tests []any
+		for _, c := range cases {
+			err := mldsa.Verify(key.PublicKey(), message, c.signature, &mldsa.Options{Context: string(c.context)})
+			tcID++
+			tests = append(tests, map[string]any{
+				"tcId": tcID, "message": hexString(message), "context": hexString(c.context),
+				"signature": hexString(c.signature), "testPassed": err == nil,
+			})
+		}
+		verGroups = append(verGroups, map[string]any{
+			"tgId": i + 1, "testType": "AFT", "parameterSet": ps.name,
+			"signatureInterface": "external", "preHash": "pure", "externalMu": false,
+			"pk": hexString(pkBytes), "tests": tests,
+		})
+	}
+	return vectorSet("ML-DSA", "sigGen", "FIPS204", genGroups), vectorSet("ML-DSA", "sigVer", "FIPS204", verGroups)
+}
+
+// ecdsaKeyVer returns a valid P-256 key, a point off the curve, a coordinate
+// as large as the field, and a P-384 key the MSP cannot import
+func ecdsaKeyVer() map[string]any {
+	p256 := generateECDSA(elliptic.P256())
+	p384 := generateECDSA(elliptic.P384())
+	field := elliptic.P256().Params().P
+
+	return vectorSet("ECDSA", "keyVer", "FIPS186-5", []any{
+		map[string]any{"tgId": 1, "testType": "AFT", "curve": "P-256", "tests": []any{
+			ecdsaKeyTest(1, p256.X, p256.Y, true),
+			ecdsaKeyTest(2, p256.X, new(big.Int).Add(p256.Y, big.NewInt(1)), false),
+			ecdsaKeyTest(3, field, p256.Y, false),
+		}},
+		map[string]any{"tgId": 2, "testType": "AFT", "curve": "P-384", "tests": []any{
+			ecdsaKeyTest(4, p384.X, p384.Y, true),
+		}},
+	})
+}
+
+// ecdsaSigVer returns P-256 SHA-256 signatures in low-S form, in the high-S
+// form that FIPS 186-5 accepts and the MSP rejects, and over another message,
+// with groups for a hash and a curve the MSP does not verify
+func ecdsaSigVer() map[string]any {
+	key := generateECDSA(elliptic.P256())
+	message := []byte("ACVP ECDSA test message")
+	digest := sha256.Sum256(message)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	n := elliptic.P256().Params().N
+	highS := new(big.Int).Sub(n, s)
+	if s.Cmp(highS) > 0 {
+		s, highS = highS, s
+	}
+	p384 := generateECDSA(elliptic.P384())
+
+	return vectorSet("ECDSA", "sigVer", "FIPS186-5", []any{
+		map[string]any{"tgId": 1, "testType": "AFT", "curve": "P-256", "hashAlg": "SHA2-256", "tests": []any{
+			ecdsaSigTest(1, []byte(message), key, r, s, true),
+			ecdsaSigTest(2, []byte(message), key, r, highS, true),
+			ecdsaSigTest(3, []byte("another message"), key, r, s, false),
+		}},
+		map[string]any{"tgId": 2, "testType": "AFT", "curve": "P-256", "hashAlg": "SHA2-384", "tests": []any{
+			ecdsaSigTest(4, message, key, r, s, false),
+		}},
+		map[string]any{"tgId": 3, "testType": "AFT", "curve": "P-384", "hashAlg": "SHA2-384", "tests": []any{
+			ecdsaSigTest(5, message, p384, r, s, false),
+		}},
+	})
+}
+
+func generateECDSA(curve elliptic.Curve) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return key
+}
+
+func ecdsaKeyTest(tcID int, x, y *big.Int, passed bool) map[string]any {
+	return map[string]any{"tcId": tcID, "qx": hexString(x.Bytes()), "qy": hexString(y.Bytes()), "testPassed": passed}
+}
+
+func ecdsaSigTest(tcID int, message []byte, key *ecdsa.PrivateKey, r, s *big.Int, passed bool) map[string]any {
+	return map[string]any{
+		"tcId": tcID, "message": hexString(message),
+		"qx": hexString(key.X.Bytes()), "qy": hexString(key.Y.Bytes()),
+		"r": hexString(r.Bytes()), "s": hexString(s.Bytes()), "testPassed": passed,
+	}
+}
+
+func vectorSet(algorithm, mode, revision string, groups []any) map[string]any {
+	return map[string]any{
+		"vsId": 0, "algorithm": algorithm, "mode": mode, "revision": revision,
+		"isSample": true, "testGroups": groups,
+	}
+}
+
+func hexString(b []byte) string {
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+func read(path string) map[string]any {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal(data, &v); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return v
+}
+
+func write(path string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("wrote", path)
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"crypto-benchmark/hbs"
+	"crypto-benchmark/msp"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// adversarialCase is one input that the verifier must reject without panicking
+type adversarialCase struct {
+	name string
+	run  func() error // Returns an error if the input was not rejected
+}
+
+// validateAdversarial runs the negative verification suite for one algorithm and
+// returns the number of cases checked. A valid signature under a different key is
+// produced for every algorithm in others to test algorithm confusion.
+func validateAdversarial(algorithm msp.SignatureAlgorithm, others []msp.SignatureAlgorithm) (int, error) {
+	message := []byte("Adversarial verification test message")
+
+	signer, err := msp.NewEnhancedMSP(algorithm)
+	if err != nil {
+		return 0, err
+	}
+	defer signer.Close()
+	signature, err := signer.Sign(message)
+	if err != nil {
+		return 0, err
+	}
+	publicKey, err := signer.GetPublicKeyBytes()
+	if err != nil {
+		return 0, err
+	}
+	verifier, err := msp.NewEnhancedMSPFromPublicKey(algorithm, publicKey)
+	if err != nil {
+		return 0, err
+	}
+	defer verifier.Close()
+
+	// The untampered signature must verify, or every rejection below is meaningless
+	if valid, err := verifier.Verify(message, signature); err != nil || !valid {
+		return 0, fmt.Errorf("reference signature does not verify (valid=%v, err=%v)", valid, err)
+	}
+
+	rejects := func(msg, sig []byte) func() error {
+		return func() error { return expectRejected(verifier, msg, sig) }
+	}
+
+	var cases []adversarialCase
+
+	// Tampered messages
+	tampered := append([]byte(nil), message...)
+	tampered[0] ^= 0x01
+	cases = append(cases,
+		adversarialCase{"tampered message", rejects(tampered, signature)},
+		adversarialCase{"truncated message", rejects(message[:len(message)-1], signature)},
+		adversarialCase{"extended message", rejects(append(append([]byte(nil), message...), 0), signature)},
+		adversarialCase{"empty message", rejects(nil, signature)},
+	)
+
+	// Single bit flips across the whole signature
+	for _, pos := range bitFlipPositions(len(signature)) {
+		flipped := append([]byte(nil), signature...)
+		flipped[pos/8] ^= 1 << (pos % 8)
+		cases = append(cases, adversarialCase{fmt.Sprintf("bit %d flipped", pos), rejects(message, flipped)})
+	}
+
+	// Truncated and oversized signatures
+	cases = append(cases,
+		adversarialCase{"nil signature", rejects(message, nil)},
+		adversarialCase{"empty signature", rejects(message, []byte{})},
+		adversarialCase{"signature missing last byte", rejects(message, signature[:len(signature)-1])},
+		adversarialCase{"signature truncated to half", rejects(message, signature[:len(signature)/2])},
+		adversarialCase{"signature with trailing byte", rejects(message, append(append([]byte(nil), signature...), 0))},
+		adversarialCase{"signature repeated twice", rejects(message, append(append([]byte(nil), signature...), signature...))},
+		adversarialCase{"all-zero signature", rejects(message, make([]byte, len(signature)))},
+	)
+
+	// A valid signature under a different key of the same algorithm
+	other, err := msp.NewEnhancedMSP(algorithm)
+	if err != nil {
+		return 0, err
+	}
+	defer other.Close()
+	otherSignature, err := other.Sign(message)
+	if err != nil {
+		return 0, err
+	}
+	cases = append(cases, adversarialCase{"signature under wrong key", rejects(message, otherSignature)})
+
+	// Algorithm confusion: valid signatures and keys of every other algorithm
+	for _, confused := range others {
+		if confused == algorithm {
+			continue
+		}
+		foreign, err := msp.NewEnhancedMSP(confused)
+		if err != nil {
+			return 0, err
+		}
+		defer foreign.Close()
+		foreignSignature, err := foreign.Sign(message)
+		if err != nil {
+			return 0, err
+		}
+		foreignKey, err := foreign.GetPublicKeyBytes()
+		if err != nil {
+			return 0, err
+		}
+		cases = append(cases,
+			adversarialCase{confused.String() + " signature", rejects(message, foreignSignature)},
+			adversarialCase{confused.String() + " public key", importRejected(algorithm, foreignKey, message, signature)},
+		)
+	}
+
+	// Malformed public keys
+	cases = append(cases,
+		adversarialCase{"nil public key", importRejected(algorithm, nil, message, signature)},
+		adversarialCase{"truncated public key", importRejected(algorithm, publicKey[:len(publicKey)-1], message, signature)},
+		adversarialCase{"public key with trailing byte", importRejected(algorithm, append(append([]byte(nil), publicKey...), 0), message, signature)},
+		adversarialCase{"random public key", importRejected(algorithm, randomBytes(len(publicKey)), message, signature)},
+	)
+	if algorithm == msp.ECDSA {
+		ecdsaCases, err := ecdsaAdversarialCases(verifier, publicKey, message, signature)
+		if err != nil {
+			return 0, err
+		}
+		cases = append(cases, ecdsaCases...)
+	}
+	contextCases, err := contextAdversarialCases(signer, verifier, message, signature)
+	if err != nil {
+		return 0, err
+	}
+	cases = append(cases, contextCases...)
+	if algorithm.IsStateful() {
+		statefulCases, err := statefulAdversarialCases(algorithm, signer, message)
+		if err != nil {
+			return 0, err
+		}
+		cases = append(cases, statefulCases...)
+	}
+
+	// A verify-only MSP must refuse to sign instead of dereferencing a missing key
+	cases = append(cases, adversarialCase{"signing without private key", func() error {
+		if _, err := verifier.Sign(message); err == nil {
+			return fmt.Errorf("signing succeeded without a private key")
+		}
+		return nil
+	}})
+
+	for _, c := range cases {
+		if err := runAdversarialCase(c); err != nil {
+			return 0, fmt.Errorf("%s: %v", c.name, err)
+		}
+	}
+	return len(cases), nil
+}
+
+// contextAdversarialCases checks domain separation: a signature made under one
+// context string must not verify under any other, including the empty context.
+// ECDSA, LMS and XMSS cannot bind a context, so they must refuse to sign or
+// verify with one.
+func contextAdversarialCases(signer, verifier *msp.EnhancedMSP, message, signature []byte) ([]adversarialCase, error) {
+	ctx := context.Background()
+	if algorithm := signer.GetAlgorithm(); !algorithm.SupportsContextString() {
+		return []adversarialCase{
+			{"signing with a context string", func() error {
+				if _, err := signer.SignWithContextString(ctx, message, []byte(msp.Domains[0].Context)); !errors.Is(err, msp.ErrInvalidContextString) {
+					return fmt.Errorf("%s signing with a context string it cannot bind: expected ErrInvalidContextString, got %v", algorithm, err)
+				}
+				return nil
+			}},
+			{"verifying with a context string", func() error {
+				valid, err := verifier.VerifyWithContextString(ctx, message, signature, []byte(msp.Domains[0].Context))
+				if valid {
+					return fmt.Errorf("%s signature verified under a context string", algorithm)
+				}
+				if !errors.Is(err, msp.ErrInvalidContextString) {
+					return fmt.Errorf("%s verifying under a context string: expected ErrInvalidContextString, got %v", algorithm, err)
+				}
+				return nil
+			}},
+		}, nil
+	}
+
+	// The empty context and every domain, each with a signature made under it
+	contexts := [][]byte{nil}
+	for _, d := range msp.Domains {
+		contexts = append(contexts, []byte(d.Context))
+	}
+	signatures := [][]byte{signature}
+	for _, contextString := range contexts[1:] {
+		sig, err := signer.SignWithContextString(ctx, message, contextString)
+		if err != nil {
+			return nil, err
+		}
+		if valid, err := verifier.VerifyWithContextString(ctx, message, sig, contextString); err != nil || !valid {
+			return nil, fmt.Errorf("signature under context %q does not verify (valid=%v, err=%v)", contextString, valid, err)
+		}
+		signatures = append(signatures, sig)
+	}
+
+	name := func(contextString []byte) string {
+		if len(contextString) == 0 {
+			return "empty context"
+		}
+		return fmt.Sprintf("context %q", contextString)
+	}
+	var cases []adversarialCase
+	for i, sig := range signatures {
+		for j, contextString := range contexts {
+			if i == j {
+				continue
+			}
+			sig, contextString := sig, contextString
+			cases = append(cases, adversarialCase{
+				fmt.Sprintf("signature under %s verified under %s", name(contexts[i]), name(contextString)),
+				func() error {
+					valid, err := verifier.VerifyWithContextString(ctx, message, sig, contextString)
+					if valid {
+						return fmt.Errorf("accepted (err=%v)", err)
+					}
+					return nil
+				},
+			})
+		}
+	}
+	cases = append(cases, adversarialCase{"context string over 255 bytes", func() error {
+		if _, err := signer.SignWithContextString(ctx, message, make([]byte, msp.MaxContextStringSize+1)); err == nil {
+			return fmt.Errorf("signed with an oversized context string")
+		}
+		return nil
+	}})
+	return cases, nil
+}
+
+// statefulAdversarialCases checks that a stateful key never signs twice with
+// one one-time key, even alternating with a clone, and that it refuses to sign
+// once its state store has handed out every index
+func statefulAdversarialCases(algorithm msp.SignatureAlgorithm, signer *msp.EnhancedMSP, message []byte) ([]adversarialCase, error) {
+	scheme := algorithm.StatefulScheme()
+	clone, err := signer.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	store := hbs.NewMemoryStateStore()
+	exhausted, err := msp.NewEnhancedMSPWithOptions(context.Background(), algorithm, msp.Options{StateStore: store})
+	if err != nil {
+		return nil, err
+	}
+	exhaustedKey, err := exhausted.GetPublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := store.Reserve(hbs.KeyID(exhaustedKey), scheme.MaxSignatures(), scheme.MaxSignatures()); err != nil {
+		return nil, err
+	}
+
+	return []adversarialCase{
+		{"clone reusing a one-time key", func() error {
+			seen := make(map[uint64]bool)
+			for i := 0; i < 4; i++ {
+				for _, m := range []*msp.EnhancedMSP{signer, clone} {
+					signature, err := m.Sign(message)
+					if err != nil {
+						return err
+					}
+					index, err := scheme.Index(signature)
+					if err != nil {
+						return err
+					}
+					if seen[index] {
+						return fmt.Errorf("index %d used twice", index)
+					}
+					seen[index] = true
+				}
+			}
+			return nil
+		}},
+		{"signing with an exhausted key", func() error {
+			if _, err := exhausted.Sign(message); !errors.Is(err, hbs.ErrKeyExhausted) {
+				return fmt.Errorf("expected an exhausted-key error, got %v", err)
+			}
+			return nil
+		}},
+	}, nil
+}
+
+// ecdsaAdversarialCases covers signature malleability and invalid curve keys
+func ecdsaAdversarialCases(verifier *msp.EnhancedMSP, publicKey, message, signature []byte) ([]adversarialCase, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+		return nil, fmt.Errorf("failed to parse ECDSA signature: %v", err)
+	}
+
+	n := elliptic.P256().Params().N
+	if sig.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return nil, fmt.Errorf("signer produced a high-S signature")
+	}
+
+	// (r, n-s) is mathematically valid and must be refused as malleable
+	highS, err := asn1.Marshal(struct{ R, S *big.Int }{sig.R, new(big.Int).Sub(n, sig.S)})
+	if err != nil {
+		return nil, err
+	}
+	zeroR, _ := asn1.Marshal(struct{ R, S *big.Int }{big.NewInt(0), sig.S})
+	orderS, _ := asn1.Marshal(struct{ R, S *big.Int }{sig.R, n})
+	trailing := append(append([]byte(nil), signature...), 0)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	p384Key, err := x509.MarshalPKIXPublicKey(&p384.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	x25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	x25519Key, err := x509.MarshalPKIXPublicKey(x25519.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	// Changing the last byte of Y moves the point off the curve
+	offCurve := append([]byte(nil), publicKey...)
+	offCurve[len(offCurve)-1] ^= 0x01
+
+	rejects := func(sig []byte) func() error {
+		return func() error { return expectRejected(verifier, message, sig) }
+	}
+	return []adversarialCase{
+		{"high-S signature", rejects(highS)},
+		{"zero r", rejects(zeroR)},
+		{"s equal to curve order", rejects(orderS)},
+		{"DER with trailing data", rejects(trailing)},
+		{"P-384 public key", importRejected(msp.ECDSA, p384Key, message, signature)},
+		{"X25519 public key", importRejected(msp.ECDSA, x25519Key, message, signature)},
+		{"off-curve public key", importRejected(msp.ECDSA, offCurve, message, signature)},
+	}, nil
+}
+
+// expectRejected fails if the verifier accepts the signature
+func expectRejected(verifier *msp.EnhancedMSP, message, signature []byte) error {
+	valid, _ := verifier.Verify(message, signature)
+	if valid {
+		return fmt.Errorf("signature accepted")
+	}
+	return nil
+}
+
+// importRejected fails if a malformed public key is both imported and then
+// verifies a signature it did not produce
+func importRejected(algorithm msp.SignatureAlgorithm, publicKey, message, signature []byte) func() error {
+	return func() error {
+		verifier, err := msp.NewEnhancedMSPFromPublicKey(algorithm, publicKey)
+		if err != nil {
+			return nil
+		}
+		defer verifier.Close()
+		return expectRejected(verifier, message, signature)
+	}
+}
+
+// runAdversarialCase runs a case, turning a panic into a failure
+func runAdversarialCase(c adversarialCase) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return c.run()
+}
+
+// bitFlipPositions returns every bit of the first and last byte plus one bit
+// in each of 64 evenly spaced bytes
+func bitFlipPositions(size int) []int {
+	var positions []int
+	for bit := 0; bit < 8; bit++ {
+		positions = append(positions, bit, (size-1)*8+bit)
+	}
+	step := size / 64
+	if step < 1 {
+		step = 1
+	}
+	for i := step; i < size-1; i += step {
+		positions = append(positions, i*8+i%8)
+	}
+	return positions
+}
+
+// randomBytes returns n bytes from the system random source
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"crypto-benchmark/msp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// batchResult is the time to verify one batch in one mode
+type batchResult struct {
+	Algorithm      string  `json:"algorithm"`
+	BatchSize      int     `json:"batch_size"`
+	Signers        int     `json:"signers"`
+	Mode           string  `json:"mode"`
+	Workers        int     `json:"workers"`
+	TotalMs        float64 `json:"total_ms"`
+	PerSignatureUs float64 `json:"per_signature_us"`
+	Speedup        float64 `json:"speedup"` // Relative to the sequential mode without key cache
+}
+
+// Batch verification modes, in reporting order
+const (
+	batchModeNaive     = "sequential"
+	batchModeCached    = "sequential+key-cache"
+	batchModeParallel  = "parallel+key-cache"
+	batchModeAggregate = "aggregate"
+)
+
+// runBatch implements the batch command and returns the process exit code
+func runBatch(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	sizeList := fs.String("sizes", "16,128,512", "Comma-separated batch sizes (signatures per block)")
+	signers := fs.Int("signers", 4, "Distinct signing keys per batch, as endorsing peers")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "Goroutines for parallel verification")
+	repeat := fs.Int("repeat", 5, "Repetitions per mode; the median is reported")
+	algorithmList := fs.String("algorithms", "ECDSA,ML-DSA-44,ML-DSA-65,ML-DSA-87", "Comma-separated algorithms")
+	withBLS := fs.Bool("bls", true, "Include BLS12-381 aggregate signatures for comparison")
+	jsonOut := fs.String("json", "", "Also write the results as JSON to this file")
+	fs.Parse(args)
+
+	var sizes []int
+	for _, s := range splitList(*sizeList) {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < 1 {
+			log.Fatalf("Invalid batch size: %s", s)
+		}
+		sizes = append(sizes, size)
+	}
+	if *signers < 1 || *repeat < 1 {
+		log.Fatalf("--signers and --repeat must be at least 1")
+	}
+
+	fmt.Printf("Batch verification study: %d signers, %d workers, median of %d runs\n", *signers, *workers, *repeat)
+
+	var results []batchResult
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		for _, size := range sizes {
+			rows, err := studyBatch(algorithm, size, *signers, *workers, *repeat)
+			if err != nil {
+				log.Fatalf("Batch study failed for %s: %v", name, err)
+			}
+			results = append(results, rows...)
+		}
+	}
+	if *withBLS {
+		for _, size := range sizes {
+			rows, err := studyBLS(size, *signers, *repeat)
+			if err != nil {
+				log.Fatalf("BLS batch study failed: %v", err)
+			}
+			results = append(results, rows...)
+		}
+	}
+
+	printBatchResults(results)
+	printECDSARatios(results)
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+		if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+			log.Fatalf("Failed to write results: %v", err)
+		}
+		fmt.Printf("Results saved to: %s\n", *jsonOut)
+	}
+	return 0
+}
+
+// batchMessage is the message of the i-th item, distinct for every item as
+// each endorsement covers a different transaction and endorser
+func batchMessage(i, signer int) []byte {
+	return []byte(fmt.Sprintf("Block endorsement %d by endorser %d", i, signer))
+}
+
+// studyBatch times BatchVerify of one algorithm in each mode
+func studyBatch(algorithm msp.SignatureAlgorithm, size, signers, workers, repeat int) ([]batchResult, error) {
+	keys := make([]*msp.EnhancedMSP, signers)
+	publicKeys := make([][]byte, signers)
+	for s := range keys {
+		var err error
+		if keys[s], err = msp.NewEnhancedMSP(algorithm); err != nil {
+			return nil, err
+		}
+		if publicKeys[s], err = keys[s].GetPublicKeyBytes(); err != nil {
+			return nil, err
+		}
+	}
+
+	items := make([]msp.BatchItem, size)
+	for i := range items {
+		s := i % signers
+		message := batchMessage(i, s)
+		signature, err := keys[s].Sign(message)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = msp.BatchItem{PublicKey: publicKeys[s], Message: message, Signature: signature}
+	}
+
+	// An invalid item must be reported at its own position
+	tampered := append([]msp.BatchItem(nil), items...)
+	tampered[size/2].Message = append([]byte("forged "), tampered[size/2].Message...)
+	check, err := msp.BatchVerify(algorithm, tampered, msp.BatchOptions{Workers: workers})
+	if err != nil {
+		return nil, err
+	}
+	for i, valid := range check {
+		if valid != (i != size/2) {
+			return nil, fmt.Errorf("batch verification reported item %d as %v", i, valid)
+		}
+	}
+
+	modes := []struct {
+		name string
+		opts msp.BatchOptions
+	}{
+		{batchModeNaive, msp.BatchOptions{Workers: 1, NoKeyCache: true}},
+		{batchModeCached, msp.BatchOptions{Workers: 1}},
+		{batchModeParallel, msp.BatchOptions{Workers: workers}},
+	}
+	var rows []batchResult
+	for _, mode := range modes {
+		var verifyErr error
+		elapsed := medianRun(repeat, func() {
+			results, err := msp.BatchVerify(algorithm, items, mode.opts)
+			if err == nil && !msp.AllValid(results) {
+				err = fmt.Errorf("valid batch did not verify in %s mode", mode.name)
+			}
+			if err != nil {
+				verifyErr = err
+			}
+		})
+		if verifyErr != nil {
+			return nil, verifyErr
+		}
+		rows = append(rows, newBatchResult(algorithm.String(), size, signers, mode.name, mode.opts.Workers, elapsed))
+	}
+	return withSpeedups(rows), nil
+}
+
+// studyBLS times individual and aggregate verification of BLS signatures
+func studyBLS(size, signers, repeat int) ([]batchResult, error) {
+	keys := make([]*msp.BLSKeyPair, signers)
+	publicKeyBytes := make([][]byte, signers)
+	for s := range keys {
+		var err error
+		if keys[s], err = msp.NewBLSKeyPair(); err != nil {
+			return nil, err
+		}
+		if publicKeyBytes[s], err = keys[s].GetPublicKeyBytes(); err != nil {
+			return nil, err
+		}
+	}
+
+	messages := make([][]byte, size)
+	signatures := make([][]byte, size)
+	itemKeys := make([][]byte, size)
+	for i := range messages {
+		s := i % signers
+		messages[i] = batchMessage(i, s)
+		signatures[i] = keys[s].Sign(messages[i])
+		itemKeys[i] = publicKeyBytes[s]
+	}
+	parsed := make([]*msp.BLSPublicKey, size)
+	for i := range parsed {
+		parsed[i] = keys[i%signers].PublicKey
+	}
+
+	var failure error
+	fail := func(err error) {
+		if failure == nil {
+			failure = err
+		}
+	}
+
+	naive := medianRun(repeat, func() {
+		for i := range messages {
+			publicKey, err := msp.ParseBLSPublicKey(itemKeys[i])
+			if err != nil || !msp.VerifyBLS(publicKey, messages[i], signatures[i]) {
+				fail(fmt.Errorf("BLS signature %d did not verify", i))
+			}
+		}
+	})
+	cached := medianRun(repeat, func() {
+		for i := range messages {
+			if !msp.VerifyBLS(parsed[i], messages[i], signatures[i]) {
+				fail(fmt.Errorf("BLS signature %d did not verify", i))
+			}
+		}
+	})
+	aggregated := medianRun(repeat, func() {
+		aggregate, err := msp.AggregateBLS(signatures)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if !msp.VerifyAggregateBLS(parsed, messages, aggregate) {
+			fail(fmt.Errorf("BLS aggregate signature did not verify"))
+		}
+	})
+	if failure != nil {
+		return nil, failure
+	}
+
+	// The aggregate must fail if any one message changes
+	aggregate, err := msp.AggregateBLS(signatures)
+	if err != nil {
+		return nil, err
+	}
+	forged := append([][]byte(nil), messages...)
+	forged[size/2] = append([]byte("forged "), forged[size/2]...)
+	if msp.VerifyAggregateBLS(parsed, forged, aggregate) {
+		return nil, fmt.Errorf("BLS aggregate verified a modified message")
+	}
+
+	rows := []batchResult{
+		newBatchResult("BLS12-381", size, signers, batchModeNaive, 1, naive),
+		newBatchResult("BLS12-381", size, signers, batchModeCached, 1, cached),
+		newBatchResult("BLS12-381", size, signers, batchModeAggregate, 1, aggregated),
+	}
+	return withSpeedups(rows), nil
+}
+
+// medianRun runs f repeat times and returns the median duration
+func medianRun(repeat int, f func()) time.Duration {
+	times := make([]time.Duration, repeat)
+	for i := range times {
+		start := time.Now()
+		f()
+		times[i] = time.Since(start)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[repeat/2]
+}
+
+// newBatchResult converts a batch duration into a result row
+func newBatchResult(algorithm string, size, signers int, mode string, workers int, elapsed time.Duration) batchResult {
+	return batchResult{
+		Algorithm:      algorithm,
+		BatchSize:      size,
+		Signers:        signers,
+		Mode:           mode,
+		Workers:        workers,
+		TotalMs:        float64(elapsed.Nanoseconds()) / 1e6,
+		PerSignatureUs: float64(elapsed.Nanoseconds()) / 1e3 / float64(size),
+	}
+}
+
+// withSpeedups fills in each row's speedup over the first (naive) row
+func withSpeedups(rows []batchResult) []batchResult {
+	for i := range rows {
+		if rows[i].TotalMs > 0 {
+			rows[i].Speedup = rows[0].TotalMs / rows[i].TotalMs
+		}
+	}
+	return rows
+}
+
+// printBatchResults prints one table row per algorithm, batch size and mode
+func printBatchResults(results []batchResult) {
+	fmt.Printf("\n%-10s %6s  %-22s %11s %11s %8s\n", "Algorithm", "Batch", "Mode", "Total (ms)", "Per sig (µs)", "Speedup")
+	for _, r := range results {
+		fmt.Printf("%-10s %6d  %-22s %11.3f %11.1f %7.2fx\n", r.Algorithm, r.BatchSize, r.Mode, r.TotalMs, r.PerSignatureUs, r.Speedup)
+	}
+}
+
+// printECDSARatios compares each post-quantum batch with ECDSA at the same
+// batch size: sequentially without a key cache, as Fabric validates today, and
+// with the fastest mode of each, which is what batching can recover
+func printECDSARatios(results []batchResult) {
+	type key struct {
+		algorithm string
+		size      int
+	}
+	naive := make(map[key]batchResult)
+	best := make(map[key]batchResult)
+	var order []key
+	for _, r := range results {
+		k := key{r.Algorithm, r.BatchSize}
+		if r.Mode == batchModeNaive {
+			naive[k] = r
+			order = append(order, k)
+		}
+		if b, ok := best[k]; !ok || r.TotalMs < b.TotalMs {
+			best[k] = r
+		}
+	}
+
+	printed := false
+	for _, k := range order {
+		ecdsa := key{msp.ECDSA.String(), k.size}
+		if k.algorithm == ecdsa.algorithm {
+			continue
+		}
+		if _, ok := naive[ecdsa]; !ok {
+			continue
+		}
+		if !printed {
+			fmt.Println("\nCost relative to ECDSA at the same batch size:")
+			printed = true
+		}
+		fmt.Printf("  %-10s batch %4d: %5.2fx sequentially, %5.2fx with %s (ECDSA best: %s)\n",
+			k.algorithm, k.size,
+			naive[k].TotalMs/naive[ecdsa].TotalMs,
+			best[k].TotalMs/best[ecdsa].TotalMs, best[k].Mode, best[ecdsa].Mode)
+	}
+}
@@ -20,6 +20,10 @@ from pqcrypto.sign import ml_dsa_44, ml_dsa_65, ml_dsa_87
 TX_PER_BLOCK = 100
 TOTAL_BLOCKS = 5
 
+# Results schema shared with the Go benchmark (see schema/ and metrics/schema.go)
+SCHEMA_VERSION = 2
+RESULTS_KIND = "blockchain_simulation"
+
 def serialize_tx(tx):
     return json.dumps(tx, sort_keys=True).encode()
 
@@ -124,7 +128,7 @@ def run_simulation(alg_name, sign_mod=None):
         "tps": tps,
         "avg_sign_time_ms": mean(sign_times) * 1000,
         "avg_verify_time_ms": mean(verify_times) * 1000,
-        "avg_latency_ms": mean(tx_latencies) * 1000,
+        "avg_tx_latency_ms": mean(tx_latencies) * 1000,
         "avg_block_time_ms": mean(block_times) * 1000,
         "avg_block_size_bytes": mean(block_sizes)
     }
@@ -134,7 +138,7 @@ def print_results(result):
     print(f"TPS                    : {result['tps']:.2f}")
     print(f"Avg Sign Time          : {result['avg_sign_time_ms']:.3f} ms")
     print(f"Avg Verify Time        : {result['avg_verify_time_ms']:.3f} ms")
-    print(f"Avg Tx Latency         : {result['avg_latency_ms']:.3f} ms")
+    print(f"Avg Tx Latency         : {result['avg_tx_latency_ms']:.3f} ms")
     print(f"Avg Block Time         : {result['avg_block_time_ms']:.3f} ms")
     print(f"Avg Block Size         : {result['avg_block_size_bytes']:.1f} bytes")
 
@@ -151,6 +155,8 @@ def save_results_to_json(sequential_results, parallel_results, filename_prefix="
     
     # Prepare data for JSON serialization
     json_data = {
+        "schema_version": SCHEMA_VERSION,
+        "kind": RESULTS_KIND,
         "simulation_info": {
             "timestamp": datetime.now().isoformat(),
             "tx_per_block": TX_PER_BLOCK,
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto-benchmark/metrics"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// runCompare implements the compare command and returns the process exit code
+func runCompare(args []string) int {
+	defaults := metrics.DefaultCompareOptions()
+
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	threshold := fs.Float64("threshold", defaults.Threshold, "Regression threshold in percent slower than the baseline")
+	alpha := fs.Float64("alpha", defaults.Alpha, "Significance level for the statistical test")
+	test := fs.String("test", defaults.Test, "Significance test: mannwhitney or welch")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: benchmark compare [flags] <baseline.json> <candidate.json> [candidate.json...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return 2
+	}
+
+	opts := metrics.CompareOptions{
+		Threshold: *threshold,
+		Alpha:     *alpha,
+		Test:      *test,
+	}
+
+	baseline, err := metrics.LoadResults(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to load baseline: %v", err)
+	}
+
+	regressions := 0
+	for _, filename := range fs.Args()[1:] {
+		candidate, err := metrics.LoadResults(filename)
+		if err != nil {
+			log.Fatalf("Failed to load candidate: %v", err)
+		}
+
+		comparison, err := metrics.CompareResults(baseline, candidate, opts)
+		if err != nil {
+			log.Fatalf("Comparison failed: %v", err)
+		}
+		comparison.Baseline = fs.Arg(0)
+		comparison.Candidate = filename
+		comparison.PrintComparison()
+
+		regressions += len(comparison.Regressions())
+	}
+
+	if regressions > 0 {
+		fmt.Printf("\n✗ %d regression(s) above %.1f%% detected\n", regressions, opts.Threshold)
+		return 1
+	}
+
+	fmt.Println("\n✓ No regressions detected")
+	return 0
+}
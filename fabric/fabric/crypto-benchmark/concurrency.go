@@ -0,0 +1,319 @@
+package main
+
+import (
+	"crypto-benchmark/msp"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runConcurrency implements the concurrency command: stress checks that sign,
+// verify, clone and swap the keys of one MSP from many goroutines at once.
+// They catch wrong results on their own, and data races when the binary is
+// built with -race. It returns the process exit code.
+func runConcurrency(args []string) int {
+	fs := flag.NewFlagSet("concurrency", flag.ExitOnError)
+	algorithmList := fs.String("algorithms", "ECDSA,ML-DSA-44,ML-DSA-65,ML-DSA-87", "Comma-separated algorithms to check")
+	goroutines := fs.Int("goroutines", 8, "Signing goroutines per algorithm; half as many verify")
+	duration := fs.Duration("duration", 2*time.Second, "Time spent on each algorithm")
+	rekeys := fs.Int("rekeys", 20, "Key swaps per algorithm, spread over the duration")
+	fs.Parse(args)
+
+	if *goroutines < 2 {
+		log.Fatalf("--goroutines must be at least 2")
+	}
+	if !raceDetectorEnabled() {
+		fmt.Println("Warning: built without -race, so only wrong results are caught; run `go run -race . concurrency`")
+	}
+
+	failed := false
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		counts, err := stressMSP(algorithm, *goroutines, *duration, *rekeys)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", algorithm, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("✓ %s: %d signatures, %d verifications, %d clones, %d key swaps\n",
+			algorithm, counts.signatures.Load(), counts.verifications.Load(), counts.clones.Load(), counts.swaps.Load())
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// raceDetectorEnabled reports whether the binary was built with -race
+func raceDetectorEnabled() bool {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return false
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "-race" {
+			enabled, _ := strconv.ParseBool(setting.Value)
+			return enabled
+		}
+	}
+	return false
+}
+
+// stressCounts counts the operations of a stress run
+type stressCounts struct {
+	signatures, verifications, clones, swaps atomic.Int64
+}
+
+// publishedKeys records every key pair an identity has had, by public key
+type publishedKeys struct {
+	mu      sync.Mutex
+	signers map[string]*msp.Signer
+}
+
+func (p *publishedKeys) add(signer *msp.Signer) error {
+	publicKey, err := signer.PublicKeyBytes()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signers[hex.EncodeToString(publicKey)] = signer
+	return nil
+}
+
+func (p *publishedKeys) lookup(publicKey []byte) *msp.Signer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.signers[hex.EncodeToString(publicKey)]
+}
+
+// verifiedByAny reports whether a signature verifies with any published key
+func (p *publishedKeys) verifiedByAny(message, signature []byte) bool {
+	p.mu.Lock()
+	signers := make([]*msp.Signer, 0, len(p.signers))
+	for _, signer := range p.signers {
+		signers = append(signers, signer)
+	}
+	p.mu.Unlock()
+	for _, signer := range signers {
+		if valid, _ := signer.Verifier().Verify(message, signature); valid {
+			return true
+		}
+	}
+	return false
+}
+
+// stressMSP runs one identity MSP, as a gateway holds it, and one verify-only
+// MSP of its public key, as a peer holds it, through concurrent signing,
+// verification, cloning and key swaps for the given duration
+func stressMSP(algorithm msp.SignatureAlgorithm, goroutines int, duration time.Duration, rekeys int) (*stressCounts, error) {
+	identity, err := msp.NewEnhancedMSP(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	defer identity.Close()
+	publicKey, err := identity.GetPublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	peer, err := msp.NewEnhancedMSPFromPublicKey(algorithm, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	first, err := identity.Signer()
+	if err != nil {
+		return nil, err
+	}
+	published := &publishedKeys{signers: make(map[string]*msp.Signer)}
+	if err := published.add(first); err != nil {
+		return nil, err
+	}
+
+	counts := &stressCounts{}
+	var (
+		firstErr error
+		errOnce  sync.Once
+		stop     atomic.Bool
+		wg       sync.WaitGroup
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { firstErr = err })
+		stop.Store(true)
+	}
+	deadline := time.Now().Add(duration)
+	running := func() bool { return !stop.Load() && time.Now().Before(deadline) }
+
+	// Signatures made through the identity MSP while its keys are swapped;
+	// those made with a key that was not yet recorded are checked at the end
+	var (
+		pendingMu sync.Mutex
+		pending   [][2][]byte
+	)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; running(); i++ {
+				message := []byte(fmt.Sprintf("concurrency %d/%d", g, i))
+				if i%2 == 0 {
+					signature, err := identity.Sign(message)
+					if err != nil {
+						fail(fmt.Errorf("identity signing: %v", err))
+						return
+					}
+					if !published.verifiedByAny(message, signature) {
+						pendingMu.Lock()
+						pending = append(pending, [2][]byte{message, signature})
+						pendingMu.Unlock()
+					}
+				} else {
+					signer, err := identity.Signer()
+					if err != nil {
+						fail(err)
+						return
+					}
+					signature, err := signer.Sign(message)
+					if err != nil {
+						fail(fmt.Errorf("signer snapshot: %v", err))
+						return
+					}
+					if valid, err := signer.Verifier().Verify(message, signature); err != nil || !valid {
+						fail(fmt.Errorf("signer snapshot signature does not verify with its own verifier (%v)", err))
+						return
+					}
+				}
+				counts.signatures.Add(1)
+			}
+		}(g)
+	}
+
+	// Peers verify against whichever key the verify-only MSP holds, signing
+	// with the published key pair it belongs to
+	for g := 0; g < goroutines/2; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; running(); i++ {
+				verifier, err := peer.Verifier()
+				if err != nil {
+					fail(err)
+					return
+				}
+				publicKey, err := verifier.PublicKeyBytes()
+				if err != nil {
+					fail(err)
+					return
+				}
+				signer := published.lookup(publicKey)
+				if signer == nil {
+					fail(errors.New("peer holds a public key the identity never had"))
+					return
+				}
+				message := []byte(fmt.Sprintf("peer %d/%d", g, i))
+				signature, err := signer.Sign(message)
+				if err != nil {
+					fail(err)
+					return
+				}
+				if valid, err := verifier.Verify(message, signature); err != nil || !valid {
+					fail(fmt.Errorf("verifier snapshot rejected a signature of its key (%v)", err))
+					return
+				}
+				// The peer MSP may have swapped keys since, so only errors other
+				// than a failed verification count
+				if _, err := peer.Verify(message, signature); err != nil && !errors.Is(err, msp.ErrVerificationFailed) {
+					fail(fmt.Errorf("peer verification: %v", err))
+					return
+				}
+				counts.verifications.Add(2)
+			}
+		}(g)
+	}
+
+	// Clones must copy a private key and public key that belong together
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; running(); i++ {
+			clone, err := identity.Clone()
+			if err != nil {
+				fail(fmt.Errorf("clone: %v", err))
+				return
+			}
+			message := []byte(fmt.Sprintf("clone %d", i))
+			signature, err := clone.Sign(message)
+			if err == nil {
+				var valid bool
+				if valid, err = clone.Verify(message, signature); err == nil && !valid {
+					err = errors.New("clone's signature does not verify with its public key")
+				}
+			}
+			clone.Close()
+			if err != nil {
+				fail(err)
+				return
+			}
+			counts.clones.Add(1)
+		}
+	}()
+
+	// Key swaps: the identity rekeys and the peer follows its new public key
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if rekeys < 1 {
+			return
+		}
+		interval := duration / time.Duration(rekeys+1)
+		for i := 0; i < rekeys && running(); i++ {
+			time.Sleep(interval)
+			if err := identity.Rekey(); err != nil {
+				fail(fmt.Errorf("rekey: %v", err))
+				return
+			}
+			signer, err := identity.Signer()
+			if err != nil {
+				fail(err)
+				return
+			}
+			if err := published.add(signer); err != nil {
+				fail(err)
+				return
+			}
+			publicKey, err := signer.PublicKeyBytes()
+			if err != nil {
+				fail(err)
+				return
+			}
+			if err := peer.SetPublicKey(publicKey); err != nil {
+				fail(fmt.Errorf("peer key swap: %v", err))
+				return
+			}
+			counts.swaps.Add(1)
+		}
+	}()
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	for _, p := range pending {
+		if !published.verifiedByAny(p[0], p[1]) {
+			return nil, fmt.Errorf("identity signature of %q verifies with none of its keys", p[0])
+		}
+	}
+	if err := identity.SetPublicKey(publicKey); err == nil {
+		return nil, errors.New("SetPublicKey replaced the public key of an MSP with a private key")
+	}
+	return counts, nil
+}
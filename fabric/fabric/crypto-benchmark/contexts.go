@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto-benchmark/msp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runContexts implements the contexts command and returns the process exit code
+func runContexts(args []string) int {
+	fs := flag.NewFlagSet("contexts", flag.ExitOnError)
+	message := fs.String("message", "Hyperledger Fabric ML-DSA vs ECDSA Performance Benchmark Test Message", "Test message for benchmarking")
+	iterations := fs.Int("iterations", 200, "Signatures per context and algorithm")
+	algorithmList := fs.String("algorithms", "ML-DSA-44,ML-DSA-65,ML-DSA-87", "Comma-separated ML-DSA algorithms to measure")
+	jsonOut := fs.String("json", "", "Also write the results as JSON to this file")
+	list := fs.Bool("list", false, "Print the domain table and exit")
+	fs.Parse(args)
+
+	fmt.Println("Domain-separation contexts")
+	for _, d := range msp.Domains {
+		fmt.Printf("  %-14s %-26q %s\n", d.Name, d.Context, d.Description)
+	}
+	if *list {
+		return 0
+	}
+
+	var results []msp.ContextMetrics
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		if !algorithm.SupportsContextString() {
+			log.Fatalf("%s has no context strings to measure", algorithm)
+		}
+
+		signer, err := msp.NewEnhancedMSP(algorithm)
+		if err != nil {
+			log.Fatalf("Failed to create MSP for %s: %v", name, err)
+		}
+		metrics, err := signer.BenchmarkContextStrings([]byte(*message), *iterations)
+		if err != nil {
+			log.Fatalf("Context benchmark failed for %s: %v", name, err)
+		}
+
+		fmt.Printf("\n%s (%d iterations)\n", algorithm.String(), *iterations)
+		fmt.Printf("  %-14s %10s %9s %10s %9s\n", "Domain", "Sign (ms)", "Overhead", "Verify (ms)", "Overhead")
+		for _, m := range metrics {
+			fmt.Printf("  %-14s %10.3f %+8.1f%% %10.3f %+8.1f%%\n",
+				m.Domain, m.SignTimeMs, m.SignOverheadPct, m.VerifyTimeMs, m.VerifyOverheadPct)
+		}
+		results = append(results, metrics...)
+	}
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+		if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+			log.Fatalf("Failed to write results: %v", err)
+		}
+		fmt.Printf("Results saved to: %s\n", *jsonOut)
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto-benchmark/metrics"
+	"crypto-benchmark/msp"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runDaemon implements the daemon command and returns the process exit code
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	listen := fs.String("listen", ":9464", "Address for the /metrics endpoint")
+	interval := fs.Duration("interval", time.Minute, "Pause between benchmark rounds")
+	iterations := fs.Int("iterations", 50, "Number of iterations per algorithm per round")
+	message := fs.String("message", "Hyperledger Fabric ML-DSA vs ECDSA Performance Benchmark Test Message", "Test message for benchmarking")
+	algorithmList := fs.String("algorithms", "ECDSA,ML-DSA-44,ML-DSA-65,ML-DSA-87", "Comma-separated algorithms to benchmark")
+	fs.Parse(args)
+
+	var algorithms []msp.SignatureAlgorithm
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+
+	exporter := metrics.NewPrometheusExporter(metrics.DefaultLatencyBuckets, metrics.CaptureEnvironment())
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "PQC crypto benchmark daemon; metrics at /metrics")
+	})
+	server := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Metrics server failed: %v", err)
+		}
+	}()
+	fmt.Printf("Serving metrics on %s/metrics (every %v, %d iterations)\n", *listen, *interval, *iterations)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for round := 1; ; round++ {
+		runDaemonRound(exporter, algorithms, []byte(*message), *iterations)
+		fmt.Printf("Round %d completed at %s\n", round, time.Now().Format(time.RFC3339))
+
+		select {
+		case <-ctx.Done():
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdown)
+			return 0
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// runDaemonRound benchmarks every algorithm once and feeds the results to the exporter
+func runDaemonRound(exporter *metrics.PrometheusExporter, algorithms []msp.SignatureAlgorithm, message []byte, iterations int) {
+	for _, algorithm := range algorithms {
+		mspInstance, err := msp.NewEnhancedMSP(algorithm)
+		if err != nil {
+			log.Printf("Failed to create MSP for %s: %v", algorithm.String(), err)
+			exporter.ObserveFailure(algorithm.String())
+			continue
+		}
+
+		result, err := mspInstance.Benchmark(message, iterations)
+		mspInstance.Close()
+		if err != nil {
+			log.Printf("Benchmark failed for %s: %v", algorithm.String(), err)
+			exporter.ObserveFailure(algorithm.String())
+			continue
+		}
+		exporter.Observe(*result)
+	}
+}
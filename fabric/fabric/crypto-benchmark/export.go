@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto-benchmark/metrics"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runExport implements the export command and returns the process exit code
+func runExport(args []string) int {
+	defaults := metrics.DefaultExportOptions()
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	formats := fs.String("format", "markdown", "Comma-separated export formats: "+strings.Join(metrics.ExporterNames(), ", "))
+	sigFigs := fs.Int("sigfigs", defaults.SignificantFigures, "Significant figures for timings and ratios")
+	baseline := fs.String("baseline", defaults.Baseline, "Algorithm used as the denominator of ratios")
+	stdout := fs.Bool("stdout", false, "Write tables to stdout instead of next to the results file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: benchmark export [flags] <results.json> [results.json...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	opts := metrics.ExportOptions{SignificantFigures: *sigFigs, Baseline: *baseline}
+	for _, filename := range fs.Args() {
+		result, err := metrics.LoadResults(filename)
+		if err != nil {
+			log.Fatalf("Failed to load results: %v", err)
+		}
+
+		for _, format := range splitList(*formats) {
+			exporter, err := metrics.GetExporter(format)
+			if err != nil {
+				log.Fatalf("Export failed: %v", err)
+			}
+
+			if *stdout {
+				if err := exporter.Export(os.Stdout, *result, opts); err != nil {
+					log.Fatalf("Export failed: %v", err)
+				}
+				continue
+			}
+
+			target := exportFilename(filename, exporter)
+			if err := metrics.ExportFile(target, *result, exporter, opts); err != nil {
+				log.Fatalf("Export failed: %v", err)
+			}
+			fmt.Printf("Exported %s\n", target)
+		}
+	}
+
+	return 0
+}
+
+// exportFilename places an exported table next to its JSON results file
+func exportFilename(resultsFile string, exporter metrics.Exporter) string {
+	return strings.TrimSuffix(resultsFile, filepath.Ext(resultsFile)) + exporter.Extension()
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
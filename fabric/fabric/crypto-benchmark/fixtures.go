@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto-benchmark/msp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// defaultFixtureMessage is the message signed by generated fixtures
+const defaultFixtureMessage = "Hyperledger Fabric reproducible signature fixture"
+
+// reproducibility is the key and signature configuration selected by --seed
+// and --signing
+type reproducibility struct {
+	seedHex string
+	signing msp.SigningMode
+	seeds   *msp.SeedSequence
+}
+
+// parseReproducibility interprets --seed and --signing. A seeded run signs
+// deterministically unless hedged signing is asked for, since hedged
+// signatures cannot be reproduced.
+func parseReproducibility(seedHex, signing string) (reproducibility, error) {
+	r := reproducibility{seedHex: seedHex, signing: msp.SigningHedged}
+	if seedHex != "" {
+		master, err := hex.DecodeString(seedHex)
+		if err != nil {
+			return r, fmt.Errorf("seed is not hex: %v", err)
+		}
+		if r.seeds, err = msp.NewSeedSequence(master); err != nil {
+			return r, err
+		}
+		r.signing = msp.SigningDeterministic
+	}
+
+	if signing != "" {
+		mode, err := msp.ParseSigningMode(signing)
+		if err != nil {
+			return r, err
+		}
+		r.signing = mode
+	}
+	return r, nil
+}
+
+// options returns the MSP options of a run
+func (r reproducibility) options() msp.Options {
+	return msp.Options{Seeds: r.seeds, Signing: r.signing}
+}
+
+// randomSeedHex returns a fresh 32-byte master seed in hex
+func randomSeedHex() (string, error) {
+	seed := make([]byte, msp.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(seed), nil
+}
+
+// fixture is a reproducible key pair and deterministic signature, with every
+// value hex-encoded so other implementations can consume it directly
+type fixture struct {
+	Algorithm   string `json:"algorithm"`
+	SigningMode string `json:"signing_mode"`
+	MasterSeed  string `json:"master_seed"`
+	KeySeed     string `json:"key_seed"`
+	PublicKey   string `json:"public_key"`
+	PrivateKey  string `json:"private_key"`
+	Message     string `json:"message"`
+	Digest      string `json:"digest"`
+	Signature   string `json:"signature"`
+}
+
+// newFixture derives the first key of an algorithm from the master seed and
+// signs message with it. The key seed is recorded so that an ML-DSA key can be
+// regenerated by any FIPS 204 implementation from ξ alone.
+func newFixture(algorithm msp.SignatureAlgorithm, masterSeed string, message []byte) (fixture, error) {
+	master, err := hex.DecodeString(masterSeed)
+	if err != nil {
+		return fixture{}, fmt.Errorf("master seed is not hex: %v", err)
+	}
+	seeds, err := msp.NewSeedSequence(master)
+	if err != nil {
+		return fixture{}, err
+	}
+	keySeed, err := seeds.Next(algorithm.String())
+	if err != nil {
+		return fixture{}, err
+	}
+
+	signer, err := msp.NewEnhancedMSPFromSeed(algorithm, keySeed, msp.SigningDeterministic)
+	if err != nil {
+		return fixture{}, err
+	}
+	publicKey, err := signer.GetPublicKeyBytes()
+	if err != nil {
+		return fixture{}, err
+	}
+	privateKey, err := signer.GetPrivateKeyBytes()
+	if err != nil {
+		return fixture{}, err
+	}
+	signature, err := signer.Sign(message)
+	if err != nil {
+		return fixture{}, err
+	}
+
+	// Confirm the signature against an independently imported public key
+	verifier, err := msp.NewEnhancedMSPFromPublicKey(algorithm, publicKey)
+	if err != nil {
+		return fixture{}, err
+	}
+	if valid, err := verifier.Verify(message, signature); err != nil || !valid {
+		return fixture{}, fmt.Errorf("fixture signature does not verify")
+	}
+
+	digest := sha256.Sum256(message)
+	return fixture{
+		Algorithm:   algorithm.String(),
+		SigningMode: msp.SigningDeterministic.String(),
+		MasterSeed:  masterSeed,
+		KeySeed:     hex.EncodeToString(keySeed),
+		PublicKey:   hex.EncodeToString(publicKey),
+		PrivateKey:  hex.EncodeToString(privateKey),
+		Message:     hex.EncodeToString(message),
+		Digest:      hex.EncodeToString(digest[:]),
+		Signature:   hex.EncodeToString(signature),
+	}, nil
+}
+
+// fixtureFile returns the path of an algorithm's fixture under dir
+func fixtureFile(dir string, algorithm msp.SignatureAlgorithm) string {
+	return filepath.Join(dir, algorithm.String()+".json")
+}
+
+// runFixtures implements the fixtures command and returns the process exit code
+func runFixtures(args []string) int {
+	fs := flag.NewFlagSet("fixtures", flag.ExitOnError)
+	seed := fs.String("seed", "", "Hex master seed, at least 16 bytes (default a fresh random seed)")
+	message := fs.String("message", defaultFixtureMessage, "Message to sign")
+	outputDir := fs.String("output", "results/fixtures", "Directory for the fixture files")
+	algorithmList := fs.String("algorithms", "ECDSA,ML-DSA-44,ML-DSA-65,ML-DSA-87", "Comma-separated algorithms")
+	check := fs.Bool("check", false, "Regenerate the fixtures in the output directory from their recorded seeds and compare them byte for byte")
+	fs.Parse(args)
+
+	var algorithms []msp.SignatureAlgorithm
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+
+	if *check {
+		return checkFixtures(*outputDir, algorithms)
+	}
+
+	if *seed == "" {
+		var err error
+		if *seed, err = randomSeedHex(); err != nil {
+			log.Fatalf("Failed to generate seed: %v", err)
+		}
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	fmt.Printf("Generating fixtures from master seed %s\n", *seed)
+	for _, algorithm := range algorithms {
+		f, err := newFixture(algorithm, *seed, []byte(*message))
+		if err != nil {
+			log.Fatalf("Failed to generate %s fixture: %v", algorithm.String(), err)
+		}
+		data, err := json.MarshalIndent(f, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode fixture: %v", err)
+		}
+		path := fixtureFile(*outputDir, algorithm)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			log.Fatalf("Failed to write fixture: %v", err)
+		}
+		fmt.Printf("  ✓ %-10s %s\n", algorithm.String(), path)
+	}
+	return 0
+}
+
+// checkFixtures regenerates each fixture from its master seed and message and
+// reports any field that differs from the stored file
+func checkFixtures(dir string, algorithms []msp.SignatureAlgorithm) int {
+	failed := false
+	for _, algorithm := range algorithms {
+		path := fixtureFile(dir, algorithm)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read fixture: %v", err)
+		}
+		var stored fixture
+		if err := json.Unmarshal(data, &stored); err != nil {
+			log.Fatalf("Failed to parse %s: %v", path, err)
+		}
+		message, err := hex.DecodeString(stored.Message)
+		if err != nil {
+			log.Fatalf("Failed to parse %s: message is not hex", path)
+		}
+
+		regenerated, err := newFixture(algorithm, stored.MasterSeed, message)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", algorithm.String(), err)
+			failed = true
+			continue
+		}
+		if diff := fixtureDiff(stored, regenerated); diff != "" {
+			fmt.Printf("✗ %s: %s differs from %s\n", algorithm.String(), diff, path)
+			failed = true
+			continue
+		}
+		fmt.Printf("✓ %s reproduces %s\n", algorithm.String(), path)
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// fixtureDiff returns the name of the first field that differs, or ""
+func fixtureDiff(a, b fixture) string {
+	fields := []struct {
+		name string
+		a, b string
+	}{
+		{"algorithm", a.Algorithm, b.Algorithm},
+		{"signing_mode", a.SigningMode, b.SigningMode},
+		{"key_seed", a.KeySeed, b.KeySeed},
+		{"public_key", a.PublicKey, b.PublicKey},
+		{"private_key", a.PrivateKey, b.PrivateKey},
+		{"digest", a.Digest, b.Digest},
+		{"signature", a.Signature, b.Signature},
+	}
+	for _, f := range fields {
+		if f.a != f.b {
+			return f.name
+		}
+	}
+	return ""
+}
@@ -1,7 +1,19 @@
 module crypto-benchmark
 
-go 1.19
+go 1.24.0
 
-require github.com/cloudflare/circl v1.3.6
+require (
+	github.com/cloudflare/circl v1.6.1
+	github.com/miekg/pkcs11 v1.1.2
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/trace v1.16.0
+	golang.org/x/crypto v0.45.0
+	golang.org/x/sys v0.38.0
+)
 
-require golang.org/x/sys v0.14.0 // indirect
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.16.0 // indirect
+)
@@ -0,0 +1,101 @@
+package hbs
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StateMetrics is the cost of keeping state for one scheme, store and
+// reservation size
+type StateMetrics struct {
+	Scheme              string  `json:"scheme"`
+	Store               string  `json:"store"`
+	Reserve             uint64  `json:"reserve"`
+	Signatures          int     `json:"signatures"`
+	StateUpdates        uint64  `json:"state_updates"`
+	SignTimeMs          float64 `json:"sign_time_ms"`            // Median, state update included
+	StateUpdateTimeMs   float64 `json:"state_update_time_ms"`    // Mean per update
+	StateUpdatePerSigMs float64 `json:"state_update_per_sig_ms"` // Update time amortised over every signature
+	StateUpdateSharePct float64 `json:"state_update_share_pct"`  // Share of the total signing time
+	SkippedOnRestart    uint64  `json:"skipped_on_restart"`      // Reserved indices a restart discards
+	KeygenTimeMs        float64 `json:"keygen_time_ms"`
+}
+
+// BenchmarkState signs with a fresh key through a store, then checks and
+// measures what the store guarantees: every signature verifies with a distinct
+// index, and a signer restarted on the same store continues after every index
+// reserved so far, skipping those the first signer reserved but did not use.
+func BenchmarkState(scheme Scheme, store StateStore, storeName string, reserve uint64, signatures int) (*StateMetrics, error) {
+	start := time.Now()
+	key, err := GenerateKey(scheme, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keygenTime := time.Since(start)
+
+	signer := NewSigner(key, store, reserve)
+	message := []byte("State update benchmark message")
+	signTimes := make([]time.Duration, signatures)
+	var total time.Duration
+	seen := make(map[uint64]bool)
+	for i := range signTimes {
+		start := time.Now()
+		signature, err := signer.Sign(message)
+		signTimes[i] = time.Since(start)
+		total += signTimes[i]
+		if err != nil {
+			return nil, fmt.Errorf("signature %d failed: %v", i, err)
+		}
+		if !scheme.Verify(key.PublicKey(), message, signature) {
+			return nil, fmt.Errorf("signature %d does not verify", i)
+		}
+		index, err := scheme.Index(signature)
+		if err != nil {
+			return nil, err
+		}
+		if seen[index] {
+			return nil, fmt.Errorf("index %d used twice", index)
+		}
+		seen[index] = true
+	}
+
+	used, err := store.Used(KeyID(key.PublicKey()))
+	if err != nil {
+		return nil, err
+	}
+	restarted := NewSigner(key, store, reserve)
+	signature, err := restarted.Sign(message)
+	if err != nil {
+		return nil, fmt.Errorf("signing after restart failed: %v", err)
+	}
+	index, err := scheme.Index(signature)
+	if err != nil {
+		return nil, err
+	}
+	if index != used || seen[index] {
+		return nil, fmt.Errorf("restarted signer used index %d, expected %d", index, used)
+	}
+
+	updates, updateTime := signer.StateUpdates()
+	sort.Slice(signTimes, func(i, j int) bool { return signTimes[i] < signTimes[j] })
+	metrics := &StateMetrics{
+		Scheme:           scheme.Name(),
+		Store:            storeName,
+		Reserve:          reserve,
+		Signatures:       signatures,
+		StateUpdates:     updates,
+		SignTimeMs:       float64(signTimes[signatures/2].Nanoseconds()) / 1e6,
+		SkippedOnRestart: used - uint64(signatures),
+		KeygenTimeMs:     float64(keygenTime.Nanoseconds()) / 1e6,
+	}
+	if updates > 0 {
+		metrics.StateUpdateTimeMs = float64(updateTime.Nanoseconds()) / 1e6 / float64(updates)
+	}
+	metrics.StateUpdatePerSigMs = float64(updateTime.Nanoseconds()) / 1e6 / float64(signatures)
+	if total > 0 {
+		metrics.StateUpdateSharePct = float64(updateTime) / float64(total) * 100
+	}
+	return metrics, nil
+}
@@ -0,0 +1,101 @@
+// Package hbs implements the stateful hash-based signature schemes of NIST SP
+// 800-208: LMS in its multi-tree HSS form (RFC 8554) and XMSS^MT (RFC 8391).
+// Their security rests only on the hash function, but each one-time key of a
+// tree must sign at most once: two signatures with the same index reveal
+// enough of that one-time key to forge. Private keys therefore only sign
+// through a Signer, which durably reserves every index in a StateStore before
+// it releases a signature.
+//
+// The encodings follow the RFC parameter tables, and the tests check the
+// schemes against the known-answer tests of RFC 8554 Appendix F and the XMSS
+// reference implementation once those are vendored into testdata. Until they
+// pass, and since the root secret values are derived from the seed by this
+// package's own method, the schemes are for benchmarking only, not for
+// long-lived keys such as an orderer or CA key.
+package hbs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SeedSize is the size of the seed every secret value of a private key is
+// derived from
+const SeedSize = 32
+
+// ErrKeyExhausted is returned once every one-time key of a private key is used
+var ErrKeyExhausted = errors.New("stateful key exhausted: every one-time key has been used")
+
+// ErrKeyDestroyed is returned when signing with a private key after Destroy
+var ErrKeyDestroyed = errors.New("stateful private key has been destroyed")
+
+// Scheme is a parameter set of a stateful hash-based signature scheme
+type Scheme interface {
+	// Name returns the parameter set name of RFC 8554 or RFC 8391
+	Name() string
+	// MaxSignatures returns how many signatures one private key can make
+	MaxSignatures() uint64
+	PublicKeySize() int
+	SignatureSize() int
+	// NewPrivateKey derives a private key from a SeedSize-byte seed. This
+	// builds the first tree of every level, which is the cost of key generation.
+	NewPrivateKey(seed []byte) (PrivateKey, error)
+	// CheckPublicKey rejects encodings that are not public keys of the scheme
+	CheckPublicKey(publicKey []byte) error
+	// Verify reports whether signature is a valid signature of message
+	Verify(publicKey, message, signature []byte) bool
+	// Index returns the one-time key index a signature was made with, so that
+	// an auditor can check that no index was used twice
+	Index(signature []byte) (uint64, error)
+}
+
+// PrivateKey is a stateful hash-based private key. It holds no state itself:
+// signAt signs with any index it is given, so that the only way to sign is
+// through a Signer.
+type PrivateKey interface {
+	Scheme() Scheme
+	PublicKey() []byte
+	// Seed returns the encoded private key, from which NewPrivateKey rebuilds it
+	Seed() []byte
+	// Destroy overwrites the seed and every secret derived from it; the key
+	// cannot sign afterwards
+	Destroy()
+	signAt(index uint64, message []byte) ([]byte, error)
+}
+
+// GenerateKey creates a private key from a fresh seed read from random
+func GenerateKey(scheme Scheme, random io.Reader) (PrivateKey, error) {
+	seed := make([]byte, SeedSize)
+	if _, err := io.ReadFull(random, seed); err != nil {
+		return nil, err
+	}
+	return scheme.NewPrivateKey(seed)
+}
+
+// KeyID identifies a key in a StateStore by the SHA-256 of its public key
+func KeyID(publicKey []byte) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:])
+}
+
+// Parameter sets offered as MSP signature algorithms. Both can make 2^20
+// signatures with two levels of height-10 trees so that key
+// generation and each tree switch build 1024 leaves rather than a million.
+var (
+	// HSSSHA256H10L2 is HSS with two levels of LMS_SHA256_M32_H10 and
+	// LMOTS_SHA256_N32_W4
+	HSSSHA256H10L2 = mustHSS(2, LMSSHA256M32H10, LMOTSSHA256N32W4)
+	// XMSSMTSHA2H20D2 is XMSSMT-SHA2_20/2_256
+	XMSSMTSHA2H20D2 = mustXMSSMT(XMSSMTSHA2_20_2_256)
+)
+
+// checkSeed rejects seeds of the wrong size
+func checkSeed(seed []byte) error {
+	if len(seed) != SeedSize {
+		return fmt.Errorf("seed is %d bytes, expected %d", len(seed), SeedSize)
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package hbs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// These tests check the encodings against the parameter tables and formats
+// of RFC 8554 and RFC 8391, and that keys sign and verify across tree
+// boundaries. The known-answer tests of RFC 8554 Appendix F and of the XMSS
+// reference implementation are in kat_test.go.
+
+// testSeed returns a fixed seed filled with b
+func testSeed(b byte) []byte {
+	return bytes.Repeat([]byte{b}, SeedSize)
+}
+
+// TestLMOTSParameterSets checks p, ls and the signature size against RFC
+// 8554 Table 1
+func TestLMOTSParameterSets(t *testing.T) {
+	tests := []struct {
+		otsType LMOTSType
+		name    string
+		p, ls   int
+		sigLen  int
+	}{
+		{LMOTSSHA256N32W1, "LMOTS_SHA256_N32_W1", 265, 7, 8516},
+		{LMOTSSHA256N32W2, "LMOTS_SHA256_N32_W2", 133, 6, 4292},
+		{LMOTSSHA256N32W4, "LMOTS_SHA256_N32_W4", 67, 4, 2180},
+		{LMOTSSHA256N32W8, "LMOTS_SHA256_N32_W8", 34, 0, 1124},
+	}
+	for _, tt := range tests {
+		params, ok := tt.otsType.params()
+		if !ok || params.p != tt.p || params.ls != tt.ls {
+			t.Errorf("%s: p = %d, ls = %d, expected %d and %d", tt.name, params.p, params.ls, tt.p, tt.ls)
+		}
+		if name := tt.otsType.String(); name != tt.name {
+			t.Errorf("type %d is named %s, expected %s", tt.otsType, name, tt.name)
+		}
+		scheme, err := NewHSS(1, LMSSHA256M32H5, tt.otsType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := scheme.(*hssScheme).otsSignatureSize(); size != tt.sigLen {
+			t.Errorf("%s signature is %d bytes, expected %d", tt.name, size, tt.sigLen)
+		}
+	}
+	if _, ok := LMOTSType(5).params(); ok {
+		t.Error("unassigned LM-OTS type accepted")
+	}
+}
+
+// TestLMSParameterSets checks the tree heights of RFC 8554 Table 2 and the
+// signature and public key sizes of Sections 5.3 and 5.4
+func TestLMSParameterSets(t *testing.T) {
+	tests := []struct {
+		lmsType LMSType
+		name    string
+		height  int
+	}{
+		{LMSSHA256M32H5, "LMS_SHA256_M32_H5", 5},
+		{LMSSHA256M32H10, "LMS_SHA256_M32_H10", 10},
+		{LMSSHA256M32H15, "LMS_SHA256_M32_H15", 15},
+		{LMSSHA256M32H20, "LMS_SHA256_M32_H20", 20},
+		{LMSSHA256M32H25, "LMS_SHA256_M32_H25", 25},
+	}
+	for _, tt := range tests {
+		if height, ok := tt.lmsType.height(); !ok || height != tt.height {
+			t.Errorf("%s: height %d, expected %d", tt.name, height, tt.height)
+		}
+		if name := tt.lmsType.String(); name != tt.name {
+			t.Errorf("type %d is named %s, expected %s", tt.lmsType, name, tt.name)
+		}
+	}
+
+	s := HSSSHA256H10L2.(*hssScheme)
+	if size := s.lmsSignatureSize(); size != 2508 {
+		t.Errorf("LMS signature is %d bytes, expected 2508", size)
+	}
+	if HSSSHA256H10L2.PublicKeySize() != 60 {
+		t.Errorf("HSS public key is %d bytes, expected 60", HSSSHA256H10L2.PublicKeySize())
+	}
+	// Nspk, one signed public key and the bottom LMS signature
+	if HSSSHA256H10L2.SignatureSize() != 4+2508+56+2508 {
+		t.Errorf("HSS signature is %d bytes, expected %d", HSSSHA256H10L2.SignatureSize(), 4+2508+56+2508)
+	}
+	if HSSSHA256H10L2.MaxSignatures() != 1<<20 {
+		t.Errorf("HSS key makes %d signatures, expected 2^20", HSSSHA256H10L2.MaxSignatures())
+	}
+}
+
+// TestXMSSMTParameterSets checks the OIDs and the signature sizes of RFC 8391
+// Section 5.4, ceil(h/8) + n + (h + d*len)*n bytes
+func TestXMSSMTParameterSets(t *testing.T) {
+	tests := []struct {
+		oid       XMSSMTOID
+		name      string
+		sigLen    int
+		supported bool
+	}{
+		{XMSSMTSHA2_20_2_256, "XMSSMT-SHA2_20/2_256", 4963, true},
+		{XMSSMTSHA2_20_4_256, "XMSSMT-SHA2_20/4_256", 9251, true},
+		{XMSSMTSHA2_40_2_256, "XMSSMT-SHA2_40/2_256", 5605, false},
+		{XMSSMTSHA2_40_4_256, "XMSSMT-SHA2_40/4_256", 9893, true},
+		{XMSSMTSHA2_40_8_256, "XMSSMT-SHA2_40/8_256", 18469, true},
+		{XMSSMTSHA2_60_3_256, "XMSSMT-SHA2_60/3_256", 8392, false},
+		{XMSSMTSHA2_60_6_256, "XMSSMT-SHA2_60/6_256", 14824, true},
+		{XMSSMTSHA2_60_12_256, "XMSSMT-SHA2_60/12_256", 27688, true},
+	}
+	for _, tt := range tests {
+		if name := tt.oid.String(); name != tt.name {
+			t.Errorf("OID %d is named %s, expected %s", tt.oid, name, tt.name)
+		}
+		scheme, err := NewXMSSMT(tt.oid)
+		if !tt.supported {
+			if err == nil {
+				t.Errorf("%s accepted, but its trees are higher than %d", tt.name, maxXMSSTreeHeight)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if scheme.PublicKeySize() != 68 {
+			t.Errorf("%s public key is %d bytes, expected 68", tt.name, scheme.PublicKeySize())
+		}
+		if scheme.SignatureSize() != tt.sigLen {
+			t.Errorf("%s signature is %d bytes, expected %d", tt.name, scheme.SignatureSize(), tt.sigLen)
+		}
+	}
+	if _, err := NewXMSSMT(0); err == nil {
+		t.Error("reserved OID accepted")
+	}
+}
+
+// TestHSSEncoding checks the fields of an HSS public key and signature of
+// RFC 8554 Sections 6.1 and 6.2
+func TestHSSEncoding(t *testing.T) {
+	key, err := HSSSHA256H10L2.NewPrivateKey(testSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := key.PublicKey()
+	if err := HSSSHA256H10L2.CheckPublicKey(publicKey); err != nil {
+		t.Fatal(err)
+	}
+	// u32str(L) || u32str(lms_type) || u32str(otstype) || I || T[1]
+	if l := binary.BigEndian.Uint32(publicKey); l != 2 {
+		t.Errorf("public key has L = %d, expected 2", l)
+	}
+	checkLMSTypes(t, "public key", publicKey[4:])
+
+	const index = 1<<10 + 7
+	message := []byte("HSS encoding")
+	signature, err := key.signAt(index, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signature) != HSSSHA256H10L2.SignatureSize() {
+		t.Fatalf("signature is %d bytes, expected %d", len(signature), HSSSHA256H10L2.SignatureSize())
+	}
+	// u32str(Nspk) || signed_pub_key[0] || sig[Nspk]
+	if nspk := binary.BigEndian.Uint32(signature); nspk != 1 {
+		t.Errorf("signature has Nspk = %d, expected 1", nspk)
+	}
+	top := signature[4:]
+	child := top[2508 : 2508+56]
+	bottom := top[2508+56:]
+	// Each LMS signature is u32str(q) || lmots_signature || u32str(lms_type) || path,
+	// and an LM-OTS signature starts with its type
+	for _, lms := range []struct {
+		name string
+		sig  []byte
+		q    uint32
+	}{{"top", top, 1}, {"bottom", bottom, 7}} {
+		if q := binary.BigEndian.Uint32(lms.sig); q != lms.q {
+			t.Errorf("%s signature has q = %d, expected %d", lms.name, q, lms.q)
+		}
+		if otsType := LMOTSType(binary.BigEndian.Uint32(lms.sig[4:])); otsType != LMOTSSHA256N32W4 {
+			t.Errorf("%s LM-OTS signature has type %d", lms.name, otsType)
+		}
+		if lmsType := LMSType(binary.BigEndian.Uint32(lms.sig[4+2180:])); lmsType != LMSSHA256M32H10 {
+			t.Errorf("%s LMS signature has type %d", lms.name, lmsType)
+		}
+	}
+	checkLMSTypes(t, "signed public key", child)
+	if bytes.Equal(child[8:24], publicKey[12:28]) {
+		t.Error("child tree reuses the root tree's identifier I")
+	}
+	if got, err := HSSSHA256H10L2.Index(signature); err != nil || got != index {
+		t.Errorf("Index = %d, %v, expected %d", got, err, index)
+	}
+	if !HSSSHA256H10L2.Verify(publicKey, message, signature) {
+		t.Error("signature does not verify")
+	}
+}
+
+// checkLMSTypes checks the type fields of an LMS public key
+func checkLMSTypes(t *testing.T, name string, publicKey []byte) {
+	t.Helper()
+	if lmsType := LMSType(binary.BigEndian.Uint32(publicKey)); lmsType != LMSSHA256M32H10 {
+		t.Errorf("%s has LMS type %d, expected %d", name, lmsType, LMSSHA256M32H10)
+	}
+	if otsType := LMOTSType(binary.BigEndian.Uint32(publicKey[4:])); otsType != LMOTSSHA256N32W4 {
+		t.Errorf("%s has LM-OTS type %d, expected %d", name, otsType, LMOTSSHA256N32W4)
+	}
+}
+
+// TestXMSSMTEncoding checks the fields of an XMSS^MT public key and
+// signature of RFC 8391 Sections 4.2.3 and 4.2.5
+func TestXMSSMTEncoding(t *testing.T) {
+	key, err := XMSSMTSHA2H20D2.NewPrivateKey(testSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// OID || root || SEED
+	publicKey := key.PublicKey()
+	if oid := XMSSMTOID(binary.BigEndian.Uint32(publicKey)); oid != XMSSMTSHA2_20_2_256 {
+		t.Errorf("public key has OID %d, expected %d", oid, XMSSMTSHA2_20_2_256)
+	}
+
+	const index = 0x0a0b0c
+	message := []byte("XMSS^MT encoding")
+	signature, err := key.signAt(index, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// idx_sig is ceil(h/8) = 3 bytes
+	if !bytes.Equal(signature[:3], []byte{0x0a, 0x0b, 0x0c}) {
+		t.Errorf("signature starts with idx %x, expected 0a0b0c", signature[:3])
+	}
+	if got, err := XMSSMTSHA2H20D2.Index(signature); err != nil || got != index {
+		t.Errorf("Index = %d, %v, expected %d", got, err, index)
+	}
+	if !XMSSMTSHA2H20D2.Verify(publicKey, message, signature) {
+		t.Error("signature does not verify")
+	}
+}
+
+// TestKeyGenerationIsDeterministic checks that a seed always gives the same
+// key, and that Seed rebuilds it
+func TestKeyGenerationIsDeterministic(t *testing.T) {
+	for _, scheme := range []Scheme{HSSSHA256H10L2, XMSSMTSHA2H20D2} {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			key, err := scheme.NewPrivateKey(testSeed(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := scheme.NewPrivateKey(key.Seed())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(key.PublicKey(), again.PublicKey()) {
+				t.Error("the same seed gives different public keys")
+			}
+			other, err := scheme.NewPrivateKey(testSeed(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(key.PublicKey(), other.PublicKey()) {
+				t.Error("different seeds give the same public key")
+			}
+			if _, err := scheme.NewPrivateKey(testSeed(3)[1:]); err == nil {
+				t.Error("short seed accepted")
+			}
+		})
+	}
+}
+
+// TestTreeBoundaries signs with the last and first one-time keys of adjacent
+// bottom trees, with small trees so that every layer is crossed
+func TestTreeBoundaries(t *testing.T) {
+	hss, err := NewHSS(3, LMSSHA256M32H5, LMOTSSHA256N32W8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmss, err := NewXMSSMT(XMSSMTSHA2_20_4_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, scheme := range []Scheme{hss, xmss} {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			key, err := scheme.NewPrivateKey(testSeed(5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			message := []byte("tree boundary")
+			for _, index := range []uint64{0, 31, 32, 1<<10 - 1, 1 << 10, scheme.MaxSignatures() - 1} {
+				signature, err := key.signAt(index, message)
+				if err != nil {
+					t.Fatalf("index %d: %v", index, err)
+				}
+				if got, err := scheme.Index(signature); err != nil || got != index {
+					t.Errorf("index %d: Index = %d, %v", index, got, err)
+				}
+				if !scheme.Verify(key.PublicKey(), message, signature) {
+					t.Errorf("index %d: signature does not verify", index)
+				}
+			}
+			if _, err := key.signAt(scheme.MaxSignatures(), message); err != ErrKeyExhausted {
+				t.Errorf("signing past the last index: %v", err)
+			}
+		})
+	}
+}
+
+// TestVerifyRejectsModifications flips one bit in every 32-byte block of a
+// signature and of the public key, and verifies another message
+func TestVerifyRejectsModifications(t *testing.T) {
+	for _, scheme := range []Scheme{HSSSHA256H10L2, XMSSMTSHA2H20D2} {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			key, err := scheme.NewPrivateKey(testSeed(6))
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer := NewSigner(key, NewMemoryStateStore(), 1)
+			message := []byte("modified signatures")
+			signature, err := signer.Sign(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			publicKey := key.PublicKey()
+			if !scheme.Verify(publicKey, message, signature) {
+				t.Fatal("signature does not verify")
+			}
+			if scheme.Verify(publicKey, []byte("another message"), signature) {
+				t.Error("signature verifies another message")
+			}
+			for i := 0; i < len(signature); i += 32 {
+				modified := bytes.Clone(signature)
+				modified[i] ^= 1
+				if scheme.Verify(publicKey, message, modified) {
+					t.Errorf("signature with byte %d flipped verifies", i)
+				}
+			}
+			for i := range publicKey {
+				modified := bytes.Clone(publicKey)
+				modified[i] ^= 1
+				if scheme.Verify(modified, message, signature) {
+					t.Errorf("public key with byte %d flipped verifies", i)
+				}
+			}
+			if scheme.Verify(publicKey, message, signature[:len(signature)-1]) {
+				t.Error("truncated signature verifies")
+			}
+		})
+	}
+}
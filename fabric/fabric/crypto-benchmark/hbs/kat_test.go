@@ -0,0 +1,229 @@
+package hbs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The known-answer tests below read the published vectors from testdata, as
+// JSON objects of hex strings:
+//
+//	rfc8554-tc1.json  RFC 8554 Appendix F Test Case 1: publicKey, message, signature
+//	rfc8554-tc2.json  Test Case 2, plus bottomSeed and bottomI, the SEED and I
+//	                  of its bottom tree
+//	xmssmt-*.json     XMSS^MT signatures of the reference implementation: oid
+//	                  (a number), publicKey, message, signature
+//
+// A test whose file is missing is skipped, and the schemes are only fit for
+// benchmarking until every one of them passes.
+
+// katVector is one known-answer test read from testdata
+type katVector struct {
+	OID        XMSSMTOID `json:"oid"`
+	PublicKey  katHex    `json:"publicKey"`
+	Message    katHex    `json:"message"`
+	Signature  katHex    `json:"signature"`
+	BottomSeed katHex    `json:"bottomSeed"`
+	BottomI    katHex    `json:"bottomI"`
+}
+
+// katHex is a byte string encoded in hex in a vector file
+type katHex []byte
+
+func (h *katHex) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	*h = decoded
+	return err
+}
+
+// loadKAT reads a vector file, skipping the test when it is not vendored
+func loadKAT(t *testing.T, path string) katVector {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skipf("%s is not vendored", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v katVector
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return v
+}
+
+// Test Case 1 has two levels of LMS_SHA256_M32_H5 with LMOTS_SHA256_N32_W8.
+// Test Case 2 has LMS_SHA256_M32_H5 with LMOTS_SHA256_N32_W8 on top and
+// LMS_SHA256_M32_H10 with LMOTS_SHA256_N32_W4 below, which NewHSS cannot
+// express, so each of its levels is checked with its own one-level scheme.
+var (
+	rfc8554TC1       = mustHSS(2, LMSSHA256M32H5, LMOTSSHA256N32W8)
+	rfc8554TC2Top    = mustHSS(1, LMSSHA256M32H5, LMOTSSHA256N32W8).(*hssScheme)
+	rfc8554TC2Bottom = mustHSS(1, LMSSHA256M32H10, LMOTSSHA256N32W4).(*hssScheme)
+)
+
+// checkRFC8554TC1 verifies the signature of Test Case 1
+func checkRFC8554TC1(v katVector) error {
+	if !rfc8554TC1.Verify(v.PublicKey, v.Message, v.Signature) {
+		return errors.New("signature does not verify")
+	}
+	return nil
+}
+
+// checkRFC8554TC2 verifies both levels of the signature of Test Case 2, then
+// rebuilds the bottom tree from its SEED and I and signs the message again
+// with the published leaf and randomizer C, which must give the published
+// bottom signature byte for byte
+func checkRFC8554TC2(v katVector) error {
+	top, bottom := rfc8554TC2Top, rfc8554TC2Bottom
+	if len(v.PublicKey) != 4+lmsPublicKey || binary.BigEndian.Uint32(v.PublicKey) != 2 {
+		return errors.New("public key is not a two-level HSS public key")
+	}
+	topSize, bottomSize := top.lmsSignatureSize(), bottom.lmsSignatureSize()
+	if len(v.Signature) != 4+topSize+lmsPublicKey+bottomSize || binary.BigEndian.Uint32(v.Signature) != 1 {
+		return fmt.Errorf("signature is %d bytes, expected a two-level HSS signature of %d", len(v.Signature), 4+topSize+lmsPublicKey+bottomSize)
+	}
+	topSignature := v.Signature[4 : 4+topSize]
+	child := v.Signature[4+topSize : 4+topSize+lmsPublicKey]
+	bottomSignature := v.Signature[4+topSize+lmsPublicKey:]
+	if !top.verifyLMS(v.PublicKey[4:], child, topSignature) {
+		return errors.New("top tree's signature of the bottom public key does not verify")
+	}
+	if !bottom.verifyLMS(child, v.Message, bottomSignature) {
+		return errors.New("bottom tree's signature of the message does not verify")
+	}
+
+	if len(v.BottomSeed) != lmsN || len(v.BottomI) != lmsIDSize {
+		return errors.New("bottom tree's SEED or I is missing")
+	}
+	tree := newLMSTree(bottom, 0, [lmsIDSize]byte(v.BottomI), [lmsN]byte(v.BottomSeed))
+	if !bytes.Equal(tree.publicKey(), child) {
+		return errors.New("bottom tree rebuilt from SEED and I has a different public key")
+	}
+	q := binary.BigEndian.Uint32(bottomSignature)
+	c := [lmsN]byte(bottomSignature[8 : 8+lmsN])
+	if !bytes.Equal(tree.signWith(q, c, v.Message), bottomSignature) {
+		return errors.New("bottom tree's signature differs from the published one")
+	}
+	return nil
+}
+
+// checkXMSSMT verifies the signature of an XMSS^MT vector
+func checkXMSSMT(v katVector) error {
+	scheme, err := NewXMSSMT(v.OID)
+	if err != nil {
+		return err
+	}
+	if !scheme.Verify(v.PublicKey, v.Message, v.Signature) {
+		return errors.New("signature does not verify")
+	}
+	return nil
+}
+
+func TestRFC8554TestCase1(t *testing.T) {
+	if err := checkRFC8554TC1(loadKAT(t, filepath.Join("testdata", "rfc8554-tc1.json"))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRFC8554TestCase2(t *testing.T) {
+	if err := checkRFC8554TC2(loadKAT(t, filepath.Join("testdata", "rfc8554-tc2.json"))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestXMSSMTReferenceVectors(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "xmssmt-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Skip("no XMSS^MT vectors are vendored")
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			if err := checkXMSSMT(loadKAT(t, path)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestKATChecks runs the checks on vectors of this package's own keys, shaped
+// like the published ones, so that they are known to pass correct vectors and
+// fail altered ones while the published vectors are not vendored
+func TestKATChecks(t *testing.T) {
+	message := []byte("known-answer test check")
+
+	key, err := rfc8554TC1.NewPrivateKey(testSeed(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := key.(*hssPrivateKey).signAt(37, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc1 := katVector{PublicKey: key.PublicKey(), Message: message, Signature: signature}
+
+	// The two trees of Test Case 2 are built and linked by hand
+	topTree := newLMSTree(rfc8554TC2Top, 0, [lmsIDSize]byte(testSeed(5)), [lmsN]byte(testSeed(6)))
+	bottomI, bottomSeed := testSeed(7)[:lmsIDSize], testSeed(8)
+	bottomTree := newLMSTree(rfc8554TC2Bottom, 0, [lmsIDSize]byte(bottomI), [lmsN]byte(bottomSeed))
+	tc2 := katVector{
+		PublicKey:  append([]byte{0, 0, 0, 2}, topTree.publicKey()...),
+		Message:    message,
+		Signature:  []byte{0, 0, 0, 1},
+		BottomSeed: bottomSeed,
+		BottomI:    bottomI,
+	}
+	tc2.Signature = append(tc2.Signature, topTree.sign(0, bottomTree.publicKey())...)
+	tc2.Signature = append(tc2.Signature, bottomTree.publicKey()...)
+	tc2.Signature = append(tc2.Signature, bottomTree.sign(4, message)...)
+
+	key, err = XMSSMTSHA2H20D2.NewPrivateKey(testSeed(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err = key.(*xmssmtPrivateKey).signAt(1<<10+3, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmssmt := katVector{OID: XMSSMTSHA2_20_2_256, PublicKey: key.PublicKey(), Message: message, Signature: signature}
+
+	tests := []struct {
+		name  string
+		check func(katVector) error
+		v     katVector
+		alter func(v *katVector)
+	}{
+		{"TC1 signature", checkRFC8554TC1, tc1, func(v *katVector) { v.Signature[len(v.Signature)-1] ^= 1 }},
+		{"TC2 signature", checkRFC8554TC2, tc2, func(v *katVector) { v.Signature[len(v.Signature)-1] ^= 1 }},
+		// A different SEED gives a different bottom tree
+		{"TC2 SEED", checkRFC8554TC2, tc2, func(v *katVector) { v.BottomSeed[0] ^= 1 }},
+		{"XMSS^MT signature", checkXMSSMT, xmssmt, func(v *katVector) { v.Signature[len(v.Signature)-1] ^= 1 }},
+	}
+	for _, tt := range tests {
+		if err := tt.check(tt.v); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		altered := tt.v
+		altered.Signature = bytes.Clone(tt.v.Signature)
+		altered.BottomSeed = bytes.Clone(tt.v.BottomSeed)
+		tt.alter(&altered)
+		if tt.check(altered) == nil {
+			t.Errorf("%s: altered vector passes", tt.name)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+package hbs
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// LMOTSType is an LM-OTS parameter set of RFC 8554 Section 4.1
+type LMOTSType uint32
+
+const (
+	LMOTSSHA256N32W1 LMOTSType = 1
+	LMOTSSHA256N32W2 LMOTSType = 2
+	LMOTSSHA256N32W4 LMOTSType = 3
+	LMOTSSHA256N32W8 LMOTSType = 4
+)
+
+// String returns the RFC 8554 name of the parameter set
+func (t LMOTSType) String() string {
+	if p, ok := t.params(); ok {
+		return fmt.Sprintf("LMOTS_SHA256_N32_W%d", p.w)
+	}
+	return "Unknown"
+}
+
+// lmotsParams are the Winternitz width w, the number of chains p and the
+// checksum shift ls of an LM-OTS parameter set
+type lmotsParams struct {
+	w, p, ls int
+}
+
+func (t LMOTSType) params() (lmotsParams, bool) {
+	switch t {
+	case LMOTSSHA256N32W1:
+		return lmotsParams{w: 1, p: 265, ls: 7}, true
+	case LMOTSSHA256N32W2:
+		return lmotsParams{w: 2, p: 133, ls: 6}, true
+	case LMOTSSHA256N32W4:
+		return lmotsParams{w: 4, p: 67, ls: 4}, true
+	case LMOTSSHA256N32W8:
+		return lmotsParams{w: 8, p: 34, ls: 0}, true
+	default:
+		return lmotsParams{}, false
+	}
+}
+
+// LMSType is an LMS parameter set of RFC 8554 Section 5.1
+type LMSType uint32
+
+const (
+	LMSSHA256M32H5  LMSType = 5
+	LMSSHA256M32H10 LMSType = 6
+	LMSSHA256M32H15 LMSType = 7
+	LMSSHA256M32H20 LMSType = 8
+	LMSSHA256M32H25 LMSType = 9
+)
+
+// String returns the RFC 8554 name of the parameter set
+func (t LMSType) String() string {
+	if h, ok := t.height(); ok {
+		return fmt.Sprintf("LMS_SHA256_M32_H%d", h)
+	}
+	return "Unknown"
+}
+
+func (t LMSType) height() (int, bool) {
+	switch t {
+	case LMSSHA256M32H5:
+		return 5, true
+	case LMSSHA256M32H10:
+		return 10, true
+	case LMSSHA256M32H15:
+		return 15, true
+	case LMSSHA256M32H20:
+		return 20, true
+	case LMSSHA256M32H25:
+		return 25, true
+	default:
+		return 0, false
+	}
+}
+
+const (
+	lmsN         = 32 // n and m of the SHA-256/256 parameter sets
+	lmsIDSize    = 16 // Size of the key pair identifier I
+	lmsPublicKey = 4 + 4 + lmsIDSize + lmsN
+
+	// Domain separation values of RFC 8554
+	dPBLC = 0x8080
+	dMESG = 0x8181
+	dLEAF = 0x8282
+	dINTR = 0x8383
+
+	// Secret values are derived as in RFC 8554 Appendix A, as
+	// H(I || u32str(q) || u16str(i) || u8str(0xff) || SEED). The one-time key
+	// chains use i < p; these values of i derive the rest, as the reference
+	// implementation does.
+	dRandomizer = 0xfffd
+	dChildSeed  = 0xfffe
+	dChildI     = 0xffff
+
+	// maxLMSHeight bounds the trees NewHSS accepts, since every node of the
+	// current tree of each level is kept in memory
+	maxLMSHeight = 15
+)
+
+// hssScheme is HSS with the same LMS and LM-OTS parameter set on every level
+type hssScheme struct {
+	levels  int
+	lmsType LMSType
+	otsType LMOTSType
+	height  int
+	ots     lmotsParams
+}
+
+// NewHSS returns the HSS scheme with the given number of levels, each an LMS
+// tree of lmsType with one-time keys of otsType. Trees higher than 15 are not
+// supported.
+func NewHSS(levels int, lmsType LMSType, otsType LMOTSType) (Scheme, error) {
+	height, ok := lmsType.height()
+	if !ok {
+		return nil, fmt.Errorf("unknown LMS type: %d", lmsType)
+	}
+	ots, ok := otsType.params()
+	if !ok {
+		return nil, fmt.Errorf("unknown LM-OTS type: %d", otsType)
+	}
+	if height > maxLMSHeight {
+		return nil, fmt.Errorf("%s is not supported: trees are held in memory, at most height %d", lmsType, maxLMSHeight)
+	}
+	if levels < 1 || levels > 8 || levels*height > 63 {
+		return nil, fmt.Errorf("unsupported number of HSS levels: %d", levels)
+	}
+	return &hssScheme{levels: levels, lmsType: lmsType, otsType: otsType, height: height, ots: ots}, nil
+}
+
+func mustHSS(levels int, lmsType LMSType, otsType LMOTSType) Scheme {
+	scheme, err := NewHSS(levels, lmsType, otsType)
+	if err != nil {
+		panic(err)
+	}
+	return scheme
+}
+
+func (s *hssScheme) Name() string {
+	return fmt.Sprintf("HSS-L%d/%s/%s", s.levels, s.lmsType, s.otsType)
+}
+
+func (s *hssScheme) MaxSignatures() uint64 { return 1 << (s.levels * s.height) }
+func (s *hssScheme) PublicKeySize() int    { return 4 + lmsPublicKey }
+
+func (s *hssScheme) SignatureSize() int {
+	return 4 + (s.levels-1)*(s.lmsSignatureSize()+lmsPublicKey) + s.lmsSignatureSize()
+}
+
+func (s *hssScheme) otsSignatureSize() int { return 4 + lmsN + s.ots.p*lmsN }
+func (s *hssScheme) lmsSignatureSize() int { return 4 + s.otsSignatureSize() + 4 + s.height*lmsN }
+
+// hssPrivateKey caches the current tree of every level and the signed public
+// keys linking them, so that a signature only builds a tree when the index
+// crosses into the next tree of a level
+type hssPrivateKey struct {
+	scheme    *hssScheme
+	seed      []byte
+	publicKey []byte
+
+	mu         sync.Mutex
+	trees      []*lmsTree
+	signedKeys [][]byte // signedKeys[i] is trees[i]'s signature of trees[i+1]'s public key, then that key
+}
+
+// NewPrivateKey derives the root tree's I and SEED from the seed with HKDF
+func (s *hssScheme) NewPrivateKey(seed []byte) (PrivateKey, error) {
+	if err := checkSeed(seed); err != nil {
+		return nil, err
+	}
+	id, err := hkdf.Key(sha256.New, seed, nil, "crypto-benchmark HSS I", lmsIDSize)
+	if err != nil {
+		return nil, err
+	}
+	treeSeed, err := hkdf.Key(sha256.New, seed, nil, "crypto-benchmark HSS SEED", lmsN)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(treeSeed)
+
+	key := &hssPrivateKey{
+		scheme:     s,
+		seed:       append([]byte(nil), seed...),
+		trees:      make([]*lmsTree, s.levels),
+		signedKeys: make([][]byte, s.levels-1),
+	}
+	key.trees[0] = newLMSTree(s, 0, [lmsIDSize]byte(id), [lmsN]byte(treeSeed))
+	for i := 1; i < s.levels; i++ {
+		key.descend(i, 0)
+	}
+
+	key.publicKey = binary.BigEndian.AppendUint32(nil, uint32(s.levels))
+	key.publicKey = append(key.publicKey, key.trees[0].publicKey()...)
+	return key, nil
+}
+
+func (k *hssPrivateKey) Scheme() Scheme    { return k.scheme }
+func (k *hssPrivateKey) PublicKey() []byte { return append([]byte(nil), k.publicKey...) }
+func (k *hssPrivateKey) Seed() []byte      { return append([]byte(nil), k.seed...) }
+
+// Destroy overwrites the seed and the SEED of every current tree, from which
+// all one-time keys are derived
+func (k *hssPrivateKey) Destroy() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	clear(k.seed)
+	k.seed = nil
+	for _, t := range k.trees {
+		t.seed = [lmsN]byte{}
+	}
+	k.trees = nil
+	k.signedKeys = nil
+}
+
+// descend replaces the tree of a level with the tree at index among that
+// level's trees, signed by the one-time key of its parent that index selects
+func (k *hssPrivateKey) descend(level int, index uint64) {
+	parent := k.trees[level-1]
+	q := uint32(index & (1<<k.scheme.height - 1))
+	child := parent.child(q, index)
+	publicKey := child.publicKey()
+	k.trees[level] = child
+	k.signedKeys[level-1] = append(parent.sign(q, publicKey), publicKey...)
+}
+
+// signAt produces the HSS signature with the index-th one-time key of the
+// bottom level. Each parent one-time key signs only its child's public key,
+// and deterministically, so rebuilding a key after a restart re-signs the same
+// child with the same signature rather than reusing the one-time key.
+func (k *hssPrivateKey) signAt(index uint64, message []byte) ([]byte, error) {
+	s := k.scheme
+	if index >= s.MaxSignatures() {
+		return nil, ErrKeyExhausted
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.trees == nil {
+		return nil, ErrKeyDestroyed
+	}
+
+	for level := 1; level < s.levels; level++ {
+		treeIndex := index >> (s.height * (s.levels - level))
+		if k.trees[level].index != treeIndex {
+			k.descend(level, treeIndex)
+		}
+	}
+
+	signature := make([]byte, 0, s.SignatureSize())
+	signature = binary.BigEndian.AppendUint32(signature, uint32(s.levels-1))
+	for _, signedKey := range k.signedKeys {
+		signature = append(signature, signedKey...)
+	}
+	q := uint32(index & (1<<s.height - 1))
+	return append(signature, k.trees[s.levels-1].sign(q, message)...), nil
+}
+
+// CheckPublicKey checks the size and the parameter sets of an HSS public key
+func (s *hssScheme) CheckPublicKey(publicKey []byte) error {
+	if len(publicKey) != s.PublicKeySize() {
+		return fmt.Errorf("%s public key is %d bytes, expected %d", s.Name(), len(publicKey), s.PublicKeySize())
+	}
+	if binary.BigEndian.Uint32(publicKey) != uint32(s.levels) {
+		return fmt.Errorf("HSS public key has %d levels, expected %d", binary.BigEndian.Uint32(publicKey), s.levels)
+	}
+	if _, _, ok := s.parseLMSPublicKey(publicKey[4:]); !ok {
+		return fmt.Errorf("public key is not %s with %s", s.lmsType, s.otsType)
+	}
+	return nil
+}
+
+// Verify checks every signed public key down the levels, then the signature of
+// the message by the bottom tree. Every level must use the scheme's parameter
+// sets, so a signature of another parameter set is rejected by its size or type.
+func (s *hssScheme) Verify(publicKey, message, signature []byte) bool {
+	if s.CheckPublicKey(publicKey) != nil || len(signature) != s.SignatureSize() {
+		return false
+	}
+	if binary.BigEndian.Uint32(signature) != uint32(s.levels-1) {
+		return false
+	}
+	current := publicKey[4:]
+	signature = signature[4:]
+	for level := 0; level < s.levels-1; level++ {
+		lmsSignature := signature[:s.lmsSignatureSize()]
+		child := signature[s.lmsSignatureSize() : s.lmsSignatureSize()+lmsPublicKey]
+		if !s.verifyLMS(current, child, lmsSignature) {
+			return false
+		}
+		current = child
+		signature = signature[s.lmsSignatureSize()+lmsPublicKey:]
+	}
+	return s.verifyLMS(current, message, signature)
+}
+
+// Index combines the leaf q of every level into the global index, with the
+// root level's q as the most significant part
+func (s *hssScheme) Index(signature []byte) (uint64, error) {
+	if len(signature) != s.SignatureSize() {
+		return 0, fmt.Errorf("%s signature is %d bytes, expected %d", s.Name(), len(signature), s.SignatureSize())
+	}
+	var index uint64
+	offset := 4
+	for level := 0; level < s.levels; level++ {
+		q := binary.BigEndian.Uint32(signature[offset:])
+		if q >= 1<<s.height {
+			return 0, fmt.Errorf("leaf %d out of range at level %d", q, level)
+		}
+		index = index<<s.height | uint64(q)
+		offset += s.lmsSignatureSize() + lmsPublicKey
+	}
+	return index, nil
+}
+
+// parseLMSPublicKey returns I and the root of an LMS public key of the scheme's types
+func (s *hssScheme) parseLMSPublicKey(publicKey []byte) (id, root []byte, ok bool) {
+	if len(publicKey) != lmsPublicKey ||
+		LMSType(binary.BigEndian.Uint32(publicKey)) != s.lmsType ||
+		LMOTSType(binary.BigEndian.Uint32(publicKey[4:])) != s.otsType {
+		return nil, nil, false
+	}
+	return publicKey[8 : 8+lmsIDSize], publicKey[8+lmsIDSize:], true
+}
+
+// verifyLMS computes the candidate root of RFC 8554 Algorithm 6a from an LMS
+// signature of exactly lmsSignatureSize bytes
+func (s *hssScheme) verifyLMS(publicKey, message, signature []byte) bool {
+	id, root, ok := s.parseLMSPublicKey(publicKey)
+	if !ok || len(signature) != s.lmsSignatureSize() {
+		return false
+	}
+	q := binary.BigEndian.Uint32(signature)
+	if q >= 1<<s.height || LMOTSType(binary.BigEndian.Uint32(signature[4:])) != s.otsType {
+		return false
+	}
+	otsSignature := signature[8 : 4+s.otsSignatureSize()]
+	rest := signature[4+s.otsSignatureSize():]
+	if LMSType(binary.BigEndian.Uint32(rest)) != s.lmsType {
+		return false
+	}
+	path := rest[4:]
+
+	var identifier [lmsIDSize]byte
+	copy(identifier[:], id)
+	candidate := lmotsCandidate(&identifier, q, s.ots, otsSignature[:lmsN], otsSignature[lmsN:], message)
+
+	node := uint32(1)<<s.height + q
+	tmp := lmsLeaf(&identifier, node, candidate)
+	for i := 0; node > 1; i++ {
+		var sibling [lmsN]byte
+		copy(sibling[:], path[i*lmsN:])
+		if node&1 == 1 {
+			tmp = lmsInterior(&identifier, node/2, &sibling, &tmp)
+		} else {
+			tmp = lmsInterior(&identifier, node/2, &tmp, &sibling)
+		}
+		node /= 2
+	}
+	return bytes.Equal(tmp[:], root)
+}
+
+// lmsTree is one LMS tree with every node, T[r] for 1 <= r < 2^(h+1)
+type lmsTree struct {
+	scheme *hssScheme
+	index  uint64 // Position of the tree among the trees of its level
+	id     [lmsIDSize]byte
+	seed   [lmsN]byte
+	nodes  [][lmsN]byte
+}
+
+// newLMSTree computes the one-time public key of every leaf and hashes them
+// up to the root
+func newLMSTree(s *hssScheme, index uint64, id [lmsIDSize]byte, seed [lmsN]byte) *lmsTree {
+	t := &lmsTree{scheme: s, index: index, id: id, seed: seed}
+	leaves := uint32(1) << s.height
+	t.nodes = make([][lmsN]byte, 2*leaves)
+	for q := uint32(0); q < leaves; q++ {
+		t.nodes[leaves+q] = lmsLeaf(&t.id, leaves+q, t.otsPublicKey(q))
+	}
+	for r := leaves - 1; r >= 1; r-- {
+		t.nodes[r] = lmsInterior(&t.id, r, &t.nodes[2*r], &t.nodes[2*r+1])
+	}
+	return t
+}
+
+// publicKey encodes the LMS public key u32str(type) || u32str(otstype) || I || T[1]
+func (t *lmsTree) publicKey() []byte {
+	publicKey := binary.BigEndian.AppendUint32(nil, uint32(t.scheme.lmsType))
+	publicKey = binary.BigEndian.AppendUint32(publicKey, uint32(t.scheme.otsType))
+	publicKey = append(publicKey, t.id[:]...)
+	return append(publicKey, t.nodes[1][:]...)
+}
+
+// child derives the tree that the one-time key q of this tree signs
+func (t *lmsTree) child(q uint32, index uint64) *lmsTree {
+	id := t.secret(q, dChildI)
+	return newLMSTree(t.scheme, index, [lmsIDSize]byte(id[:lmsIDSize]), t.secret(q, dChildSeed))
+}
+
+// secret returns the pseudorandom value H(I || u32str(q) || u16str(i) || u8str(0xff) || SEED)
+func (t *lmsTree) secret(q uint32, i uint16) [lmsN]byte {
+	var buf [lmsIDSize + 4 + 2 + 1 + lmsN]byte
+	copy(buf[:], t.id[:])
+	binary.BigEndian.PutUint32(buf[lmsIDSize:], q)
+	binary.BigEndian.PutUint16(buf[lmsIDSize+4:], i)
+	buf[lmsIDSize+6] = 0xff
+	copy(buf[lmsIDSize+7:], t.seed[:])
+	return sha256.Sum256(buf[:])
+}
+
+// otsPublicKey computes K, the hash of the ends of every chain of one-time key q
+func (t *lmsTree) otsPublicKey(q uint32) [lmsN]byte {
+	ots := t.scheme.ots
+	h := sha256.New()
+	h.Write(t.id[:])
+	h.Write(binary.BigEndian.AppendUint32(nil, q))
+	h.Write(binary.BigEndian.AppendUint16(nil, dPBLC))
+	for i := 0; i < ots.p; i++ {
+		y := lmotsChain(&t.id, q, uint16(i), t.secret(q, uint16(i)), 0, 1<<ots.w-1)
+		h.Write(y[:])
+	}
+	var k [lmsN]byte
+	h.Sum(k[:0])
+	return k
+}
+
+// sign produces the LMS signature of a message with one-time key q:
+// u32str(q) || LM-OTS signature || u32str(type) || authentication path
+func (t *lmsTree) sign(q uint32, message []byte) []byte {
+	// C is derived rather than random, so that a signature is a function of the
+	// index and the message alone
+	return t.signWith(q, t.secret(q, dRandomizer), message)
+}
+
+// signWith produces the LMS signature of a message with one-time key q and
+// the randomizer c
+func (t *lmsTree) signWith(q uint32, c [lmsN]byte, message []byte) []byte {
+	s := t.scheme
+	signature := make([]byte, 0, s.lmsSignatureSize())
+	signature = binary.BigEndian.AppendUint32(signature, q)
+	signature = binary.BigEndian.AppendUint32(signature, uint32(s.otsType))
+	signature = append(signature, c[:]...)
+	for i, a := range lmotsDigits(s.ots, lmotsMessageHash(&t.id, q, c[:], message)) {
+		y := lmotsChain(&t.id, q, uint16(i), t.secret(q, uint16(i)), 0, a)
+		signature = append(signature, y[:]...)
+	}
+
+	signature = binary.BigEndian.AppendUint32(signature, uint32(s.lmsType))
+	for node := uint32(1)<<s.height + q; node > 1; node /= 2 {
+		signature = append(signature, t.nodes[node^1][:]...)
+	}
+	return signature
+}
+
+// lmotsCandidate computes the candidate one-time public key Kc of RFC 8554
+// Algorithm 4b by completing every chain of the signature
+func lmotsCandidate(id *[lmsIDSize]byte, q uint32, ots lmotsParams, c, ys, message []byte) [lmsN]byte {
+	h := sha256.New()
+	h.Write(id[:])
+	h.Write(binary.BigEndian.AppendUint32(nil, q))
+	h.Write(binary.BigEndian.AppendUint16(nil, dPBLC))
+	for i, a := range lmotsDigits(ots, lmotsMessageHash(id, q, c, message)) {
+		z := lmotsChain(id, q, uint16(i), [lmsN]byte(ys[i*lmsN:(i+1)*lmsN]), a, 1<<ots.w-1)
+		h.Write(z[:])
+	}
+	var k [lmsN]byte
+	h.Sum(k[:0])
+	return k
+}
+
+// lmotsMessageHash returns Q = H(I || u32str(q) || u16str(D_MESG) || C || message)
+func lmotsMessageHash(id *[lmsIDSize]byte, q uint32, c, message []byte) [lmsN]byte {
+	h := sha256.New()
+	h.Write(id[:])
+	h.Write(binary.BigEndian.AppendUint32(nil, q))
+	h.Write(binary.BigEndian.AppendUint16(nil, dMESG))
+	h.Write(c)
+	h.Write(message)
+	var sum [lmsN]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// lmotsDigits returns the p base-2^w digits of Q || Cksm(Q)
+func lmotsDigits(ots lmotsParams, hash [lmsN]byte) []int {
+	max := 1<<ots.w - 1
+	coef := func(s []byte, i int) int {
+		return int(s[i*ots.w/8]>>(8-(ots.w*(i%(8/ots.w))+ots.w))) & max
+	}
+	sum := 0
+	for i := 0; i < lmsN*8/ots.w; i++ {
+		sum += max - coef(hash[:], i)
+	}
+	s := binary.BigEndian.AppendUint16(hash[:], uint16(sum<<ots.ls))
+	digits := make([]int, ots.p)
+	for i := range digits {
+		digits[i] = coef(s, i)
+	}
+	return digits
+}
+
+// lmotsChain applies H(I || u32str(q) || u16str(i) || u8str(j) || tmp) for
+// from <= j < to
+func lmotsChain(id *[lmsIDSize]byte, q uint32, i uint16, tmp [lmsN]byte, from, to int) [lmsN]byte {
+	var buf [lmsIDSize + 4 + 2 + 1 + lmsN]byte
+	copy(buf[:], id[:])
+	binary.BigEndian.PutUint32(buf[lmsIDSize:], q)
+	binary.BigEndian.PutUint16(buf[lmsIDSize+4:], i)
+	for j := from; j < to; j++ {
+		buf[lmsIDSize+6] = byte(j)
+		copy(buf[lmsIDSize+7:], tmp[:])
+		tmp = sha256.Sum256(buf[:])
+	}
+	return tmp
+}
+
+// lmsLeaf returns T[r] = H(I || u32str(r) || u16str(D_LEAF) || K)
+func lmsLeaf(id *[lmsIDSize]byte, r uint32, k [lmsN]byte) [lmsN]byte {
+	var buf [lmsIDSize + 4 + 2 + lmsN]byte
+	copy(buf[:], id[:])
+	binary.BigEndian.PutUint32(buf[lmsIDSize:], r)
+	binary.BigEndian.PutUint16(buf[lmsIDSize+4:], dLEAF)
+	copy(buf[lmsIDSize+6:], k[:])
+	return sha256.Sum256(buf[:])
+}
+
+// lmsInterior returns T[r] = H(I || u32str(r) || u16str(D_INTR) || left || right)
+func lmsInterior(id *[lmsIDSize]byte, r uint32, left, right *[lmsN]byte) [lmsN]byte {
+	var buf [lmsIDSize + 4 + 2 + 2*lmsN]byte
+	copy(buf[:], id[:])
+	binary.BigEndian.PutUint32(buf[lmsIDSize:], r)
+	binary.BigEndian.PutUint16(buf[lmsIDSize+4:], dINTR)
+	copy(buf[lmsIDSize+6:], left[:])
+	copy(buf[lmsIDSize+6+lmsN:], right[:])
+	return sha256.Sum256(buf[:])
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package hbs
+
+import "os"
+
+// lockFile does nothing: without flock, FileStateStore only serialises updates
+// within one process, so a state directory must not be shared between processes
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile closes the lock file
+func unlockFile(f *os.File) error {
+	return f.Close()
+}
+
+// syncDir does nothing where directories cannot be synced; the rename is
+// durable once the file system commits it
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package hbs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an exclusive advisory lock, waiting for other processes
+func lockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX)
+}
+
+// unlockFile releases the lock and closes the file
+func unlockFile(f *os.File) error {
+	defer f.Close()
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
+
+// syncDir makes a rename in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,267 @@
+package hbs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StateStore records how many one-time keys of each stateful key are used.
+// A reservation must be durable before Reserve returns, so that a crash can
+// at worst lose indices that were reserved but never signed with, and never
+// hand out an index twice.
+type StateStore interface {
+	// Reserve marks up to n of the next unused indices of a key as used and
+	// returns the first and how many were reserved, fewer than n only near the
+	// end of the key. It fails with ErrKeyExhausted once all limit are used.
+	Reserve(keyID string, n, limit uint64) (first, count uint64, err error)
+	// Used returns how many indices of a key have been reserved
+	Used(keyID string) (uint64, error)
+}
+
+// reserve computes a reservation of up to n indices from next
+func reserve(next, n, limit uint64) (first, count uint64, err error) {
+	if next >= limit {
+		return 0, 0, ErrKeyExhausted
+	}
+	count = n
+	if count > limit-next {
+		count = limit - next
+	}
+	return next, count, nil
+}
+
+// MemoryStateStore keeps state in memory. It prevents reuse within one
+// process only, so it is meant for throwaway benchmark keys.
+type MemoryStateStore struct {
+	mu   sync.Mutex
+	next map[string]uint64
+}
+
+// NewMemoryStateStore creates an empty in-memory store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{next: make(map[string]uint64)}
+}
+
+func (m *MemoryStateStore) Reserve(keyID string, n, limit uint64) (uint64, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	first, count, err := reserve(m.next[keyID], n, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	m.next[keyID] = first + count
+	return first, count, nil
+}
+
+func (m *MemoryStateStore) Used(keyID string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.next[keyID], nil
+}
+
+// keyState is the content of a key's state file
+type keyState struct {
+	KeyID   string `json:"key_id"`
+	Next    uint64 `json:"next"`  // First index not yet reserved
+	Limit   uint64 `json:"limit"` // Signatures the key can make
+	Updated string `json:"updated"`
+}
+
+// FileStateStore keeps the state of each key in <dir>/<key ID>.json. An update
+// writes a temporary file, syncs it, renames it over the state file and syncs
+// the directory, so the file always holds either the old or the new state. A
+// lock file serialises updates between processes where the platform supports
+// it. Deleting or restoring an older copy of a state file makes the key unsafe.
+type FileStateStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenFileStateStore opens a state directory, creating it if needed
+func OpenFileStateStore(dir string) (*FileStateStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %v", err)
+	}
+	return &FileStateStore{dir: dir}, nil
+}
+
+func (f *FileStateStore) path(keyID string) string {
+	return filepath.Join(f.dir, keyID+".json")
+}
+
+func (f *FileStateStore) Reserve(keyID string, n, limit uint64) (uint64, uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	lock, err := f.lock(keyID)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlockFile(lock)
+
+	state, err := f.load(keyID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if state.Limit != 0 && state.Limit != limit {
+		return 0, 0, fmt.Errorf("state of key %s has limit %d, expected %d", keyID, state.Limit, limit)
+	}
+	first, count, err := reserve(state.Next, n, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	state.Next = first + count
+	state.Limit = limit
+	state.Updated = time.Now().Format(time.RFC3339Nano)
+	if err := f.store(state); err != nil {
+		return 0, 0, err
+	}
+	return first, count, nil
+}
+
+func (f *FileStateStore) Used(keyID string) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, err := f.load(keyID)
+	if err != nil {
+		return 0, err
+	}
+	return state.Next, nil
+}
+
+// lock opens and locks the key's lock file
+func (f *FileStateStore) lock(keyID string) (*os.File, error) {
+	lock, err := os.OpenFile(filepath.Join(f.dir, keyID+".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state lock: %v", err)
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to lock state: %v", err)
+	}
+	return lock, nil
+}
+
+// load reads a key's state; a key without a state file has used no indices.
+// A state file that cannot be read refuses every reservation rather than
+// starting over at index 0.
+func (f *FileStateStore) load(keyID string) (keyState, error) {
+	data, err := os.ReadFile(f.path(keyID))
+	if os.IsNotExist(err) {
+		return keyState{KeyID: keyID}, nil
+	}
+	if err != nil {
+		return keyState{}, fmt.Errorf("failed to read state of key %s: %v", keyID, err)
+	}
+	var state keyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return keyState{}, fmt.Errorf("corrupt state of key %s: %v", keyID, err)
+	}
+	if state.KeyID != keyID {
+		return keyState{}, fmt.Errorf("state file of key %s belongs to key %s", keyID, state.KeyID)
+	}
+	return state, nil
+}
+
+// store replaces a key's state file atomically and durably
+func (f *FileStateStore) store(state keyState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, state.KeyID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync state: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path(state.KeyID)); err != nil {
+		return fmt.Errorf("failed to replace state: %v", err)
+	}
+	return syncDir(f.dir)
+}
+
+// Signer signs with a stateful private key, reserving indices from a store
+// before using them. Reserving several indices at once amortises the durable
+// state update over as many signatures; indices still reserved when the
+// process exits are skipped, never reused.
+type Signer struct {
+	key     PrivateKey
+	store   StateStore
+	keyID   string
+	reserve uint64
+
+	mu         sync.Mutex
+	next, end  uint64 // Reserved indices not yet used
+	updates    uint64
+	updateTime time.Duration
+}
+
+// NewSigner creates a signer that reserves reserve indices per state update;
+// 0 or 1 updates the state before every signature
+func NewSigner(key PrivateKey, store StateStore, reserve uint64) *Signer {
+	if reserve == 0 {
+		reserve = 1
+	}
+	return &Signer{key: key, store: store, keyID: KeyID(key.PublicKey()), reserve: reserve}
+}
+
+// Key returns the private key of the signer
+func (s *Signer) Key() PrivateKey {
+	return s.key
+}
+
+// Sign signs a message with the next unused one-time key
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == s.end {
+		start := time.Now()
+		first, count, err := s.store.Reserve(s.keyID, s.reserve, s.key.Scheme().MaxSignatures())
+		s.updateTime += time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+		s.updates++
+		s.next, s.end = first, first+count
+	}
+	index := s.next
+	s.next++
+	return s.key.signAt(index, message)
+}
+
+// Remaining returns how many signatures the key can still make: the indices
+// the store has not reserved plus those this signer reserved but has not used
+func (s *Signer) Remaining() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, err := s.store.Used(s.keyID)
+	if err != nil {
+		return 0, err
+	}
+	return s.key.Scheme().MaxSignatures() - used + (s.end - s.next), nil
+}
+
+// StateUpdates returns how many state updates the signer made and their total time
+func (s *Signer) StateUpdates() (uint64, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updates, s.updateTime
+}
@@ -0,0 +1,544 @@
+package hbs
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// XMSSMTOID is an XMSS^MT parameter set of RFC 8391 Section 5.4
+type XMSSMTOID uint32
+
+const (
+	XMSSMTSHA2_20_2_256  XMSSMTOID = 0x00000001
+	XMSSMTSHA2_20_4_256  XMSSMTOID = 0x00000002
+	XMSSMTSHA2_40_2_256  XMSSMTOID = 0x00000003
+	XMSSMTSHA2_40_4_256  XMSSMTOID = 0x00000004
+	XMSSMTSHA2_40_8_256  XMSSMTOID = 0x00000005
+	XMSSMTSHA2_60_3_256  XMSSMTOID = 0x00000006
+	XMSSMTSHA2_60_6_256  XMSSMTOID = 0x00000007
+	XMSSMTSHA2_60_12_256 XMSSMTOID = 0x00000008
+)
+
+// String returns the RFC 8391 name of the parameter set
+func (o XMSSMTOID) String() string {
+	if h, d, ok := o.params(); ok {
+		return fmt.Sprintf("XMSSMT-SHA2_%d/%d_256", h, d)
+	}
+	return "Unknown"
+}
+
+// params returns the total height h and the number of layers d
+func (o XMSSMTOID) params() (h, d int, ok bool) {
+	switch o {
+	case XMSSMTSHA2_20_2_256:
+		return 20, 2, true
+	case XMSSMTSHA2_20_4_256:
+		return 20, 4, true
+	case XMSSMTSHA2_40_2_256:
+		return 40, 2, true
+	case XMSSMTSHA2_40_4_256:
+		return 40, 4, true
+	case XMSSMTSHA2_40_8_256:
+		return 40, 8, true
+	case XMSSMTSHA2_60_3_256:
+		return 60, 3, true
+	case XMSSMTSHA2_60_6_256:
+		return 60, 6, true
+	case XMSSMTSHA2_60_12_256:
+		return 60, 12, true
+	default:
+		return 0, 0, false
+	}
+}
+
+const (
+	xmssN    = 32 // n of the SHA2-256 parameter sets
+	wotsW    = 16
+	wotsLen1 = 64 // 8n / lg(w)
+	wotsLen2 = 3  // floor(lg(len1 (w-1)) / lg(w)) + 1
+	wotsLen  = wotsLen1 + wotsLen2
+
+	// Padding prefixes toByte(x, 32) that separate the hash functions of RFC
+	// 8391 Section 5.1, and PRF_keygen of SP 800-208 Section 5
+	xmssPadF         = 0
+	xmssPadH         = 1
+	xmssPadHashMsg   = 2
+	xmssPadPRF       = 3
+	xmssPadPRFKeygen = 4
+
+	// Address types of RFC 8391 Section 2.5
+	xmssAddressOTS   = 0
+	xmssAddressLTree = 1
+	xmssAddressTree  = 2
+
+	// maxXMSSTreeHeight bounds the subtree height, since every node of the
+	// current tree of each layer is kept in memory
+	maxXMSSTreeHeight = 15
+)
+
+// xmssmtScheme is XMSS^MT with SHA2-256 and n = 32
+type xmssmtScheme struct {
+	oid        XMSSMTOID
+	height     int // Total height h
+	layers     int // d
+	treeHeight int // h/d
+}
+
+// NewXMSSMT returns the XMSS^MT scheme of a parameter set. Parameter sets with
+// subtrees higher than 15 are not supported.
+func NewXMSSMT(oid XMSSMTOID) (Scheme, error) {
+	h, d, ok := oid.params()
+	if !ok {
+		return nil, fmt.Errorf("unknown XMSS^MT parameter set: %d", oid)
+	}
+	if h/d > maxXMSSTreeHeight {
+		return nil, fmt.Errorf("%s is not supported: trees are held in memory, at most height %d", oid, maxXMSSTreeHeight)
+	}
+	return &xmssmtScheme{oid: oid, height: h, layers: d, treeHeight: h / d}, nil
+}
+
+func mustXMSSMT(oid XMSSMTOID) Scheme {
+	scheme, err := NewXMSSMT(oid)
+	if err != nil {
+		panic(err)
+	}
+	return scheme
+}
+
+func (s *xmssmtScheme) Name() string          { return s.oid.String() }
+func (s *xmssmtScheme) MaxSignatures() uint64 { return 1 << s.height }
+func (s *xmssmtScheme) PublicKeySize() int    { return 4 + 2*xmssN }
+func (s *xmssmtScheme) indexSize() int        { return (s.height + 7) / 8 }
+func (s *xmssmtScheme) treeSigSize() int      { return (wotsLen + s.treeHeight) * xmssN }
+
+func (s *xmssmtScheme) SignatureSize() int {
+	return s.indexSize() + xmssN + s.layers*s.treeSigSize()
+}
+
+// xmssmtPrivateKey caches the current tree of every layer and the signatures
+// linking them, so that a signature only builds a tree when the index crosses
+// into the next tree of a layer
+type xmssmtPrivateKey struct {
+	scheme    *xmssmtScheme
+	seed      []byte
+	skPRF     [xmssN]byte
+	hasher    *xmssHasher
+	root      [xmssN]byte
+	publicKey []byte
+
+	mu       sync.Mutex
+	trees    []*xmssTree // trees[j] is the current tree of layer j, with layer 0 at the bottom
+	rootSigs [][]byte    // rootSigs[j] is trees[j+1]'s signature of trees[j]'s root
+}
+
+// NewPrivateKey derives SK_SEED, SK_PRF and SEED from the seed with HKDF
+func (s *xmssmtScheme) NewPrivateKey(seed []byte) (PrivateKey, error) {
+	if err := checkSeed(seed); err != nil {
+		return nil, err
+	}
+	derive := func(label string) ([xmssN]byte, error) {
+		key, err := hkdf.Key(sha256.New, seed, nil, "crypto-benchmark XMSS^MT "+label, xmssN)
+		defer clear(key)
+		return [xmssN]byte(key), err
+	}
+	skSeed, err := derive("SK_SEED")
+	if err != nil {
+		return nil, err
+	}
+	skPRF, err := derive("SK_PRF")
+	if err != nil {
+		return nil, err
+	}
+	pubSeed, err := derive("SEED")
+	if err != nil {
+		return nil, err
+	}
+
+	key := &xmssmtPrivateKey{
+		scheme:   s,
+		seed:     append([]byte(nil), seed...),
+		skPRF:    skPRF,
+		hasher:   &xmssHasher{skSeed: skSeed, pubSeed: pubSeed},
+		trees:    make([]*xmssTree, s.layers),
+		rootSigs: make([][]byte, s.layers-1),
+	}
+	top := s.layers - 1
+	key.trees[top] = newXMSSTree(key.hasher, s.treeHeight, uint32(top), 0)
+	for layer := top - 1; layer >= 0; layer-- {
+		key.descend(layer, 0)
+	}
+	key.root = key.trees[top].root()
+
+	key.publicKey = binary.BigEndian.AppendUint32(nil, uint32(s.oid))
+	key.publicKey = append(key.publicKey, key.root[:]...)
+	key.publicKey = append(key.publicKey, pubSeed[:]...)
+	return key, nil
+}
+
+func (k *xmssmtPrivateKey) Scheme() Scheme    { return k.scheme }
+func (k *xmssmtPrivateKey) PublicKey() []byte { return append([]byte(nil), k.publicKey...) }
+func (k *xmssmtPrivateKey) Seed() []byte      { return append([]byte(nil), k.seed...) }
+
+// Destroy overwrites the seed, SK_PRF and SK_SEED, from which all one-time
+// keys are derived
+func (k *xmssmtPrivateKey) Destroy() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	clear(k.seed)
+	k.seed = nil
+	k.skPRF = [xmssN]byte{}
+	k.hasher.skSeed = [xmssN]byte{}
+	k.trees = nil
+	k.rootSigs = nil
+}
+
+// descend replaces the tree of a layer with the tree at index among that
+// layer's trees, and signs its root with the leaf of the layer above that the
+// index selects
+func (k *xmssmtPrivateKey) descend(layer int, index uint64) {
+	s := k.scheme
+	tree := newXMSSTree(k.hasher, s.treeHeight, uint32(layer), index)
+	k.trees[layer] = tree
+	leaf := uint32(index & (1<<s.treeHeight - 1))
+	k.rootSigs[layer] = k.trees[layer+1].sign(leaf, tree.root())
+}
+
+// signAt produces the XMSS^MT signature of RFC 8391 Algorithm 15 with the
+// index-th one-time key of the bottom layer. WOTS+ signing is deterministic, so
+// rebuilding a key after a restart signs each root with the same signature.
+func (k *xmssmtPrivateKey) signAt(index uint64, message []byte) ([]byte, error) {
+	s := k.scheme
+	if index >= s.MaxSignatures() {
+		return nil, ErrKeyExhausted
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.trees == nil {
+		return nil, ErrKeyDestroyed
+	}
+
+	for layer := s.layers - 2; layer >= 0; layer-- {
+		treeIndex := index >> (s.treeHeight * (layer + 1))
+		if k.trees[layer].index != treeIndex {
+			k.descend(layer, treeIndex)
+		}
+	}
+
+	var indexBytes [xmssN]byte
+	binary.BigEndian.PutUint64(indexBytes[xmssN-8:], index)
+	r := xmssPRF(xmssPadPRF, k.skPRF[:], indexBytes[:])
+	digest := xmssHashMessage(r[:], k.root[:], indexBytes[:], message)
+
+	signature := make([]byte, 0, s.SignatureSize())
+	signature = append(signature, indexBytes[xmssN-s.indexSize():]...)
+	signature = append(signature, r[:]...)
+	signature = append(signature, k.trees[0].sign(uint32(index&(1<<s.treeHeight-1)), digest)...)
+	for _, rootSig := range k.rootSigs {
+		signature = append(signature, rootSig...)
+	}
+	return signature, nil
+}
+
+// CheckPublicKey checks the size and parameter set of an XMSS^MT public key
+func (s *xmssmtScheme) CheckPublicKey(publicKey []byte) error {
+	if len(publicKey) != s.PublicKeySize() {
+		return fmt.Errorf("%s public key is %d bytes, expected %d", s.Name(), len(publicKey), s.PublicKeySize())
+	}
+	if oid := XMSSMTOID(binary.BigEndian.Uint32(publicKey)); oid != s.oid {
+		return fmt.Errorf("public key is %s, expected %s", oid, s.oid)
+	}
+	return nil
+}
+
+// Verify recomputes the root of every layer from the bottom, as in RFC 8391
+// Algorithm 16, and compares the top one with the public key
+func (s *xmssmtScheme) Verify(publicKey, message, signature []byte) bool {
+	if s.CheckPublicKey(publicKey) != nil || len(signature) != s.SignatureSize() {
+		return false
+	}
+	root := publicKey[4 : 4+xmssN]
+	hasher := &xmssHasher{pubSeed: [xmssN]byte(publicKey[4+xmssN:])}
+
+	var indexBytes [xmssN]byte
+	copy(indexBytes[xmssN-s.indexSize():], signature)
+	index := binary.BigEndian.Uint64(indexBytes[xmssN-8:])
+	if index >= s.MaxSignatures() {
+		return false
+	}
+	r := signature[s.indexSize() : s.indexSize()+xmssN]
+	signature = signature[s.indexSize()+xmssN:]
+
+	node := xmssHashMessage(r, root, indexBytes[:], message)
+	treeIndex := index
+	for layer := 0; layer < s.layers; layer++ {
+		leaf := uint32(treeIndex & (1<<s.treeHeight - 1))
+		treeIndex >>= s.treeHeight
+		node = hasher.rootFromSig(uint32(layer), treeIndex, leaf, s.treeHeight, signature[:s.treeSigSize()], node)
+		signature = signature[s.treeSigSize():]
+	}
+	return bytes.Equal(node[:], root)
+}
+
+// Index returns idx_sig, the first ceil(h/8) bytes of the signature
+func (s *xmssmtScheme) Index(signature []byte) (uint64, error) {
+	if len(signature) != s.SignatureSize() {
+		return 0, fmt.Errorf("%s signature is %d bytes, expected %d", s.Name(), len(signature), s.SignatureSize())
+	}
+	var index uint64
+	for _, b := range signature[:s.indexSize()] {
+		index = index<<8 | uint64(b)
+	}
+	if index >= s.MaxSignatures() {
+		return 0, fmt.Errorf("index %d out of range", index)
+	}
+	return index, nil
+}
+
+// xmssTree is one XMSS tree of a layer with every node: nodes[k][i] is the
+// i-th node at height k
+type xmssTree struct {
+	hasher *xmssHasher
+	layer  uint32
+	index  uint64 // Position of the tree among the trees of its layer
+	nodes  [][][xmssN]byte
+}
+
+// newXMSSTree computes every leaf from its WOTS+ public key with an L-tree and
+// hashes them up to the root, addressing nodes as RFC 8391 treeHash does
+func newXMSSTree(hasher *xmssHasher, height int, layer uint32, index uint64) *xmssTree {
+	t := &xmssTree{hasher: hasher, layer: layer, index: index, nodes: make([][][xmssN]byte, height+1)}
+	leaves := 1 << height
+	t.nodes[0] = make([][xmssN]byte, leaves)
+	for i := range t.nodes[0] {
+		pk := hasher.wotsPublicKey(t.address(xmssAddressOTS, uint32(i)))
+		t.nodes[0][i] = hasher.lTree(pk, t.address(xmssAddressLTree, uint32(i)))
+	}
+	for k := 0; k < height; k++ {
+		t.nodes[k+1] = make([][xmssN]byte, len(t.nodes[k])/2)
+		for i := range t.nodes[k+1] {
+			adrs := t.address(xmssAddressTree, 0)
+			adrs[5] = uint32(k)
+			adrs[6] = uint32(i)
+			t.nodes[k+1][i] = hasher.randHash(&t.nodes[k][2*i], &t.nodes[k][2*i+1], adrs)
+		}
+	}
+	return t
+}
+
+func (t *xmssTree) root() [xmssN]byte {
+	return t.nodes[len(t.nodes)-1][0]
+}
+
+// address returns an address of this tree of the given type, with its first
+// type-specific word (the OTS or L-tree address) set
+func (t *xmssTree) address(addressType, word4 uint32) *xmssAddress {
+	adrs := &xmssAddress{}
+	adrs.setLayerAndTree(t.layer, t.index)
+	adrs[3] = addressType
+	adrs[4] = word4
+	return adrs
+}
+
+// sign returns the WOTS+ signature of a digest by a leaf followed by the
+// leaf's authentication path
+func (t *xmssTree) sign(leaf uint32, digest [xmssN]byte) []byte {
+	signature := make([]byte, 0, (wotsLen+len(t.nodes)-1)*xmssN)
+	for _, y := range t.hasher.wotsSign(digest, t.address(xmssAddressOTS, leaf)) {
+		signature = append(signature, y[:]...)
+	}
+	for k := 0; k < len(t.nodes)-1; k++ {
+		signature = append(signature, t.nodes[k][(leaf>>k)^1][:]...)
+	}
+	return signature
+}
+
+// xmssAddress is the 32-byte hash address ADRS of RFC 8391 Section 2.5 as
+// eight words: layer, tree (two words), type, then four type-specific words
+type xmssAddress [8]uint32
+
+// setLayerAndTree sets the layer and the 64-bit tree address
+func (a *xmssAddress) setLayerAndTree(layer uint32, tree uint64) {
+	a[0] = layer
+	a[1] = uint32(tree >> 32)
+	a[2] = uint32(tree)
+}
+
+func (a *xmssAddress) bytes() [32]byte {
+	var b [32]byte
+	for i, word := range a {
+		binary.BigEndian.PutUint32(b[4*i:], word)
+	}
+	return b
+}
+
+// xmssHasher holds the public SEED that keys and masks every hash, and, when
+// signing, SK_SEED from which the WOTS+ secret keys are generated
+type xmssHasher struct {
+	skSeed  [xmssN]byte
+	pubSeed [xmssN]byte
+}
+
+// xmssPRF returns SHA-256(toByte(pad, 32) || key || m), the keyed hash shared
+// by F, H, PRF and PRF_keygen
+func xmssPRF(pad byte, key []byte, m ...[]byte) [xmssN]byte {
+	var buf [4 * xmssN]byte
+	buf[xmssN-1] = pad
+	n := copy(buf[xmssN:], key) + xmssN
+	for _, part := range m {
+		n += copy(buf[n:], part)
+	}
+	return sha256.Sum256(buf[:n])
+}
+
+// xmssHashMessage returns H_msg(r || root || toByte(idx, 32), message)
+func xmssHashMessage(r, root, index, message []byte) [xmssN]byte {
+	h := sha256.New()
+	var pad [xmssN]byte
+	pad[xmssN-1] = xmssPadHashMsg
+	h.Write(pad[:])
+	h.Write(r)
+	h.Write(root)
+	h.Write(index)
+	h.Write(message)
+	var sum [xmssN]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// prf returns PRF(SEED, ADRS) for the key and bitmask words of an address
+func (x *xmssHasher) prf(adrs *xmssAddress, keyAndMask uint32) [xmssN]byte {
+	adrs[7] = keyAndMask
+	b := adrs.bytes()
+	return xmssPRF(xmssPadPRF, x.pubSeed[:], b[:])
+}
+
+// randHash is RAND_HASH of RFC 8391 Algorithm 7
+func (x *xmssHasher) randHash(left, right *[xmssN]byte, adrs *xmssAddress) [xmssN]byte {
+	key := x.prf(adrs, 0)
+	mask0 := x.prf(adrs, 1)
+	mask1 := x.prf(adrs, 2)
+	var m [2 * xmssN]byte
+	for i := 0; i < xmssN; i++ {
+		m[i] = left[i] ^ mask0[i]
+		m[xmssN+i] = right[i] ^ mask1[i]
+	}
+	return xmssPRF(xmssPadH, key[:], m[:])
+}
+
+// chain is the WOTS+ chaining function of RFC 8391 Algorithm 2, applying F
+// steps times from position start of chain adrs[5]
+func (x *xmssHasher) chain(tmp [xmssN]byte, start, steps int, adrs *xmssAddress) [xmssN]byte {
+	for i := start; i < start+steps; i++ {
+		adrs[6] = uint32(i)
+		key := x.prf(adrs, 0)
+		mask := x.prf(adrs, 1)
+		for j := range tmp {
+			tmp[j] ^= mask[j]
+		}
+		tmp = xmssPRF(xmssPadF, key[:], tmp[:])
+	}
+	return tmp
+}
+
+// wotsSecret generates the secret key of one chain as SP 800-208 specifies:
+// PRF_keygen(SK_SEED, SEED || ADRS) with the hash and key-and-mask words zero
+func (x *xmssHasher) wotsSecret(adrs *xmssAddress) [xmssN]byte {
+	adrs[6], adrs[7] = 0, 0
+	b := adrs.bytes()
+	return xmssPRF(xmssPadPRFKeygen, x.skSeed[:], x.pubSeed[:], b[:])
+}
+
+// wotsPublicKey computes the end of every chain of the one-time key at adrs
+func (x *xmssHasher) wotsPublicKey(adrs *xmssAddress) [][xmssN]byte {
+	pk := make([][xmssN]byte, wotsLen)
+	for i := range pk {
+		adrs[5] = uint32(i)
+		pk[i] = x.chain(x.wotsSecret(adrs), 0, wotsW-1, adrs)
+	}
+	return pk
+}
+
+// wotsSign walks each chain as far as the corresponding digit of the digest
+func (x *xmssHasher) wotsSign(digest [xmssN]byte, adrs *xmssAddress) [][xmssN]byte {
+	signature := make([][xmssN]byte, wotsLen)
+	for i, digit := range wotsDigits(digest) {
+		adrs[5] = uint32(i)
+		signature[i] = x.chain(x.wotsSecret(adrs), 0, digit, adrs)
+	}
+	return signature
+}
+
+// rootFromSig computes the root of a tree from a WOTS+ signature of digest and
+// an authentication path, as RFC 8391 Algorithm 13 does
+func (x *xmssHasher) rootFromSig(layer uint32, tree uint64, leaf uint32, height int, signature []byte, digest [xmssN]byte) [xmssN]byte {
+	adrs := &xmssAddress{}
+	adrs.setLayerAndTree(layer, tree)
+	adrs[3] = xmssAddressOTS
+	adrs[4] = leaf
+	pk := make([][xmssN]byte, wotsLen)
+	for i, digit := range wotsDigits(digest) {
+		adrs[5] = uint32(i)
+		pk[i] = x.chain([xmssN]byte(signature[i*xmssN:(i+1)*xmssN]), digit, wotsW-1-digit, adrs)
+	}
+
+	adrs = &xmssAddress{}
+	adrs.setLayerAndTree(layer, tree)
+	adrs[3] = xmssAddressLTree
+	adrs[4] = leaf
+	node := x.lTree(pk, adrs)
+
+	auth := signature[wotsLen*xmssN:]
+	adrs = &xmssAddress{}
+	adrs.setLayerAndTree(layer, tree)
+	adrs[3] = xmssAddressTree
+	for k := 0; k < height; k++ {
+		sibling := [xmssN]byte(auth[k*xmssN : (k+1)*xmssN])
+		adrs[5] = uint32(k)
+		adrs[6] = leaf >> (k + 1)
+		if (leaf>>k)&1 == 0 {
+			node = x.randHash(&node, &sibling, adrs)
+		} else {
+			node = x.randHash(&sibling, &node, adrs)
+		}
+	}
+	return node
+}
+
+// lTree compresses a WOTS+ public key into a leaf, as RFC 8391 Algorithm 8
+// does, overwriting pk
+func (x *xmssHasher) lTree(pk [][xmssN]byte, adrs *xmssAddress) [xmssN]byte {
+	l := len(pk)
+	adrs[5] = 0
+	for l > 1 {
+		for i := 0; i < l/2; i++ {
+			adrs[6] = uint32(i)
+			pk[i] = x.randHash(&pk[2*i], &pk[2*i+1], adrs)
+		}
+		if l%2 == 1 {
+			pk[l/2] = pk[l-1]
+		}
+		l = (l + 1) / 2
+		adrs[5]++
+	}
+	return pk[0]
+}
+
+// wotsDigits returns the len1 base-w digits of a digest followed by the len2
+// digits of its checksum
+func wotsDigits(digest [xmssN]byte) []int {
+	digits := make([]int, 0, wotsLen)
+	for _, b := range digest {
+		digits = append(digits, int(b>>4), int(b&0x0f))
+	}
+	checksum := 0
+	for _, d := range digits {
+		checksum += wotsW - 1 - d
+	}
+	// Shift so the len2 digits are the top 12 bits of toByte(checksum, 2)
+	checksum <<= 4
+	return append(digits, checksum>>12&0x0f, checksum>>8&0x0f, checksum>>4&0x0f)
+}
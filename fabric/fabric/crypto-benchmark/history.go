@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto-benchmark/metrics"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// runHistory implements the history command and returns the process exit code
+func runHistory(args []string) int {
+	if len(args) == 0 {
+		printHistoryUsage()
+		return 2
+	}
+
+	switch args[0] {
+	case "trend":
+		return runHistoryTrend(args[1:])
+	case "list":
+		return runHistoryList(args[1:])
+	case "import":
+		return runHistoryImport(args[1:])
+	default:
+		printHistoryUsage()
+		return 2
+	}
+}
+
+// printHistoryUsage prints the available history subcommands
+func printHistoryUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: benchmark history <command> [flags]")
+	fmt.Fprintln(os.Stderr, "  trend   Show one algorithm and operation over time")
+	fmt.Fprintln(os.Stderr, "  list    List recorded runs")
+	fmt.Fprintln(os.Stderr, "  import  Add existing results files to the history store")
+}
+
+// runHistoryTrend prints the trend of one metric across recorded runs
+func runHistoryTrend(args []string) int {
+	fs := flag.NewFlagSet("history trend", flag.ExitOnError)
+	store := fs.String("store", metrics.DefaultHistoryFile, "History store file")
+	algorithm := fs.String("algorithm", "ECDSA", "Algorithm to query")
+	operation := fs.String("operation", "sign", "Metric to query: "+strings.Join(metrics.Operations, ", "))
+	host := fs.String("host", "", "Only include runs from this host fingerprint")
+	goVersion := fs.String("go", "", "Only include runs built with this Go version")
+	circlVersion := fs.String("circl", "", "Only include runs linked against this circl version")
+	fs.Parse(args)
+
+	points, err := metrics.OpenHistoryStore(*store).Trend(metrics.TrendQuery{
+		Algorithm:       *algorithm,
+		Operation:       *operation,
+		HostFingerprint: *host,
+		GoVersion:       *goVersion,
+		CirclVersion:    *circlVersion,
+	})
+	if err != nil {
+		log.Fatalf("Trend query failed: %v", err)
+	}
+
+	if len(points) == 0 {
+		fmt.Printf("No runs recorded for %s %s\n", *algorithm, *operation)
+		return 0
+	}
+
+	fmt.Printf("%s %s over %d run(s)\n\n", *algorithm, *operation, len(points))
+	fmt.Printf("%-25s %-12s %-12s %-10s %-10s %12s %9s\n", "Timestamp", "Commit", "Host", "Go", "circl", "Value", "Change")
+	for i, p := range points {
+		change := ""
+		if i > 0 && points[i-1].Value > 0 {
+			change = fmt.Sprintf("%+.2f%%", (p.Value-points[i-1].Value)/points[i-1].Value*100)
+		}
+		fmt.Printf("%-25s %-12s %-12s %-10s %-10s %9.4f %-2s %9s\n",
+			p.Timestamp, shortCommit(p.Commit), p.HostFingerprint, p.GoVersion, p.CirclVersion, p.Value, p.Unit, change)
+	}
+
+	return 0
+}
+
+// runHistoryList prints one line per recorded run
+func runHistoryList(args []string) int {
+	fs := flag.NewFlagSet("history list", flag.ExitOnError)
+	store := fs.String("store", metrics.DefaultHistoryFile, "History store file")
+	fs.Parse(args)
+
+	entries, err := metrics.OpenHistoryStore(*store).Load()
+	if err != nil {
+		log.Fatalf("Failed to load history: %v", err)
+	}
+
+	fmt.Printf("%-25s %-12s %-12s %-10s %-10s %10s  %s\n", "Timestamp", "Commit", "Host", "Go", "circl", "Iterations", "Results File")
+	for _, e := range entries {
+		fmt.Printf("%-25s %-12s %-12s %-10s %-10s %10d  %s\n",
+			e.Timestamp, shortCommit(e.Commit), e.HostFingerprint, e.GoVersion, e.CirclVersion, e.Iterations, e.ResultsFile)
+	}
+
+	return 0
+}
+
+// runHistoryImport backfills the store from results files written by earlier runs
+func runHistoryImport(args []string) int {
+	fs := flag.NewFlagSet("history import", flag.ExitOnError)
+	store := fs.String("store", metrics.DefaultHistoryFile, "History store file")
+	commit := fs.String("commit", "", "Commit that produced the imported files")
+	host := fs.String("host", "", "Host fingerprint of the machine that produced the files")
+	goVersion := fs.String("go", "", "Go version that built the benchmark")
+	circlVersion := fs.String("circl", "", "circl version linked into the benchmark")
+	fs.Parse(args)
+
+	history := metrics.OpenHistoryStore(*store)
+	for _, filename := range fs.Args() {
+		result, err := metrics.LoadResults(filename)
+		if err != nil {
+			log.Fatalf("Failed to import %s: %v", filename, err)
+		}
+
+		// Files with a recorded environment supply their own key; flags override it
+		key := metrics.HistoryKey{Commit: "unknown", HostFingerprint: "unknown", GoVersion: "unknown", CirclVersion: "unknown"}
+		if result.Environment != nil {
+			key = result.Environment.HistoryKey()
+		}
+		overrideKey(&key.Commit, *commit)
+		overrideKey(&key.HostFingerprint, *host)
+		overrideKey(&key.GoVersion, *goVersion)
+		overrideKey(&key.CirclVersion, *circlVersion)
+
+		if err := history.Append(metrics.NewHistoryEntry(*result, filename, key)); err != nil {
+			log.Fatalf("Failed to import %s: %v", filename, err)
+		}
+		fmt.Printf("Imported %s\n", filename)
+	}
+
+	return 0
+}
+
+// overrideKey replaces a history key field when a flag value was given
+func overrideKey(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+// shortCommit abbreviates a VCS revision for table output
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -0,0 +1,44 @@
+// Package hsm holds MSP keys in a PKCS#11 token, as Fabric's PKCS#11 BCCSP
+// does in production, so that the benchmark harness can sign with keys that
+// never leave the token. ECDSA P-256 uses CKM_ECDSA; ML-DSA uses the
+// CKM_ML_DSA mechanism of PKCS#11 3.2 on tokens that list it.
+//
+// The token is driven through cgo by github.com/miekg/pkcs11, so it is only
+// built with the pkcs11 build tag; without it Open fails with ErrNotBuilt.
+package hsm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// PINEnv holds the user PIN of the token when Config.PIN is empty, so that
+// it never has to appear on a command line
+const PINEnv = "CRYPTO_BENCHMARK_PKCS11_PIN"
+
+// ErrNotBuilt is returned by Open in binaries built without the pkcs11 tag
+var ErrNotBuilt = errors.New("PKCS#11 support is not built in; rebuild with -tags pkcs11")
+
+// Config identifies a token and how to log in to it
+type Config struct {
+	// Module is the path of the PKCS#11 library, such as libsofthsm2.so
+	Module string
+	// Slot selects the slot by ID; -1 accepts any slot with a matching label
+	Slot int
+	// TokenLabel selects the token by label; empty accepts any token in Slot
+	TokenLabel string
+	// PIN is the user PIN; empty reads it from PINEnv
+	PIN string
+}
+
+// pin returns the configured PIN
+func (c Config) pin() (string, error) {
+	if c.PIN != "" {
+		return c.PIN, nil
+	}
+	if pin := os.Getenv(PINEnv); pin != "" {
+		return pin, nil
+	}
+	return "", fmt.Errorf("no PKCS#11 PIN given; set %s", PINEnv)
+}
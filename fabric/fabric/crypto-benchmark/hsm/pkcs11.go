@@ -0,0 +1,480 @@
+//go:build pkcs11
+
+package hsm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"crypto-benchmark/msp"
+
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS#11 3.2 ML-DSA identifiers, which miekg/pkcs11 does not define yet
+const (
+	ckkMLDSA           = 0x0000004a
+	ckmMLDSAKeyPairGen = 0x0000001c
+	ckmMLDSA           = 0x0000001d
+	ckaParameterSet    = 0x0000061d
+	ckpMLDSA44         = 0x00000001
+	ckpMLDSA65         = 0x00000002
+	ckpMLDSA87         = 0x00000003
+)
+
+// oidP256 is the named curve of CKA_EC_PARAMS for P-256
+var oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+
+// Token is a logged-in PKCS#11 token. Its one session is shared by every key
+// and serialised with a mutex, since a PKCS#11 session handles one operation
+// at a time.
+type Token struct {
+	ctx        *pkcs11.Ctx
+	slot       uint
+	info       pkcs11.TokenInfo
+	mechanisms map[uint]bool
+
+	mu      sync.Mutex
+	session pkcs11.SessionHandle
+}
+
+// Open loads the module, finds the token and logs in as the user
+func Open(cfg Config) (*Token, error) {
+	pin, err := cfg.pin()
+	if err != nil {
+		return nil, err
+	}
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("failed to initialise %s: %v", cfg.Module, err)
+	}
+
+	t := &Token{ctx: ctx}
+	if err := t.open(cfg, pin); err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	return t, nil
+}
+
+// open selects the slot, opens the session and logs in
+func (t *Token) open(cfg Config, pin string) error {
+	slots, err := t.ctx.GetSlotList(true)
+	if err != nil {
+		return fmt.Errorf("failed to list slots: %v", err)
+	}
+	found := false
+	for _, slot := range slots {
+		if cfg.Slot >= 0 && uint(cfg.Slot) != slot {
+			continue
+		}
+		info, err := t.ctx.GetTokenInfo(slot)
+		if err != nil {
+			return fmt.Errorf("failed to read token in slot %d: %v", slot, err)
+		}
+		if cfg.TokenLabel != "" && strings.TrimRight(info.Label, " ") != cfg.TokenLabel {
+			continue
+		}
+		t.slot, t.info, found = slot, info, true
+		break
+	}
+	if !found {
+		return fmt.Errorf("no token with slot %d and label %q in %s", cfg.Slot, cfg.TokenLabel, cfg.Module)
+	}
+
+	mechanisms, err := t.ctx.GetMechanismList(t.slot)
+	if err != nil {
+		return fmt.Errorf("failed to list mechanisms: %v", err)
+	}
+	t.mechanisms = make(map[uint]bool)
+	for _, m := range mechanisms {
+		t.mechanisms[m.Mechanism] = true
+	}
+
+	t.session, err = t.ctx.OpenSession(t.slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return fmt.Errorf("failed to open session: %v", err)
+	}
+	err = t.ctx.Login(t.session, pkcs11.CKU_USER, pin)
+	if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		t.ctx.CloseSession(t.session)
+		return fmt.Errorf("failed to log in: %v", err)
+	}
+	return nil
+}
+
+// Close logs out and unloads the module. Session keys are destroyed with
+// the session.
+func (t *Token) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx.Logout(t.session)
+	err := t.ctx.CloseSession(t.session)
+	t.ctx.Finalize()
+	t.ctx.Destroy()
+	return err
+}
+
+// Name describes the token for the results file
+func (t *Token) Name() string {
+	return fmt.Sprintf("pkcs11:%s %s/%s", strings.TrimRight(t.info.Label, " "),
+		strings.TrimRight(t.info.ManufacturerID, " "), strings.TrimRight(t.info.Model, " "))
+}
+
+// Supports reports whether the token lists the key generation and signing
+// mechanisms of an algorithm
+func (t *Token) Supports(algorithm msp.SignatureAlgorithm) bool {
+	switch algorithm {
+	case msp.ECDSA:
+		return t.mechanisms[pkcs11.CKM_EC_KEY_PAIR_GEN] && t.mechanisms[pkcs11.CKM_ECDSA]
+	case msp.MLDSA44, msp.MLDSA65, msp.MLDSA87:
+		return t.mechanisms[ckmMLDSAKeyPairGen] && t.mechanisms[ckmMLDSA]
+	default:
+		return false
+	}
+}
+
+// GenerateKey generates a session key pair, which the token destroys when
+// the session closes. It implements msp.KeyProvider.
+func (t *Token) GenerateKey(algorithm msp.SignatureAlgorithm) (msp.KeySigner, error) {
+	return t.generate(algorithm, "", false)
+}
+
+// GenerateTokenKey generates a key pair stored on the token under a label
+func (t *Token) GenerateTokenKey(algorithm msp.SignatureAlgorithm, label string) (msp.KeySigner, error) {
+	if label == "" {
+		return nil, fmt.Errorf("token keys need a label")
+	}
+	return t.generate(algorithm, label, true)
+}
+
+// generate generates a key pair whose private key is sensitive and can never
+// be extracted
+func (t *Token) generate(algorithm msp.SignatureAlgorithm, label string, persistent bool) (msp.KeySigner, error) {
+	if !t.Supports(algorithm) {
+		return nil, fmt.Errorf("token %s does not support %s", t.Name(), algorithm)
+	}
+	public := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, persistent),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+	}
+	private := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, persistent),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+	}
+	if label != "" {
+		public = append(public, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+		private = append(private, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+	}
+
+	var mechanism uint
+	if algorithm == msp.ECDSA {
+		ecParams, err := asn1.Marshal(oidP256)
+		if err != nil {
+			return nil, err
+		}
+		mechanism = pkcs11.CKM_EC_KEY_PAIR_GEN
+		public = append(public,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams))
+		private = append(private, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC))
+	} else {
+		mechanism = ckmMLDSAKeyPairGen
+		public = append(public,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, ckkMLDSA),
+			pkcs11.NewAttribute(ckaParameterSet, mldsaParameterSet(algorithm)))
+		private = append(private, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, ckkMLDSA))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	publicHandle, privateHandle, err := t.ctx.GenerateKeyPair(t.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, public, private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key pair: %v", algorithm, err)
+	}
+	k, err := t.newKey(algorithm, publicHandle, privateHandle)
+	if err != nil {
+		return nil, err
+	}
+	k.session = !persistent
+	return k, nil
+}
+
+// FindKey returns the token key pair stored under a label
+func (t *Token) FindKey(algorithm msp.SignatureAlgorithm, label string) (msp.KeySigner, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	publicHandle, err := t.findOne(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	privateHandle, err := t.findOne(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.newKey(algorithm, publicHandle, privateHandle)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// DeleteKey destroys every object stored under a label
+func (t *Token) DeleteKey(label string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	handles, err := t.find([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_LABEL, label)})
+	if err != nil {
+		return err
+	}
+	if len(handles) == 0 {
+		return fmt.Errorf("no objects labelled %q", label)
+	}
+	for _, h := range handles {
+		if err := t.ctx.DestroyObject(t.session, h); err != nil {
+			return fmt.Errorf("failed to destroy %q: %v", label, err)
+		}
+	}
+	return nil
+}
+
+// CheckNotExtractable confirms that the token will not release a private key:
+// it must be sensitive and non-extractable, and reading its value must fail
+func (t *Token) CheckNotExtractable(signer msp.KeySigner) error {
+	k, ok := signer.(*key)
+	if !ok || k.token != t {
+		return fmt.Errorf("not a key of token %s", t.Name())
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	attrs, err := t.ctx.GetAttributeValue(t.session, k.private, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read private key attributes: %v", err)
+	}
+	if !attrBool(attrs[0]) || attrBool(attrs[1]) {
+		return fmt.Errorf("private key is not sensitive or is extractable")
+	}
+	_, err = t.ctx.GetAttributeValue(t.session, k.private, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil)})
+	if err == nil {
+		return fmt.Errorf("token released the private key value")
+	}
+	return nil
+}
+
+// findOne returns the single object of a class stored under a label
+func (t *Token) findOne(class uint, label string) (pkcs11.ObjectHandle, error) {
+	handles, err := t.find([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(handles) != 1 {
+		return 0, fmt.Errorf("found %d objects of class %d labelled %q, expected 1", len(handles), class, label)
+	}
+	return handles[0], nil
+}
+
+// find returns every object matching a template
+func (t *Token) find(template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	if err := t.ctx.FindObjectsInit(t.session, template); err != nil {
+		return nil, fmt.Errorf("failed to search token: %v", err)
+	}
+	defer t.ctx.FindObjectsFinal(t.session)
+	var all []pkcs11.ObjectHandle
+	for {
+		handles, _, err := t.ctx.FindObjects(t.session, 16)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search token: %v", err)
+		}
+		if len(handles) == 0 {
+			return all, nil
+		}
+		all = append(all, handles...)
+	}
+}
+
+// newKey reads and encodes the public key of a key pair, checking that it
+// belongs to the algorithm
+func (t *Token) newKey(algorithm msp.SignatureAlgorithm, publicHandle, privateHandle pkcs11.ObjectHandle) (*key, error) {
+	k := &key{token: t, algorithm: algorithm, publicHandle: publicHandle, private: privateHandle}
+	var err error
+	if algorithm == msp.ECDSA {
+		k.public, err = t.ecdsaPublicKey(publicHandle)
+	} else {
+		k.public, err = t.mldsaPublicKey(algorithm, publicHandle)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// ecdsaPublicKey encodes a P-256 public key object as PKIX DER
+func (t *Token) ecdsaPublicKey(h pkcs11.ObjectHandle) ([]byte, error) {
+	attrs, err := t.ctx.GetAttributeValue(t.session, h, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ECDSA public key: %v", err)
+	}
+	var curve asn1.ObjectIdentifier
+	if attrUint(attrs[0]) != pkcs11.CKK_EC {
+		return nil, fmt.Errorf("key is not an EC key")
+	}
+	if rest, err := asn1.Unmarshal(attrs[1].Value, &curve); err != nil || len(rest) != 0 || !curve.Equal(oidP256) {
+		return nil, fmt.Errorf("EC key is not on P-256")
+	}
+
+	// CKA_EC_POINT is a DER OCTET STRING, though some tokens return the raw point
+	point := attrs[2].Value
+	var wrapped []byte
+	if rest, err := asn1.Unmarshal(point, &wrapped); err == nil && len(rest) == 0 {
+		point = wrapped
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), point)
+	if x == nil {
+		return nil, fmt.Errorf("invalid EC point")
+	}
+	return x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+}
+
+// mldsaPublicKey returns the FIPS 204 encoding of an ML-DSA public key object
+func (t *Token) mldsaPublicKey(algorithm msp.SignatureAlgorithm, h pkcs11.ObjectHandle) ([]byte, error) {
+	attrs, err := t.ctx.GetAttributeValue(t.session, h, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+		pkcs11.NewAttribute(ckaParameterSet, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ML-DSA public key: %v", err)
+	}
+	if attrUint(attrs[0]) != ckkMLDSA || attrUint(attrs[1]) != mldsaParameterSet(algorithm) {
+		return nil, fmt.Errorf("key is not an %s key", algorithm)
+	}
+	return attrs[2].Value, nil
+}
+
+// mldsaParameterSet returns the CKA_PARAMETER_SET value of an ML-DSA algorithm
+func mldsaParameterSet(algorithm msp.SignatureAlgorithm) uint {
+	switch algorithm {
+	case msp.MLDSA44:
+		return ckpMLDSA44
+	case msp.MLDSA65:
+		return ckpMLDSA65
+	default:
+		return ckpMLDSA87
+	}
+}
+
+// key is a private key on the token; it implements msp.KeySigner
+type key struct {
+	token        *Token
+	algorithm    msp.SignatureAlgorithm
+	publicHandle pkcs11.ObjectHandle
+	private      pkcs11.ObjectHandle
+	public       []byte
+	session      bool // Generated by GenerateKey, so Destroy removes it
+	destroyed    bool // Guarded by token.mu
+}
+
+func (k *key) PublicKey() []byte {
+	return append([]byte(nil), k.public...)
+}
+
+// Sign signs a hash with CKM_ECDSA, converting the raw r || s to ASN.1, or
+// with CKM_ML_DSA and no parameters: hedged, with the empty context
+func (k *key) Sign(hash []byte) ([]byte, error) {
+	mechanism := uint(ckmMLDSA)
+	if k.algorithm == msp.ECDSA {
+		mechanism = pkcs11.CKM_ECDSA
+	}
+
+	k.token.mu.Lock()
+	defer k.token.mu.Unlock()
+	if k.destroyed {
+		return nil, fmt.Errorf("session key has been destroyed")
+	}
+	if err := k.token.ctx.SignInit(k.token.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, k.private); err != nil {
+		return nil, fmt.Errorf("failed to start signing: %v", err)
+	}
+	signature, err := k.token.ctx.Sign(k.token.session, hash)
+	if err != nil {
+		return nil, fmt.Errorf("token failed to sign: %v", err)
+	}
+	if k.algorithm != msp.ECDSA {
+		return signature, nil
+	}
+
+	if len(signature) != 64 {
+		return nil, fmt.Errorf("ECDSA signature is %d bytes, expected 64", len(signature))
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
+
+// Destroy destroys a session key pair with C_DestroyObject, so that a
+// benchmark generating a key per iteration does not fill the token before
+// its session closes. Token keys are kept; DeleteKey removes them. The MSP
+// calls it from Close once no other MSP or Signer shares the key.
+func (k *key) Destroy() error {
+	if !k.session {
+		return nil
+	}
+	k.token.mu.Lock()
+	defer k.token.mu.Unlock()
+	if k.destroyed {
+		return nil
+	}
+	k.destroyed = true
+	if err := k.token.ctx.DestroyObject(k.token.session, k.private); err != nil {
+		return fmt.Errorf("failed to destroy session private key: %v", err)
+	}
+	if err := k.token.ctx.DestroyObject(k.token.session, k.publicHandle); err != nil {
+		return fmt.Errorf("failed to destroy session public key: %v", err)
+	}
+	return nil
+}
+
+// attrBool decodes a CK_BBOOL attribute
+func attrBool(a *pkcs11.Attribute) bool {
+	return len(a.Value) == 1 && a.Value[0] != 0
+}
+
+// attrUint decodes a CK_ULONG attribute, which is in host byte order
+func attrUint(a *pkcs11.Attribute) uint {
+	switch len(a.Value) {
+	case 4:
+		return uint(binary.NativeEndian.Uint32(a.Value))
+	case 8:
+		return uint(binary.NativeEndian.Uint64(a.Value))
+	default:
+		return 0
+	}
+}
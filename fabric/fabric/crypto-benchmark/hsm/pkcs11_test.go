@@ -0,0 +1,300 @@
+//go:build pkcs11
+
+package hsm
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+
+	"crypto-benchmark/msp"
+
+	"github.com/miekg/pkcs11"
+)
+
+// The tests run against a SoftHSMv2 token set up as in the README:
+//
+//	export SOFTHSM2_CONF=$PWD/softhsm2.conf
+//	softhsm2-util --init-token --free --label fabric --so-pin 1234 --pin 98765432
+//	export CRYPTO_BENCHMARK_PKCS11_PIN=98765432
+//	go test -tags pkcs11 ./hsm
+//
+// They are skipped when the module, SOFTHSM2_CONF or the PIN is missing.
+// SOFTHSM2_MODULE overrides the module path and SOFTHSM2_TOKEN_LABEL the
+// token label, which defaults to any token.
+
+// softHSMModules are the usual install paths of the SoftHSMv2 module
+var softHSMModules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib/aarch64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+}
+
+// testConfig returns the SoftHSMv2 token configuration, skipping the test if
+// there is none
+func testConfig(t *testing.T) Config {
+	t.Helper()
+	if os.Getenv("SOFTHSM2_CONF") == "" {
+		t.Skip("SOFTHSM2_CONF is not set")
+	}
+	if os.Getenv(PINEnv) == "" {
+		t.Skipf("%s is not set", PINEnv)
+	}
+	modules := softHSMModules
+	if module := os.Getenv("SOFTHSM2_MODULE"); module != "" {
+		modules = []string{module}
+	}
+	for _, module := range modules {
+		if _, err := os.Stat(module); err == nil {
+			return Config{Module: module, Slot: -1, TokenLabel: os.Getenv("SOFTHSM2_TOKEN_LABEL")}
+		}
+	}
+	t.Skip("SoftHSMv2 module not found; set SOFTHSM2_MODULE")
+	return Config{}
+}
+
+// openToken opens the test token and closes it when the test ends
+func openToken(t *testing.T) *Token {
+	t.Helper()
+	token, err := Open(testConfig(t))
+	if err != nil {
+		t.Fatalf("failed to open token: %v", err)
+	}
+	t.Cleanup(func() { token.Close() })
+	return token
+}
+
+// supportedAlgorithms returns the algorithms the token lists mechanisms for
+func supportedAlgorithms(t *testing.T, token *Token) []msp.SignatureAlgorithm {
+	t.Helper()
+	var algorithms []msp.SignatureAlgorithm
+	for _, algorithm := range []msp.SignatureAlgorithm{msp.ECDSA, msp.MLDSA44, msp.MLDSA65, msp.MLDSA87} {
+		if token.Supports(algorithm) {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	if len(algorithms) == 0 {
+		t.Skipf("token %s supports none of the algorithms", token.Name())
+	}
+	return algorithms
+}
+
+// testLabel returns a label no other test run uses
+func testLabel(t *testing.T, algorithm msp.SignatureAlgorithm) string {
+	t.Helper()
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	return "crypto-benchmark-test-" + algorithm.String() + "-" + hex.EncodeToString(suffix)
+}
+
+// TestOpen checks that Open finds the token and fails for a missing one. The
+// failures come first, since a failed Open finalises the module.
+func TestOpen(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.TokenLabel = "crypto-benchmark-no-such-token"
+	if other, err := Open(cfg); err == nil {
+		other.Close()
+		t.Error("opened a token that does not exist")
+	}
+	cfg = testConfig(t)
+	cfg.Module = "/nonexistent/libpkcs11.so"
+	if _, err := Open(cfg); err == nil {
+		t.Error("opened a module that does not exist")
+	}
+
+	token := openToken(t)
+	if token.Name() == "" {
+		t.Error("token has no name")
+	}
+	if token.Supports(msp.LMSHSS) || token.Supports(msp.XMSSMT) {
+		t.Error("token claims to support a stateful algorithm")
+	}
+}
+
+// TestSessionKey signs through an MSP whose key the token generates, and
+// verifies in software against the token's public key
+func TestSessionKey(t *testing.T) {
+	token := openToken(t)
+	for _, algorithm := range supportedAlgorithms(t, token) {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			m, err := msp.NewEnhancedMSPWithOptions(context.Background(), algorithm, msp.Options{KeyProvider: token})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+			if _, err := m.GetPrivateKeyBytes(); !errors.Is(err, msp.ErrKeyNotExtractable) {
+				t.Errorf("GetPrivateKeyBytes returned %v, expected ErrKeyNotExtractable", err)
+			}
+
+			message := []byte("PKCS#11 session key")
+			signature, err := m.Sign(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			publicKey, err := m.GetPublicKeyBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifier, err := msp.NewEnhancedMSPFromPublicKey(algorithm, publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid, err := verifier.Verify(message, signature); !valid {
+				t.Errorf("token signature does not verify in software: %v", err)
+			}
+			if valid, _ := verifier.Verify(append(message, '!'), signature); valid {
+				t.Error("token signature verifies a tampered message")
+			}
+			if _, err := m.SignWithContextString(context.Background(), message, []byte("fabric/proposal/v1")); err == nil {
+				t.Error("token key signed with a context string")
+			}
+
+			clone, err := m.Clone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer clone.Close()
+			signature, err = clone.Sign(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid, err := verifier.Verify(message, signature); !valid {
+				t.Errorf("clone's signature does not verify: %v", err)
+			}
+		})
+	}
+}
+
+// TestSessionKeyDestroyed checks that Close destroys a session key on the
+// token once no clone of the MSP shares it
+func TestSessionKeyDestroyed(t *testing.T) {
+	token := openToken(t)
+	exists := func(k *key) bool {
+		token.mu.Lock()
+		defer token.mu.Unlock()
+		_, err := token.ctx.GetAttributeValue(token.session, k.private, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, nil),
+		})
+		return err == nil
+	}
+
+	for _, algorithm := range supportedAlgorithms(t, token) {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			signer, err := token.GenerateKey(algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := msp.NewEnhancedMSPFromKeySigner(algorithm, signer, msp.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if exists(signer.(*key)) {
+				t.Error("session key left on the token after Close")
+			}
+
+			shared, err := token.GenerateKey(algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer shared.(*key).Destroy()
+			m, err = msp.NewEnhancedMSPFromKeySigner(algorithm, shared, msp.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			clone, err := m.Clone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer clone.Close()
+			if err := m.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := clone.Sign([]byte("PKCS#11 shared session key")); err != nil {
+				t.Errorf("clone stopped signing once the original was closed: %v", err)
+			}
+		})
+	}
+}
+
+// TestTokenKey stores a labelled key on the token, finds it again and
+// deletes it
+func TestTokenKey(t *testing.T) {
+	token := openToken(t)
+	for _, algorithm := range supportedAlgorithms(t, token) {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			label := testLabel(t, algorithm)
+			generated, err := token.GenerateTokenKey(algorithm, label)
+			if err != nil {
+				t.Fatal(err)
+			}
+			deleted := false
+			defer func() {
+				if !deleted {
+					token.DeleteKey(label)
+				}
+			}()
+			if err := token.CheckNotExtractable(generated); err != nil {
+				t.Error(err)
+			}
+
+			found, err := token.FindKey(algorithm, label)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(found.PublicKey(), generated.PublicKey()) {
+				t.Fatal("found key has another public key")
+			}
+			// Through an MSP, which normalises ECDSA signatures to low-S
+			m, err := msp.NewEnhancedMSPFromKeySigner(algorithm, found, msp.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			message := []byte("PKCS#11 token key")
+			signature, err := m.Sign(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifier, err := msp.NewEnhancedMSPFromPublicKey(algorithm, generated.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid, err := verifier.Verify(message, signature); !valid {
+				t.Errorf("found key's signature does not verify: %v", err)
+			}
+
+			// Closing the MSP leaves a token key on the token
+			if err := m.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := token.FindKey(algorithm, label); err != nil {
+				t.Errorf("token key gone once its MSP was closed: %v", err)
+			}
+
+			if err := token.DeleteKey(label); err != nil {
+				t.Fatal(err)
+			}
+			deleted = true
+			if _, err := token.FindKey(algorithm, label); err == nil {
+				t.Error("key found after it was deleted")
+			}
+			if err := token.DeleteKey(label); err == nil {
+				t.Error("deleting a deleted key succeeded")
+			}
+		})
+	}
+
+	if _, err := token.GenerateTokenKey(msp.ECDSA, ""); err == nil {
+		t.Error("generated a token key without a label")
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !pkcs11
+
+package hsm
+
+import "crypto-benchmark/msp"
+
+// Token is a logged-in PKCS#11 token; this build has no PKCS#11 support
+type Token struct{}
+
+// Open fails with ErrNotBuilt
+func Open(cfg Config) (*Token, error) {
+	return nil, ErrNotBuilt
+}
+
+func (t *Token) Close() error { return ErrNotBuilt }
+
+func (t *Token) Name() string { return "pkcs11 (not built)" }
+
+func (t *Token) Supports(algorithm msp.SignatureAlgorithm) bool { return false }
+
+func (t *Token) GenerateKey(algorithm msp.SignatureAlgorithm) (msp.KeySigner, error) {
+	return nil, ErrNotBuilt
+}
+
+func (t *Token) GenerateTokenKey(algorithm msp.SignatureAlgorithm, label string) (msp.KeySigner, error) {
+	return nil, ErrNotBuilt
+}
+
+func (t *Token) FindKey(algorithm msp.SignatureAlgorithm, label string) (msp.KeySigner, error) {
+	return nil, ErrNotBuilt
+}
+
+func (t *Token) DeleteKey(label string) error { return ErrNotBuilt }
+
+func (t *Token) CheckNotExtractable(signer msp.KeySigner) error { return ErrNotBuilt }
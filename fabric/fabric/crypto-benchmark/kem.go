@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"crypto-benchmark/kem"
+	"fmt"
+)
+
+// parseKEMList parses a comma-separated list of KEM names
+func parseKEMList(list string) ([]kem.Algorithm, error) {
+	var algorithms []kem.Algorithm
+	for _, name := range splitList(list) {
+		algorithm, err := kem.ParseAlgorithm(name)
+		if err != nil {
+			return nil, err
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms, nil
+}
+
+// validateKEMs checks that every KEM round-trips a shared key, that a modified
+// ciphertext does not yield the same key, and that malformed encapsulation keys
+// and ciphertexts are refused
+func validateKEMs(algorithms []kem.Algorithm) error {
+	for _, algorithm := range algorithms {
+		k, err := kem.New(algorithm)
+		if err != nil {
+			return err
+		}
+		key, err := k.GenerateKey()
+		if err != nil {
+			return fmt.Errorf("%s: %v", algorithm, err)
+		}
+		encapsulationKey := key.EncapsulationKey()
+		if len(encapsulationKey) != k.EncapsulationKeySize() {
+			return fmt.Errorf("%s: encapsulation key is %d bytes, expected %d", algorithm, len(encapsulationKey), k.EncapsulationKeySize())
+		}
+
+		ciphertext, sharedKey, err := k.Encapsulate(encapsulationKey)
+		if err != nil {
+			return fmt.Errorf("%s: encapsulation failed: %v", algorithm, err)
+		}
+		if len(ciphertext) != k.CiphertextSize() || len(sharedKey) != k.SharedKeySize() {
+			return fmt.Errorf("%s: unexpected ciphertext or shared key size", algorithm)
+		}
+		decapsulated, err := key.Decapsulate(ciphertext)
+		if err != nil || !bytes.Equal(sharedKey, decapsulated) {
+			return fmt.Errorf("%s: decapsulation did not recover the shared key (err=%v)", algorithm, err)
+		}
+
+		// A second encapsulation must produce a different shared key
+		_, otherKey, err := k.Encapsulate(encapsulationKey)
+		if err != nil {
+			return fmt.Errorf("%s: encapsulation failed: %v", algorithm, err)
+		}
+		if bytes.Equal(sharedKey, otherKey) {
+			return fmt.Errorf("%s: two encapsulations produced the same shared key", algorithm)
+		}
+
+		// The private key must survive encoding
+		privateKey, err := key.Bytes()
+		if err != nil {
+			return fmt.Errorf("%s: %v", algorithm, err)
+		}
+		decoded, err := k.NewDecapsulationKey(privateKey)
+		if err != nil {
+			return fmt.Errorf("%s: decoding private key failed: %v", algorithm, err)
+		}
+		if again, err := decoded.Decapsulate(ciphertext); err != nil || !bytes.Equal(sharedKey, again) {
+			return fmt.Errorf("%s: decoded private key did not recover the shared key", algorithm)
+		}
+
+		// A modified ciphertext must not decapsulate to the same key; ML-KEM
+		// returns an implicit-rejection key instead of an error
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[len(tampered)-1] ^= 0x01
+		if wrong, err := key.Decapsulate(tampered); err == nil && bytes.Equal(wrong, sharedKey) {
+			return fmt.Errorf("%s: modified ciphertext decapsulated to the shared key", algorithm)
+		}
+
+		if _, _, err := k.Encapsulate(encapsulationKey[:len(encapsulationKey)-1]); err == nil {
+			return fmt.Errorf("%s: truncated encapsulation key accepted", algorithm)
+		}
+		if _, err := key.Decapsulate(ciphertext[:len(ciphertext)-1]); err == nil {
+			return fmt.Errorf("%s: truncated ciphertext accepted", algorithm)
+		}
+
+		fmt.Printf("  ✓ %s validation passed (%d-byte ciphertext, %d-byte shared key)\n", algorithm, len(ciphertext), len(sharedKey))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package kem
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Metrics holds the performance metrics for one KEM
+type Metrics struct {
+	Algorithm             string  `json:"algorithm"`
+	KeygenTimeMs          float64 `json:"keygen_time_ms"`
+	EncapsulateTimeMs     float64 `json:"encapsulate_time_ms"`
+	DecapsulateTimeMs     float64 `json:"decapsulate_time_ms"`
+	EncapsulationKeyBytes int     `json:"encapsulation_key_bytes"`
+	DecapsulationKeyBytes int     `json:"decapsulation_key_bytes"`
+	CiphertextBytes       int     `json:"ciphertext_bytes"`
+	SharedKeyBytes        int     `json:"shared_key_bytes"`
+	NISTSecurityCategory  int     `json:"nist_security_category"`
+	Timestamp             string  `json:"timestamp"`
+
+	// Per-iteration samples, used for statistical comparison between runs
+	KeygenSamplesMs      []float64 `json:"keygen_samples_ms,omitempty"`
+	EncapsulateSamplesMs []float64 `json:"encapsulate_samples_ms,omitempty"`
+	DecapsulateSamplesMs []float64 `json:"decapsulate_samples_ms,omitempty"`
+}
+
+// Benchmark measures key generation, encapsulation and decapsulation. Every
+// iteration uses a fresh key pair, and each decapsulated key is checked
+// against the encapsulated one so that only working round trips are timed.
+func Benchmark(k KEM, iterations int) (*Metrics, error) {
+	metrics := &Metrics{
+		Algorithm:             k.Algorithm().String(),
+		EncapsulationKeyBytes: k.EncapsulationKeySize(),
+		DecapsulationKeyBytes: k.DecapsulationKeySize(),
+		CiphertextBytes:       k.CiphertextSize(),
+		SharedKeyBytes:        k.SharedKeySize(),
+		NISTSecurityCategory:  k.Algorithm().NISTSecurityCategory(),
+		Timestamp:             time.Now().Format(time.RFC3339),
+	}
+
+	keygenTimes := make([]time.Duration, iterations)
+	encapsulateTimes := make([]time.Duration, iterations)
+	decapsulateTimes := make([]time.Duration, iterations)
+	for i := 0; i < iterations; i++ {
+		start := time.Now()
+		key, err := k.GenerateKey()
+		keygenTimes[i] = atLeastMicrosecond(time.Since(start))
+		if err != nil {
+			return nil, fmt.Errorf("key generation failed: %v", err)
+		}
+		encapsulationKey := key.EncapsulationKey()
+
+		start = time.Now()
+		ciphertext, sharedKey, err := k.Encapsulate(encapsulationKey)
+		encapsulateTimes[i] = atLeastMicrosecond(time.Since(start))
+		if err != nil {
+			return nil, fmt.Errorf("encapsulation failed: %v", err)
+		}
+
+		start = time.Now()
+		decapsulated, err := key.Decapsulate(ciphertext)
+		decapsulateTimes[i] = atLeastMicrosecond(time.Since(start))
+		if err != nil {
+			return nil, fmt.Errorf("decapsulation failed: %v", err)
+		}
+		if !bytes.Equal(sharedKey, decapsulated) {
+			return nil, fmt.Errorf("decapsulated key does not match the encapsulated key")
+		}
+	}
+
+	metrics.KeygenTimeMs, metrics.KeygenSamplesMs = summarise(keygenTimes)
+	metrics.EncapsulateTimeMs, metrics.EncapsulateSamplesMs = summarise(encapsulateTimes)
+	metrics.DecapsulateTimeMs, metrics.DecapsulateSamplesMs = summarise(decapsulateTimes)
+	return metrics, nil
+}
+
+// atLeastMicrosecond clamps a duration to the timer resolution the signature
+// benchmarks use
+func atLeastMicrosecond(d time.Duration) time.Duration {
+	if d < time.Microsecond {
+		return time.Microsecond
+	}
+	return d
+}
+
+// summarise returns the mean and the samples of durations in milliseconds
+func summarise(durations []time.Duration) (float64, []float64) {
+	if len(durations) == 0 {
+		return 0, nil
+	}
+	samples := make([]float64, len(durations))
+	var total time.Duration
+	for i, d := range durations {
+		samples[i] = float64(d.Nanoseconds()) / 1e6
+		total += d
+	}
+	return float64((total / time.Duration(len(durations))).Nanoseconds()) / 1e6, samples
+}
@@ -0,0 +1,204 @@
+// Package kem provides the key encapsulation mechanisms a post-quantum Fabric
+// deployment needs for key establishment, behind one interface: ML-KEM (FIPS
+// 203), classical X25519 and the X25519MLKEM768 hybrid used by TLS 1.3.
+package kem
+
+import (
+	"fmt"
+
+	"github.com/cloudflare/circl/hpke"
+	circlkem "github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/hybrid"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem1024"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem512"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+)
+
+// Algorithm represents the supported key encapsulation mechanisms
+type Algorithm int
+
+const (
+	MLKEM512 Algorithm = iota
+	MLKEM768
+	MLKEM1024
+	X25519
+	X25519MLKEM768
+)
+
+// Algorithms lists every supported KEM in reporting order
+var Algorithms = []Algorithm{MLKEM512, MLKEM768, MLKEM1024, X25519, X25519MLKEM768}
+
+// String returns the string representation of the algorithm
+func (a Algorithm) String() string {
+	switch a {
+	case MLKEM512:
+		return "ML-KEM-512"
+	case MLKEM768:
+		return "ML-KEM-768"
+	case MLKEM1024:
+		return "ML-KEM-1024"
+	case X25519:
+		return "X25519"
+	case X25519MLKEM768:
+		return "X25519MLKEM768"
+	default:
+		return "Unknown"
+	}
+}
+
+// NISTSecurityCategory returns the FIPS 203 security category, or 0 for
+// X25519, which offers no post-quantum security. The hybrid is as strong as
+// its ML-KEM-768 half.
+func (a Algorithm) NISTSecurityCategory() int {
+	switch a {
+	case MLKEM512:
+		return 1
+	case MLKEM768, X25519MLKEM768:
+		return 3
+	case MLKEM1024:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// ParseAlgorithm returns the algorithm with the given name
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for _, a := range Algorithms {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown KEM algorithm: %s", name)
+}
+
+// KEM establishes a shared key: the holder of a decapsulation key publishes
+// its encapsulation key, a peer encapsulates a fresh shared key to it, and
+// the holder recovers the same key from the ciphertext.
+type KEM interface {
+	Algorithm() Algorithm
+	// GenerateKey creates a new decapsulation key
+	GenerateKey() (DecapsulationKey, error)
+	// Encapsulate generates a shared key for an encoded encapsulation key and
+	// returns it with the ciphertext that carries it
+	Encapsulate(encapsulationKey []byte) (ciphertext, sharedKey []byte, err error)
+	// NewDecapsulationKey decodes a private key produced by DecapsulationKey.Bytes
+	NewDecapsulationKey(privateKey []byte) (DecapsulationKey, error)
+
+	EncapsulationKeySize() int
+	DecapsulationKeySize() int
+	CiphertextSize() int
+	SharedKeySize() int
+}
+
+// DecapsulationKey is the private half of a KEM key pair
+type DecapsulationKey interface {
+	// EncapsulationKey returns the encoded public key to give to peers
+	EncapsulationKey() []byte
+	// Bytes returns the encoded private key
+	Bytes() ([]byte, error)
+	// Decapsulate recovers the shared key from a ciphertext. ML-KEM rejects
+	// invalid ciphertexts implicitly, returning an unrelated pseudorandom key.
+	Decapsulate(ciphertext []byte) ([]byte, error)
+}
+
+// New returns the KEM for an algorithm
+func New(algorithm Algorithm) (KEM, error) {
+	var scheme circlkem.Scheme
+	switch algorithm {
+	case MLKEM512:
+		scheme = mlkem512.Scheme()
+	case MLKEM768:
+		scheme = mlkem768.Scheme()
+	case MLKEM1024:
+		scheme = mlkem1024.Scheme()
+	case X25519:
+		// DHKEM(X25519, HKDF-SHA256) of RFC 9180
+		scheme = hpke.KEM_X25519_HKDF_SHA256.Scheme()
+	case X25519MLKEM768:
+		scheme = hybrid.X25519MLKEM768()
+	default:
+		return nil, fmt.Errorf("unsupported KEM algorithm: %v", algorithm)
+	}
+	return &circlKEM{algorithm: algorithm, scheme: scheme}, nil
+}
+
+// circlKEM implements KEM with a Cloudflare CIRCL scheme
+type circlKEM struct {
+	algorithm Algorithm
+	scheme    circlkem.Scheme
+}
+
+// circlDecapsulationKey is a CIRCL private key with its encoded public key
+type circlDecapsulationKey struct {
+	scheme           circlkem.Scheme
+	privateKey       circlkem.PrivateKey
+	encapsulationKey []byte
+}
+
+func (k *circlKEM) Algorithm() Algorithm { return k.algorithm }
+
+func (k *circlKEM) EncapsulationKeySize() int { return k.scheme.PublicKeySize() }
+func (k *circlKEM) DecapsulationKeySize() int { return k.scheme.PrivateKeySize() }
+func (k *circlKEM) CiphertextSize() int       { return k.scheme.CiphertextSize() }
+func (k *circlKEM) SharedKeySize() int        { return k.scheme.SharedKeySize() }
+
+// GenerateKey creates a new decapsulation key from crypto/rand
+func (k *circlKEM) GenerateKey() (DecapsulationKey, error) {
+	publicKey, privateKey, err := k.scheme.GenerateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key pair: %v", k.algorithm, err)
+	}
+	return newCirclDecapsulationKey(k.scheme, publicKey, privateKey)
+}
+
+// Encapsulate decodes the encapsulation key, which must have the exact size of
+// the scheme, and encapsulates a fresh shared key to it
+func (k *circlKEM) Encapsulate(encapsulationKey []byte) ([]byte, []byte, error) {
+	if len(encapsulationKey) != k.scheme.PublicKeySize() {
+		return nil, nil, fmt.Errorf("invalid %s encapsulation key size: got %d bytes, expected %d",
+			k.algorithm, len(encapsulationKey), k.scheme.PublicKeySize())
+	}
+	publicKey, err := k.scheme.UnmarshalBinaryPublicKey(encapsulationKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s encapsulation key: %v", k.algorithm, err)
+	}
+	return k.scheme.Encapsulate(publicKey)
+}
+
+// NewDecapsulationKey decodes an encoded private key
+func (k *circlKEM) NewDecapsulationKey(privateKey []byte) (DecapsulationKey, error) {
+	if len(privateKey) != k.scheme.PrivateKeySize() {
+		return nil, fmt.Errorf("invalid %s decapsulation key size: got %d bytes, expected %d",
+			k.algorithm, len(privateKey), k.scheme.PrivateKeySize())
+	}
+	key, err := k.scheme.UnmarshalBinaryPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s decapsulation key: %v", k.algorithm, err)
+	}
+	return newCirclDecapsulationKey(k.scheme, key.Public(), key)
+}
+
+// newCirclDecapsulationKey encodes the public key once, as peers receive it
+func newCirclDecapsulationKey(scheme circlkem.Scheme, publicKey circlkem.PublicKey, privateKey circlkem.PrivateKey) (*circlDecapsulationKey, error) {
+	encapsulationKey, err := publicKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &circlDecapsulationKey{scheme: scheme, privateKey: privateKey, encapsulationKey: encapsulationKey}, nil
+}
+
+func (d *circlDecapsulationKey) EncapsulationKey() []byte {
+	return append([]byte(nil), d.encapsulationKey...)
+}
+
+func (d *circlDecapsulationKey) Bytes() ([]byte, error) {
+	return d.privateKey.MarshalBinary()
+}
+
+func (d *circlDecapsulationKey) Decapsulate(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) != d.scheme.CiphertextSize() {
+		return nil, fmt.Errorf("invalid ciphertext size: got %d bytes, expected %d", len(ciphertext), d.scheme.CiphertextSize())
+	}
+	return d.scheme.Decapsulate(d.privateKey, ciphertext)
+}
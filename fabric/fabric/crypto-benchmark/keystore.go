@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto-benchmark/keystore"
+	"crypto-benchmark/msp"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// passphraseEnv holds the keystore passphrase when no file is given. The
+// passphrase is never a flag, since flags are visible to every user of the host.
+const passphraseEnv = "CRYPTO_BENCHMARK_KEYSTORE_PASSPHRASE"
+
+// runKeystore implements the keystore command and returns the process exit code
+func runKeystore(args []string) int {
+	if len(args) == 0 {
+		printKeystoreUsage()
+		return 2
+	}
+
+	switch args[0] {
+	case "generate":
+		return runKeystoreGenerate(args[1:])
+	case "list":
+		return runKeystoreList(args[1:])
+	case "check":
+		return runKeystoreCheck(args[1:])
+	case "rotate":
+		return runKeystoreRotate(args[1:])
+	case "delete":
+		return runKeystoreDelete(args[1:])
+	default:
+		printKeystoreUsage()
+		return 2
+	}
+}
+
+// printKeystoreUsage prints the available keystore subcommands
+func printKeystoreUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: benchmark keystore <command> [flags]")
+	fmt.Fprintln(os.Stderr, "  generate  Generate a key and store it encrypted")
+	fmt.Fprintln(os.Stderr, "  list      List stored keys")
+	fmt.Fprintln(os.Stderr, "  check     Decrypt keys and sign and verify with them")
+	fmt.Fprintln(os.Stderr, "  rotate    Replace a key with a new key of the same algorithm")
+	fmt.Fprintln(os.Stderr, "  delete    Delete a key")
+	fmt.Fprintf(os.Stderr, "The passphrase is read from --passphrase-file or $%s.\n", passphraseEnv)
+}
+
+// keystoreFlags are the flags every keystore subcommand shares
+type keystoreFlags struct {
+	dir            *string
+	passphraseFile *string
+}
+
+func addKeystoreFlags(fs *flag.FlagSet) keystoreFlags {
+	return keystoreFlags{
+		dir:            fs.String("dir", "keystore", "Keystore directory"),
+		passphraseFile: fs.String("passphrase-file", "", "File holding the keystore passphrase (default $"+passphraseEnv+")"),
+	}
+}
+
+// open opens the keystore with the configured passphrase
+func (f keystoreFlags) open() *keystore.KeyStore {
+	var passphrase []byte
+	if *f.passphraseFile != "" {
+		data, err := os.ReadFile(*f.passphraseFile)
+		if err != nil {
+			log.Fatalf("Failed to read passphrase: %v", err)
+		}
+		passphrase = bytes.TrimRight(data, "\r\n")
+	} else {
+		passphrase = []byte(os.Getenv(passphraseEnv))
+	}
+	ks, err := keystore.Open(*f.dir, passphrase)
+	clear(passphrase)
+	if err != nil {
+		log.Fatalf("Failed to open keystore: %v", err)
+	}
+	return ks
+}
+
+// runKeystoreGenerate generates keys and stores them
+func runKeystoreGenerate(args []string) int {
+	fs := flag.NewFlagSet("keystore generate", flag.ExitOnError)
+	flags := addKeystoreFlags(fs)
+	algorithmList := fs.String("algorithms", "ML-DSA-65", "Comma-separated algorithms to generate one key each for")
+	fs.Parse(args)
+
+	ks := flags.open()
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		if algorithm.IsStateful() {
+			log.Fatalf("Cannot generate %s key: %v", algorithm, keystore.ErrStatefulKey)
+		}
+		m, err := msp.NewEnhancedMSPWithOptions(context.Background(), algorithm, msp.Options{})
+		if err != nil {
+			log.Fatalf("Failed to generate %s key: %v", algorithm, err)
+		}
+		start := time.Now()
+		entry, err := ks.Store(m)
+		m.Close()
+		if err != nil {
+			log.Fatalf("Failed to store %s key: %v", algorithm, err)
+		}
+		fmt.Printf("%s %s (sealed in %s)\n", entry.SKI, entry.Algorithm, time.Since(start).Round(time.Millisecond))
+	}
+	return 0
+}
+
+// runKeystoreList prints the metadata of every stored key
+func runKeystoreList(args []string) int {
+	fs := flag.NewFlagSet("keystore list", flag.ExitOnError)
+	flags := addKeystoreFlags(fs)
+	fs.Parse(args)
+
+	entries, err := flags.open().List()
+	if err != nil {
+		log.Fatalf("Failed to list keys: %v", err)
+	}
+	fmt.Printf("%-64s %-10s %-25s %s\n", "SKI", "Algorithm", "Created", "Rotated To")
+	for _, e := range entries {
+		fmt.Printf("%-64s %-10s %-25s %s\n", e.SKI, e.Algorithm, e.Created.Format(time.RFC3339), e.RotatedTo)
+	}
+	return 0
+}
+
+// runKeystoreCheck decrypts the given keys, or every key, and checks that each
+// signs a message its stored public key verifies
+func runKeystoreCheck(args []string) int {
+	fs := flag.NewFlagSet("keystore check", flag.ExitOnError)
+	flags := addKeystoreFlags(fs)
+	fs.Parse(args)
+
+	ks := flags.open()
+	skis := fs.Args()
+	if len(skis) == 0 {
+		entries, err := ks.List()
+		if err != nil {
+			log.Fatalf("Failed to list keys: %v", err)
+		}
+		for _, e := range entries {
+			skis = append(skis, e.SKI)
+		}
+	}
+
+	failed := 0
+	message := []byte("Keystore check message")
+	for _, ski := range skis {
+		start := time.Now()
+		m, err := ks.Load(ski, msp.Options{})
+		unlock := time.Since(start)
+		if err == nil {
+			err = checkStoredKey(m, message)
+			m.Close()
+		}
+		if err != nil {
+			fmt.Printf("  ✗ %s: %v\n", ski, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  ✓ %s %s (unlocked in %s)\n", ski, m.GetAlgorithm(), unlock.Round(time.Millisecond))
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d keys failed\n", failed, len(skis))
+		return 1
+	}
+	return 0
+}
+
+// checkStoredKey signs with a loaded key and verifies with its public key alone
+func checkStoredKey(m *msp.EnhancedMSP, message []byte) error {
+	signature, err := m.Sign(message)
+	if err != nil {
+		return err
+	}
+	publicKey, err := m.GetPublicKeyBytes()
+	if err != nil {
+		return err
+	}
+	verifier, err := msp.NewEnhancedMSPFromPublicKey(m.GetAlgorithm(), publicKey)
+	if err != nil {
+		return err
+	}
+	valid, err := verifier.Verify(message, signature)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("signature does not verify")
+	}
+	return nil
+}
+
+// runKeystoreRotate replaces keys with new keys of the same algorithms
+func runKeystoreRotate(args []string) int {
+	fs := flag.NewFlagSet("keystore rotate", flag.ExitOnError)
+	flags := addKeystoreFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		log.Fatalf("Usage: benchmark keystore rotate [flags] <SKI>...")
+	}
+
+	ks := flags.open()
+	for _, ski := range fs.Args() {
+		m, entry, err := ks.Rotate(ski, msp.Options{})
+		if err != nil {
+			log.Fatalf("Failed to rotate %s: %v", ski, err)
+		}
+		m.Close()
+		fmt.Printf("%s -> %s %s\n", ski, entry.SKI, entry.Algorithm)
+	}
+	return 0
+}
+
+// runKeystoreDelete deletes keys
+func runKeystoreDelete(args []string) int {
+	fs := flag.NewFlagSet("keystore delete", flag.ExitOnError)
+	flags := addKeystoreFlags(fs)
+	rotated := fs.Bool("rotated", false, "Delete every key that has been rotated")
+	fs.Parse(args)
+
+	ks := flags.open()
+	skis := fs.Args()
+	if *rotated {
+		entries, err := ks.List()
+		if err != nil {
+			log.Fatalf("Failed to list keys: %v", err)
+		}
+		for _, e := range entries {
+			if e.RotatedTo != "" {
+				skis = append(skis, e.SKI)
+			}
+		}
+	}
+	if len(skis) == 0 {
+		log.Fatalf("Usage: benchmark keystore delete [flags] [--rotated] <SKI>...")
+	}
+
+	for _, ski := range skis {
+		if err := ks.Delete(ski); err != nil {
+			log.Fatalf("Failed to delete %s: %v", ski, err)
+		}
+		fmt.Printf("Deleted %s\n", ski)
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"crypto-benchmark/msp"
+)
+
+// fuzzPassphrase seals the seed key files
+var fuzzPassphrase = []byte("fuzzing passphrase")
+
+// fuzzKDF keeps Argon2id cheap enough to run for every input
+var fuzzKDF = KDFParams{Algorithm: "argon2id", Time: 1, MemoryKiB: 64, Threads: 1}
+
+// FuzzLoadKeyFile decodes and decrypts untrusted key files. The file is named
+// after the SKI it claims, as in a keystore directory, and MaxKDF is fuzzKDF
+// so that an input cannot make Argon2id run for long. A file that loads
+// yields an MSP with the public key it records, which signs and verifies.
+//
+//	go test ./keystore -run '^$' -fuzz FuzzLoadKeyFile -fuzztime 30s
+func FuzzLoadKeyFile(f *testing.F) {
+	for _, algorithm := range []msp.SignatureAlgorithm{msp.ECDSA, msp.MLDSA44} {
+		ks, err := Open(f.TempDir(), fuzzPassphrase)
+		if err != nil {
+			f.Fatal(err)
+		}
+		ks.KDF = fuzzKDF
+		m, err := msp.NewEnhancedMSP(algorithm)
+		if err != nil {
+			f.Fatal(err)
+		}
+		entry, err := ks.Store(m)
+		m.Close()
+		if err != nil {
+			f.Fatal(err)
+		}
+		data, err := os.ReadFile(ks.path(entry.SKI))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+		f.Add(data[:len(data)/2])
+	}
+	f.Add([]byte("{}"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var claimed keyFile
+		ski := hex.EncodeToString(make([]byte, 32))
+		if json.Unmarshal(data, &claimed) == nil {
+			if decoded, err := hex.DecodeString(claimed.SKI); err == nil && len(decoded) == 32 {
+				ski = hex.EncodeToString(decoded)
+			}
+		}
+
+		ks, err := Open(t.TempDir(), fuzzPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ks.MaxKDF = fuzzKDF
+		if err := os.WriteFile(ks.path(ski), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		entries, listErr := ks.List()
+		m, err := ks.Load(ski, msp.Options{})
+		if err != nil {
+			return
+		}
+		defer m.Close()
+		if listErr != nil || len(entries) != 1 {
+			t.Fatalf("key loads but does not list: %v", listErr)
+		}
+		publicKey, err := m.GetPublicKeyBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(publicKey, entries[0].PublicKey) {
+			t.Fatalf("loaded key differs from the public key its file records")
+		}
+		message := []byte("keystore fuzzing")
+		signature, err := m.Sign(message)
+		if err != nil {
+			t.Fatalf("loaded key cannot sign: %v", err)
+		}
+		if valid, err := m.Verify(message, signature); !valid {
+			t.Fatalf("loaded key's signature does not verify: %v", err)
+		}
+	})
+}
@@ -0,0 +1,443 @@
+// Package keystore saves MSP private keys on disk encrypted under a
+// passphrase, so that a Fabric keystore/ folder on a shared host never holds
+// plaintext secrets. Each key is a JSON file named after its subject key
+// identifier (SKI), holding its metadata in the clear and its private key
+// sealed with AES-256-GCM under a key derived from the passphrase with
+// Argon2id (RFC 9106). The metadata is the additional authenticated data of
+// the seal, so it cannot be changed without the passphrase either.
+package keystore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"crypto-benchmark/msp"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// fileSuffix follows Fabric, which names private key files <SKI>_sk
+const fileSuffix = "_sk.json"
+
+// fileVersion is the version of the key file format
+const fileVersion = 1
+
+var (
+	// ErrKeyNotFound is returned for an SKI with no key file
+	ErrKeyNotFound = errors.New("key not found in keystore")
+	// ErrDecryption is returned when a key file does not open, because the
+	// passphrase is wrong or the file was modified
+	ErrDecryption = errors.New("wrong passphrase or tampered key file")
+	// ErrStatefulKey is returned when storing or rotating to an LMS-HSS or
+	// XMSS-MT key: the hbs package has not been checked against the RFC 8554
+	// and RFC 8391 test vectors, so its keys are for benchmarking only and
+	// must not become long-lived orderer or CA keys
+	ErrStatefulKey = errors.New("LMS-HSS and XMSS-MT keys are not kept in the keystore")
+)
+
+// KDFParams are the Argon2id parameters of one key file
+type KDFParams struct {
+	Algorithm string `json:"algorithm"`
+	Time      uint32 `json:"time"`       // Passes over memory
+	MemoryKiB uint32 `json:"memory_kib"` // Memory in KiB
+	Threads   uint8  `json:"threads"`
+	Salt      []byte `json:"salt,omitempty"`
+}
+
+// DefaultKDF is the second recommended option of RFC 9106: three passes over
+// 64 MiB with four lanes, which takes on the order of 100 ms
+var DefaultKDF = KDFParams{Algorithm: "argon2id", Time: 3, MemoryKiB: 64 * 1024, Threads: 4}
+
+// DefaultMaxKDF is four times DefaultKDF in passes, memory and lanes, which
+// leaves room to raise KDF without letting a key file cost gigabytes
+var DefaultMaxKDF = KDFParams{
+	Algorithm: "argon2id",
+	Time:      4 * DefaultKDF.Time,
+	MemoryKiB: 4 * DefaultKDF.MemoryKiB,
+	Threads:   4 * DefaultKDF.Threads,
+}
+
+const (
+	saltSize = 16
+	keySize  = 32 // AES-256
+)
+
+// check rejects parameters this package does not produce, and parameters
+// costlier than limit in passes, memory or lanes
+func (p KDFParams) check(limit KDFParams) error {
+	if p.Algorithm != "argon2id" {
+		return fmt.Errorf("unsupported key derivation function %q", p.Algorithm)
+	}
+	if p.Time < 1 || p.Threads < 1 || p.MemoryKiB < 8*uint32(p.Threads) {
+		return fmt.Errorf("invalid Argon2id parameters: time %d, memory %d KiB, threads %d", p.Time, p.MemoryKiB, p.Threads)
+	}
+	if p.Time > limit.Time || p.MemoryKiB > limit.MemoryKiB || p.Threads > limit.Threads {
+		return fmt.Errorf("Argon2id parameters exceed the limit: time %d, memory %d KiB, threads %d, at most %d, %d KiB, %d",
+			p.Time, p.MemoryKiB, p.Threads, limit.Time, limit.MemoryKiB, limit.Threads)
+	}
+	if len(p.Salt) < saltSize {
+		return fmt.Errorf("Argon2id salt is %d bytes, expected at least %d", len(p.Salt), saltSize)
+	}
+	return nil
+}
+
+// deriveKey derives the AES key of a file from the passphrase
+func (p KDFParams) deriveKey(passphrase []byte) []byte {
+	return argon2.IDKey(passphrase, p.Salt, p.Time, p.MemoryKiB, p.Threads, keySize)
+}
+
+// Entry is the metadata of a stored key
+type Entry struct {
+	SKI       string    `json:"ski"` // Hex SHA-256 of the public key
+	Algorithm string    `json:"algorithm"`
+	Created   time.Time `json:"created"`
+	PublicKey []byte    `json:"public_key"`
+	// RotatedTo is the SKI of the key that replaced this one. A rotated key
+	// is kept so that existing signatures still verify until it is deleted.
+	RotatedTo string `json:"rotated_to,omitempty"`
+}
+
+// keyFile is the content of a key file
+type keyFile struct {
+	Version int `json:"version"`
+	Entry
+	KDF        KDFParams `json:"kdf"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+// additionalData binds everything but the ciphertext into the seal
+func (f *keyFile) additionalData() ([]byte, error) {
+	header := *f
+	header.Nonce = nil
+	header.Ciphertext = nil
+	return json.Marshal(header)
+}
+
+// SKI returns the subject key identifier of an encoded public key
+func SKI(publicKey []byte) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyStore is a directory of encrypted key files sharing one passphrase
+type KeyStore struct {
+	dir        string
+	passphrase []byte
+	// KDF are the Argon2id parameters for keys stored from now on; each file
+	// records its own, so changing them does not affect existing keys
+	KDF KDFParams
+	// MaxKDF bounds the parameters a key file may ask for. They are read
+	// before the passphrase is checked, so without a bound anyone who can
+	// write to the directory could make opening a key allocate gigabytes.
+	MaxKDF KDFParams
+}
+
+// Open opens a keystore directory, creating it if needed
+func Open(dir string, passphrase []byte) (*KeyStore, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("keystore passphrase is empty")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create keystore directory: %v", err)
+	}
+	return &KeyStore{dir: dir, passphrase: append([]byte(nil), passphrase...), KDF: DefaultKDF, MaxKDF: DefaultMaxKDF}, nil
+}
+
+func (ks *KeyStore) path(ski string) string {
+	return filepath.Join(ks.dir, ski+fileSuffix)
+}
+
+// Store encrypts the private key of an MSP into a new key file. It fails if
+// the keystore already holds the key, or if the key is stateful.
+func (ks *KeyStore) Store(m *msp.EnhancedMSP) (*Entry, error) {
+	if m.GetAlgorithm().IsStateful() {
+		return nil, fmt.Errorf("%s: %w", m.GetAlgorithm(), ErrStatefulKey)
+	}
+	publicKey, err := m.GetPublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := m.GetPrivateKeyBytes()
+	if err != nil {
+		return nil, fmt.Errorf("cannot store a key without its private key: %v", err)
+	}
+	defer clear(privateKey)
+
+	entry := Entry{
+		SKI:       SKI(publicKey),
+		Algorithm: m.GetAlgorithm().String(),
+		Created:   time.Now().UTC().Round(0),
+		PublicKey: publicKey,
+	}
+	file, err := ks.seal(entry, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.write(file, false); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Load decrypts a key and returns an MSP that signs with it
+func (ks *KeyStore) Load(ski string, opts msp.Options) (*msp.EnhancedMSP, error) {
+	file, privateKey, err := ks.open(ski)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(privateKey)
+
+	algorithm, err := msp.ParseSignatureAlgorithm(file.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	m, err := msp.NewEnhancedMSPFromPrivateKey(algorithm, privateKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := m.GetPublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	if SKI(publicKey) != ski {
+		m.Close()
+		return nil, fmt.Errorf("private key of %s does not match its public key", ski)
+	}
+	return m, nil
+}
+
+// List returns the metadata of every key, oldest first. It reads the files
+// without the passphrase, so the metadata is only authenticated by Load.
+func (ks *KeyStore) List() ([]Entry, error) {
+	names, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %v", err)
+	}
+	var entries []Entry
+	for _, name := range names {
+		ski, ok := strings.CutSuffix(name.Name(), fileSuffix)
+		if !ok || name.IsDir() {
+			continue
+		}
+		file, err := ks.read(ski)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, file.Entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Created.Equal(entries[j].Created) {
+			return entries[i].Created.Before(entries[j].Created)
+		}
+		return entries[i].SKI < entries[j].SKI
+	})
+	return entries, nil
+}
+
+// Rotate replaces a key with a new key of the same algorithm, generated with
+// opts, and records the replacement in the old key's metadata. The old key
+// is kept until Delete, since signatures it made may still need verifying.
+func (ks *KeyStore) Rotate(ski string, opts msp.Options) (*msp.EnhancedMSP, *Entry, error) {
+	old, privateKey, err := ks.open(ski)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer clear(privateKey)
+	if old.RotatedTo != "" {
+		return nil, nil, fmt.Errorf("key %s was already rotated to %s", ski, old.RotatedTo)
+	}
+
+	algorithm, err := msp.ParseSignatureAlgorithm(old.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	if algorithm.IsStateful() {
+		return nil, nil, fmt.Errorf("%s: %w", algorithm, ErrStatefulKey)
+	}
+	m, err := msp.NewEnhancedMSPWithOptions(context.Background(), algorithm, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, err := ks.Store(m)
+	if err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+
+	old.Entry.RotatedTo = entry.SKI
+	file, err := ks.seal(old.Entry, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ks.write(file, true); err != nil {
+		return nil, nil, err
+	}
+	return m, entry, nil
+}
+
+// Delete removes a key file. The ciphertext may survive on the disk, but it
+// stays sealed under the passphrase.
+func (ks *KeyStore) Delete(ski string) error {
+	if _, err := ks.read(ski); err != nil {
+		return err
+	}
+	if err := os.Remove(ks.path(ski)); err != nil {
+		return fmt.Errorf("failed to delete key %s: %v", ski, err)
+	}
+	syncDir(ks.dir)
+	return nil
+}
+
+// seal encrypts a private key with a fresh salt and nonce
+func (ks *KeyStore) seal(entry Entry, privateKey []byte) (*keyFile, error) {
+	file := &keyFile{Version: fileVersion, Entry: entry, KDF: ks.KDF}
+	file.KDF.Salt = make([]byte, saltSize)
+	if _, err := rand.Read(file.KDF.Salt); err != nil {
+		return nil, err
+	}
+	if err := file.KDF.check(ks.MaxKDF); err != nil {
+		return nil, err
+	}
+
+	key := file.KDF.deriveKey(ks.passphrase)
+	defer clear(key)
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return nil, err
+	}
+	additionalData, err := file.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, privateKey, additionalData)
+	return file, nil
+}
+
+// open reads and decrypts a key file
+func (ks *KeyStore) open(ski string) (*keyFile, []byte, error) {
+	file, err := ks.read(ski)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := file.KDF.check(ks.MaxKDF); err != nil {
+		return nil, nil, fmt.Errorf("key file %s: %v", ski, err)
+	}
+
+	key := file.KDF.deriveKey(ks.passphrase)
+	defer clear(key)
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, nil, fmt.Errorf("key file %s: nonce is %d bytes, expected %d", ski, len(file.Nonce), aead.NonceSize())
+	}
+	additionalData, err := file.additionalData()
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey, err := aead.Open(nil, file.Nonce, file.Ciphertext, additionalData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("key %s: %w", ski, ErrDecryption)
+	}
+	return file, privateKey, nil
+}
+
+// read parses a key file without decrypting it
+func (ks *KeyStore) read(ski string) (*keyFile, error) {
+	data, err := os.ReadFile(ks.path(ski))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("key %s: %w", ski, ErrKeyNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %v", ski, err)
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("corrupt key file %s: %v", ski, err)
+	}
+	if file.Version != fileVersion {
+		return nil, fmt.Errorf("key file %s has version %d, expected %d", ski, file.Version, fileVersion)
+	}
+	if file.SKI != ski || SKI(file.PublicKey) != ski {
+		return nil, fmt.Errorf("key file %s does not hold key %s", ski, ski)
+	}
+	return &file, nil
+}
+
+// write saves a key file through a synced temporary file. A new key is
+// linked into place, which fails if the SKI is taken; an existing key is
+// replaced by renaming over it.
+func (ks *KeyStore) write(file *keyFile, replace bool) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(ks.dir, file.SKI+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write key: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write key: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync key: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write key: %v", err)
+	}
+	if replace {
+		err = os.Rename(tmp.Name(), ks.path(file.SKI))
+	} else {
+		err = os.Link(tmp.Name(), ks.path(file.SKI))
+	}
+	if os.IsExist(err) {
+		return fmt.Errorf("key %s is already in the keystore", file.SKI)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save key: %v", err)
+	}
+	syncDir(ks.dir)
+	return nil
+}
+
+// newAEAD returns AES-GCM under key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// syncDir makes a link, rename or removal in dir durable where the platform
+// allows syncing a directory; elsewhere it is left to the file system
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
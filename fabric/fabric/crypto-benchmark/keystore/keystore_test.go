@@ -0,0 +1,242 @@
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"crypto-benchmark/msp"
+)
+
+// testPassphrase seals the key files of the tests
+var testPassphrase = []byte("test passphrase")
+
+// testKDF keeps Argon2id cheap in tests
+var testKDF = KDFParams{Algorithm: "argon2id", Time: 1, MemoryKiB: 64, Threads: 1}
+
+// newTestKeyStore opens a keystore in a temporary directory
+func newTestKeyStore(t *testing.T, dir string, passphrase []byte) *KeyStore {
+	t.Helper()
+	ks, err := Open(dir, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.KDF = testKDF
+	return ks
+}
+
+// storeNewKey stores a fresh key of an algorithm and returns its MSP
+func storeNewKey(t *testing.T, ks *KeyStore, algorithm msp.SignatureAlgorithm) (*msp.EnhancedMSP, *Entry) {
+	t.Helper()
+	m, err := msp.NewEnhancedMSP(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	entry, err := ks.Store(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, entry
+}
+
+// editKeyFile rewrites a key file through its decoded fields
+func editKeyFile(t *testing.T, ks *KeyStore, ski string, edit func(*keyFile)) {
+	t.Helper()
+	data, err := os.ReadFile(ks.path(ski))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	edit(&file)
+	if data, err = json.Marshal(&file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ks.path(ski), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStoreLoad checks that a loaded key is the stored one
+func TestStoreLoad(t *testing.T) {
+	for _, algorithm := range []msp.SignatureAlgorithm{msp.ECDSA, msp.MLDSA44} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			ks := newTestKeyStore(t, t.TempDir(), testPassphrase)
+			original, entry := storeNewKey(t, ks, algorithm)
+			if entry.Algorithm != algorithm.String() || entry.RotatedTo != "" {
+				t.Errorf("stored entry %+v", entry)
+			}
+
+			loaded, err := ks.Load(entry.SKI, msp.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer loaded.Close()
+			message := []byte("keystore round trip")
+			signature, err := loaded.Sign(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid, err := original.Verify(message, signature); !valid {
+				t.Errorf("loaded key's signature does not verify with the stored key: %v", err)
+			}
+
+			entries, err := ks.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].SKI != entry.SKI || !entries[0].Created.Equal(entry.Created) {
+				t.Errorf("List returned %+v, expected the stored entry", entries)
+			}
+			if _, err := ks.Store(original); err == nil {
+				t.Error("stored the same key twice")
+			}
+		})
+	}
+}
+
+// TestLoadErrors checks that a wrong passphrase, modified metadata and a
+// missing key fail to load with the matching error
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	ks := newTestKeyStore(t, dir, testPassphrase)
+	_, entry := storeNewKey(t, ks, msp.ECDSA)
+
+	wrong := newTestKeyStore(t, dir, []byte("wrong passphrase"))
+	if _, err := wrong.Load(entry.SKI, msp.Options{}); !errors.Is(err, ErrDecryption) {
+		t.Errorf("wrong passphrase: %v, expected ErrDecryption", err)
+	}
+
+	// Every field outside the ciphertext is sealed as additional data
+	original, err := os.ReadFile(ks.path(entry.SKI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits := map[string]func(*keyFile){
+		"created":    func(f *keyFile) { f.Created = f.Created.Add(time.Hour) },
+		"rotated to": func(f *keyFile) { f.RotatedTo = SKI([]byte("another key")) },
+		"KDF time":   func(f *keyFile) { f.KDF.Time++ },
+	}
+	for name, edit := range edits {
+		editKeyFile(t, ks, entry.SKI, edit)
+		if _, err := ks.Load(entry.SKI, msp.Options{}); !errors.Is(err, ErrDecryption) {
+			t.Errorf("modified %s: %v, expected ErrDecryption", name, err)
+		}
+		if err := os.WriteFile(ks.path(entry.SKI), original, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := ks.Load(entry.SKI, msp.Options{})
+	if err != nil {
+		t.Fatalf("restored key file does not load: %v", err)
+	}
+	loaded.Close()
+
+	if _, err := ks.Load(SKI([]byte("missing")), msp.Options{}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("missing key: %v, expected ErrKeyNotFound", err)
+	}
+}
+
+// TestKDFLimit checks that a key file asking for costlier Argon2id parameters
+// than MaxKDF is refused before any key is derived
+func TestKDFLimit(t *testing.T) {
+	ks := newTestKeyStore(t, t.TempDir(), testPassphrase)
+	_, entry := storeNewKey(t, ks, msp.ECDSA)
+	editKeyFile(t, ks, entry.SKI, func(f *keyFile) { f.KDF.MemoryKiB = DefaultMaxKDF.MemoryKiB * 16 })
+
+	start := time.Now()
+	_, err := ks.Load(entry.SKI, msp.Options{})
+	if err == nil || errors.Is(err, ErrDecryption) {
+		t.Errorf("oversized KDF parameters: %v, expected a limit error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("refusing oversized KDF parameters took %v", elapsed)
+	}
+
+	ks.KDF = DefaultMaxKDF
+	ks.KDF.Threads++
+	m, err := msp.NewEnhancedMSP(msp.ECDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := ks.Store(m); err == nil {
+		t.Error("stored a key with KDF parameters over MaxKDF")
+	}
+}
+
+// TestRotate checks that Rotate records the new key in the old one, keeps the
+// old key loadable, and refuses to rotate it again
+func TestRotate(t *testing.T) {
+	ks := newTestKeyStore(t, t.TempDir(), testPassphrase)
+	_, old := storeNewKey(t, ks, msp.MLDSA44)
+
+	m, entry, err := ks.Rotate(old.SKI, msp.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if entry.SKI == old.SKI || entry.Algorithm != old.Algorithm {
+		t.Errorf("rotated to %+v from %+v", entry, old)
+	}
+	publicKey, err := m.GetPublicKeyBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if SKI(publicKey) != entry.SKI {
+		t.Error("rotated MSP does not hold the new key")
+	}
+
+	entries, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatedTo := make(map[string]string)
+	for _, e := range entries {
+		rotatedTo[e.SKI] = e.RotatedTo
+	}
+	if len(entries) != 2 || rotatedTo[old.SKI] != entry.SKI || rotatedTo[entry.SKI] != "" {
+		t.Errorf("after Rotate, List returned %+v", entries)
+	}
+	loaded, err := ks.Load(old.SKI, msp.Options{})
+	if err != nil {
+		t.Fatalf("rotated key no longer loads: %v", err)
+	}
+	loaded.Close()
+
+	if _, _, err := ks.Rotate(old.SKI, msp.Options{}); err == nil {
+		t.Error("rotated a key twice")
+	}
+	if entries, _ := ks.List(); len(entries) != 2 {
+		t.Errorf("failed rotation left %d keys, expected 2", len(entries))
+	}
+}
+
+// TestDelete checks that a deleted key is gone and the others stay
+func TestDelete(t *testing.T) {
+	ks := newTestKeyStore(t, t.TempDir(), testPassphrase)
+	_, deleted := storeNewKey(t, ks, msp.ECDSA)
+	_, kept := storeNewKey(t, ks, msp.ECDSA)
+
+	if err := ks.Delete(deleted.SKI); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Load(deleted.SKI, msp.Options{}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("deleted key: %v, expected ErrKeyNotFound", err)
+	}
+	if err := ks.Delete(deleted.SKI); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("second Delete: %v, expected ErrKeyNotFound", err)
+	}
+	entries, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].SKI != kept.SKI {
+		t.Errorf("after Delete, List returned %+v", entries)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto-benchmark/leakage"
+	"crypto-benchmark/msp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runLeakage implements the leakage command and returns the process exit code
+func runLeakage(args []string) int {
+	defaults := leakage.DefaultOptions()
+
+	fs := flag.NewFlagSet("leakage", flag.ExitOnError)
+	measurements := fs.Int("measurements", defaults.Measurements, "Measurements per test, split between the two classes")
+	threshold := fs.Float64("threshold", defaults.Threshold, "|t| above which a test reports a leak")
+	seed := fs.Int64("seed", defaults.Seed, "Seed for class assignment and random inputs")
+	signing := fs.String("signing", msp.SigningHedged.String(), "Signing mode: hedged or deterministic")
+	algorithmList := fs.String("algorithms", "ECDSA,ML-DSA-44,ML-DSA-65,ML-DSA-87", "Comma-separated algorithms to test")
+	jsonOut := fs.String("json", "", "Also write the results as JSON to this file")
+	fs.Parse(args)
+
+	signingMode, err := msp.ParseSigningMode(*signing)
+	if err != nil {
+		log.Fatalf("Invalid signing mode: %v", err)
+	}
+
+	opts := leakage.Options{Measurements: *measurements, Threshold: *threshold, Seed: *seed, Signing: signingMode}
+	fmt.Printf("Timing leakage test: %d measurements per test, threshold |t| > %g, %s signing (seed %d)\n",
+		opts.Measurements, opts.Threshold, opts.Signing, opts.Seed)
+
+	var results []leakage.AlgorithmResult
+	failed := false
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+
+		result, err := leakage.Run(algorithm, opts)
+		if err != nil {
+			log.Fatalf("Leakage test failed for %s: %v", name, err)
+		}
+		printLeakageResult(result)
+		if !result.Passed {
+			failed = true
+		}
+		results = append(results, result)
+	}
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+		if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+			log.Fatalf("Failed to write results: %v", err)
+		}
+		fmt.Printf("Results saved to: %s\n", *jsonOut)
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// printLeakageResult prints the verdict of each test of one algorithm
+func printLeakageResult(result leakage.AlgorithmResult) {
+	verdict := "PASS"
+	if !result.Passed {
+		verdict = "FAIL"
+	}
+	fmt.Printf("\n%s: %s\n", result.Algorithm, verdict)
+
+	for _, t := range result.Tests {
+		status := "✓"
+		switch {
+		case t.Leak && t.Informational:
+			status = "i"
+		case t.Leak:
+			status = "✗"
+		}
+		fmt.Printf("  %s %-13s t=%8.2f (crop p%g)  %s %.0f ns vs %s %.0f ns\n",
+			status, t.Name, t.T, t.CropPercentile, t.Class0, t.MeanNs[0], t.Class1, t.MeanNs[1])
+		if t.Leak && t.Note != "" {
+			fmt.Printf("      not counted: %s\n", t.Note)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package leakage implements a dudect-style timing leakage test. Two classes of
+// inputs are measured in random interleaved order and their timing
+// distributions compared with Welch's t-test; a statistic beyond the threshold
+// means the operation's duration depends on which class its input came from.
+//
+// See Reparaz, Balasch and Verbauwhede, "Dude, is my code constant time?" (2017).
+package leakage
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"time"
+
+	"crypto-benchmark/metrics"
+	"crypto-benchmark/msp"
+)
+
+// DefaultThreshold is the |t| above which dudect reports a leak
+const DefaultThreshold = 4.5
+
+// cropPercentiles are the upper cut-offs applied before testing, as in dudect,
+// so that interrupts and GC pauses in the tail cannot hide or fake a difference.
+// 100 keeps every measurement.
+var cropPercentiles = []float64{100, 99, 95, 90, 75, 50}
+
+// Options configures a leakage run
+type Options struct {
+	Measurements int             // Measurements per test, split randomly between the classes
+	Threshold    float64         // |t| above which a test fails
+	Seed         int64           // Seed for class assignment and random inputs
+	Signing      msp.SigningMode // Hedged or deterministic signing
+}
+
+// DefaultOptions returns 10,000 measurements per test at the dudect threshold
+func DefaultOptions() Options {
+	return Options{
+		Measurements: 10000,
+		Threshold:    DefaultThreshold,
+		Seed:         time.Now().UnixNano(),
+	}
+}
+
+// TestResult is the outcome of one fixed-versus-random test
+type TestResult struct {
+	Name           string     `json:"name"`
+	Class0         string     `json:"class0"`
+	Class1         string     `json:"class1"`
+	Measurements   [2]int     `json:"measurements"`
+	MeanNs         [2]float64 `json:"mean_ns"`
+	T              float64    `json:"t"`
+	CropPercentile float64    `json:"crop_percentile"`
+	Leak           bool       `json:"leak"`
+	Informational  bool       `json:"informational,omitempty"`
+	Note           string     `json:"note,omitempty"`
+}
+
+// AlgorithmResult collects the tests of one algorithm
+type AlgorithmResult struct {
+	Algorithm string       `json:"algorithm"`
+	Tests     []TestResult `json:"tests"`
+	Passed    bool         `json:"passed"`
+}
+
+// test is one pair of input classes for an operation. prepare builds the
+// inputs of the i-th measurement outside the timed region and returns the
+// operation to time.
+type test struct {
+	name          string
+	class0        string
+	class1        string
+	informational bool
+	note          string
+	prepare       func(class, i int) (func() error, error)
+}
+
+// Run measures every test of an algorithm
+func Run(algorithm msp.SignatureAlgorithm, opts Options) (AlgorithmResult, error) {
+	result := AlgorithmResult{Algorithm: algorithm.String(), Passed: true}
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	tests, err := buildTests(algorithm, opts.Signing, rng)
+	if err != nil {
+		return result, err
+	}
+
+	for _, t := range tests {
+		r, err := measure(t, opts, rng)
+		if err != nil {
+			return result, fmt.Errorf("%s: %v", t.name, err)
+		}
+		if r.Leak && !r.Informational {
+			result.Passed = false
+		}
+		result.Tests = append(result.Tests, r)
+	}
+	return result, nil
+}
+
+// measure times the two classes in random order and applies the t-test to the
+// raw and cropped distributions, reporting the largest |t|
+func measure(t test, opts Options, rng *rand.Rand) (TestResult, error) {
+	result := TestResult{
+		Name:          t.name,
+		Class0:        t.class0,
+		Class1:        t.class1,
+		Informational: t.informational,
+		Note:          t.note,
+	}
+
+	// Pin to one OS thread and start from a clean heap to reduce scheduling noise
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	runtime.GC()
+
+	var samples [2][]float64
+	for i := 0; i < opts.Measurements; i++ {
+		class := rng.Intn(2)
+		op, err := t.prepare(class, i)
+		if err != nil {
+			return result, err
+		}
+
+		start := time.Now()
+		err = op()
+		elapsed := time.Since(start)
+		if err != nil {
+			return result, err
+		}
+		samples[class] = append(samples[class], float64(elapsed.Nanoseconds()))
+	}
+
+	all := append(append([]float64(nil), samples[0]...), samples[1]...)
+	for _, p := range cropPercentiles {
+		limit := metrics.Percentile(all, p)
+		a, b := cropBelow(samples[0], limit), cropBelow(samples[1], limit)
+		tStat, _ := metrics.WelchTTest(a, b)
+		if math.IsNaN(tStat) {
+			continue
+		}
+		if math.Abs(tStat) >= math.Abs(result.T) {
+			result.T = tStat
+			result.CropPercentile = p
+		}
+	}
+
+	for class := range samples {
+		result.Measurements[class] = len(samples[class])
+		result.MeanNs[class] = metrics.Mean(samples[class])
+	}
+	result.Leak = math.Abs(result.T) > opts.Threshold
+	return result, nil
+}
+
+// cropBelow returns the samples not above limit
+func cropBelow(samples []float64, limit float64) []float64 {
+	kept := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		if s <= limit {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
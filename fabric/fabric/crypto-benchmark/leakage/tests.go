@@ -0,0 +1,138 @@
+package leakage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"crypto-benchmark/msp"
+)
+
+// poolSize is the number of key instances per class. Both classes cycle
+// through the same number of distinct objects, so cache effects are balanced.
+const poolSize = 64
+
+// messageSize is the length of random messages
+const messageSize = 64
+
+// buildTests creates the signing and verification tests of an algorithm
+func buildTests(algorithm msp.SignatureAlgorithm, signing msp.SigningMode, rng *rand.Rand) ([]test, error) {
+	newSigner := func() (*msp.EnhancedMSP, error) {
+		return msp.NewEnhancedMSPWithOptions(context.Background(), algorithm, msp.Options{Signing: signing})
+	}
+	fixed, err := newSigner()
+	if err != nil {
+		return nil, err
+	}
+
+	// Class 0 holds copies of one key, class 1 independent keys
+	var signers [2][]*msp.EnhancedMSP
+	for i := 0; i < poolSize; i++ {
+		clone, err := fixed.Clone()
+		if err != nil {
+			return nil, err
+		}
+		fresh, err := newSigner()
+		if err != nil {
+			return nil, err
+		}
+		signers[0] = append(signers[0], clone)
+		signers[1] = append(signers[1], fresh)
+	}
+
+	// Messages and signatures for verification, reused across measurements
+	publicKey, err := fixed.GetPublicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	verifiers := make([]*msp.EnhancedMSP, poolSize)
+	for i := range verifiers {
+		if verifiers[i], err = msp.NewEnhancedMSPFromPublicKey(algorithm, publicKey); err != nil {
+			return nil, err
+		}
+	}
+	type signedMessage struct {
+		message, tampered, signature []byte
+	}
+	signed := make([]signedMessage, poolSize*4)
+	for i := range signed {
+		message := randomMessage(rng)
+		signature, err := fixed.Sign(message)
+		if err != nil {
+			return nil, err
+		}
+		tampered := append([]byte(nil), message...)
+		tampered[rng.Intn(len(tampered))] ^= 1 << uint(rng.Intn(8))
+		signed[i] = signedMessage{message: message, tampered: tampered, signature: signature}
+	}
+
+	fixedMessage := randomMessage(rng)
+	messageTest := test{
+		name:   "sign-message",
+		class0: "fixed message",
+		class1: "random messages",
+		prepare: func(class, i int) (func() error, error) {
+			message := fixedMessage
+			if class == 1 {
+				message = randomMessage(rng)
+			}
+			signer := signers[0][i%poolSize]
+			return func() error {
+				_, err := signer.Sign(message)
+				return err
+			}, nil
+		},
+	}
+	if algorithm != msp.ECDSA && signing == msp.SigningDeterministic {
+		messageTest.informational = true
+		messageTest.note = "deterministic ML-DSA signing repeats the same rejection-sampling iterations for a fixed message, so its time depends on the public message by design"
+	}
+
+	return []test{
+		{
+			name:   "sign-key",
+			class0: "fixed private key",
+			class1: "random private keys",
+			prepare: func(class, i int) (func() error, error) {
+				signer := signers[class][i%poolSize]
+				message := randomMessage(rng)
+				return func() error {
+					_, err := signer.Sign(message)
+					return err
+				}, nil
+			},
+		},
+		messageTest,
+		{
+			name:   "verify",
+			class0: "valid signatures",
+			class1: "invalid signatures (tampered message)",
+			prepare: func(class, i int) (func() error, error) {
+				verifier := verifiers[i%poolSize]
+				s := signed[rng.Intn(len(signed))]
+				message, want := s.message, true
+				if class == 1 {
+					message, want = s.tampered, false
+				}
+				return func() error {
+					valid, err := verifier.Verify(message, s.signature)
+					if err != nil && !errors.Is(err, msp.ErrVerificationFailed) {
+						return err
+					}
+					if valid != want {
+						return fmt.Errorf("verification returned %v, expected %v", valid, want)
+					}
+					return nil
+				}, nil
+			},
+		},
+	}, nil
+}
+
+// randomMessage returns a message of random bytes
+func randomMessage(rng *rand.Rand) []byte {
+	message := make([]byte, messageSize)
+	rng.Read(message)
+	return message
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"crypto-benchmark/hsm"
+	"crypto-benchmark/kem"
 	"crypto-benchmark/metrics"
 	"crypto-benchmark/msp"
 	"flag"
@@ -8,23 +11,139 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 func main() {
+	// Subcommands operate on existing results files instead of running benchmarks
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compare":
+			os.Exit(runCompare(os.Args[2:]))
+		case "history":
+			os.Exit(runHistory(os.Args[2:]))
+		case "export":
+			os.Exit(runExport(os.Args[2:]))
+		case "report":
+			os.Exit(runReport(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "daemon":
+			os.Exit(runDaemon(os.Args[2:]))
+		case "acvp":
+			os.Exit(runACVP(os.Args[2:]))
+		case "leakage":
+			os.Exit(runLeakage(os.Args[2:]))
+		case "fixtures":
+			os.Exit(runFixtures(os.Args[2:]))
+		case "contexts":
+			os.Exit(runContexts(os.Args[2:]))
+		case "batch":
+			os.Exit(runBatch(os.Args[2:]))
+		case "stateful":
+			os.Exit(runStateful(os.Args[2:]))
+		case "keystore":
+			os.Exit(runKeystore(os.Args[2:]))
+		case "pkcs11":
+			os.Exit(runPKCS11(os.Args[2:]))
+		case "concurrency":
+			os.Exit(runConcurrency(os.Args[2:]))
+		case "stream":
+			os.Exit(runStream(os.Args[2:]))
+		}
+	}
+
 	var (
 		message    = flag.String("message", "Hyperledger Fabric ML-DSA vs ECDSA Performance Benchmark Test Message", "Test message for benchmarking")
 		iterations = flag.Int("iterations", 100, "Number of iterations per algorithm")
 		outputDir  = flag.String("output", "results", "Output directory for results")
 		validate   = flag.Bool("validate", true, "Run implementation validation")
+		acvpDir    = flag.String("acvp", "", "Directory of NIST ACVP test vectors to check during validation")
+		history    = flag.String("history", metrics.DefaultHistoryFile, "History store to append this run to (empty to disable)")
+		export     = flag.String("export", "", "Comma-separated table formats to write next to the JSON results (csv, markdown, latex)")
+		report     = flag.Bool("report", false, "Write a self-contained HTML report next to the JSON results")
+		baseline   = flag.String("baseline", msp.ECDSA.String(), "Algorithm used as the baseline for summary ratios")
+		costWeight = flag.String("cost-weights", "", "Cost-per-transaction weights, e.g. sign=1,verify=1,endorsers=3,bytes=0.0001,blocks=1")
+		cpuProfile = flag.Bool("cpuprofile", false, "Write a CPU profile of each algorithm's benchmark next to the JSON results")
+		memProfile = flag.Bool("memprofile", false, "Write heap profiles before and after each algorithm's benchmark next to the JSON results")
+		execTrace  = flag.Bool("trace", false, "Write an execution trace of each algorithm's benchmark next to the JSON results")
+		seed       = flag.String("seed", "", "Hex master seed (at least 16 bytes) to derive every key from, making the run reproducible")
+		kemList    = flag.String("kems", "ML-KEM-512,ML-KEM-768,ML-KEM-1024,X25519,X25519MLKEM768", "Comma-separated key encapsulation mechanisms to benchmark (empty to skip)")
+		signing    = flag.String("signing", "", "Signing mode: hedged or deterministic (default deterministic with --seed, hedged otherwise)")
+		algList    = flag.String("algorithms", "ECDSA,ML-DSA-44,ML-DSA-65,ML-DSA-87", "Comma-separated signature algorithms (LMS-HSS and XMSS-MT take seconds per key)")
+		stateDir   = flag.String("state-dir", "", "Directory recording the used one-time keys of LMS-HSS and XMSS-MT keys (default in memory)")
+		reserve    = flag.Uint64("state-reserve", 1, "One-time keys a stateful key reserves per durable state update")
+		p11Module  = flag.String("pkcs11-module", "", "PKCS#11 module to generate and hold the benchmarked keys (needs -tags pkcs11; PIN from $"+hsm.PINEnv+")")
+		p11Slot    = flag.Int("pkcs11-slot", -1, "PKCS#11 slot ID (-1 for any slot)")
+		p11Label   = flag.String("pkcs11-token-label", "", "PKCS#11 token label (empty for any token)")
 	)
 	flag.Parse()
 
+	weights, err := metrics.ParseCostWeights(*costWeight)
+	if err != nil {
+		log.Fatalf("Invalid cost weights: %v", err)
+	}
+	kems, err := parseKEMList(*kemList)
+	if err != nil {
+		log.Fatalf("Invalid KEM list: %v", err)
+	}
+	repro, err := parseReproducibility(*seed, *signing)
+	if err != nil {
+		log.Fatalf("Invalid reproducibility options: %v", err)
+	}
+	state, err := openStateConfig(*stateDir, *reserve)
+	if err != nil {
+		log.Fatalf("Invalid state options: %v", err)
+	}
+
+	if *p11Module != "" && repro.seeds != nil {
+		log.Fatalf("--seed cannot be used with --pkcs11-module: token keys cannot be derived from a seed")
+	}
+	token, err := openTokenConfig(*p11Module, *p11Slot, *p11Label)
+	if err != nil {
+		log.Fatalf("Failed to open PKCS#11 token: %v", err)
+	}
+	defer token.close()
+
+	// Define algorithms to test
+	var algorithms []msp.SignatureAlgorithm
+	for _, name := range splitList(*algList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		if token.token != nil && !token.token.Supports(algorithm) {
+			log.Fatalf("PKCS#11 token %s does not support %s", token.token.Name(), algorithm)
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+
 	fmt.Println("Hyperledger Fabric Cryptographic Algorithm Benchmark")
 	fmt.Println("====================================================")
 	fmt.Printf("Test Message: %s\n", *message)
 	fmt.Printf("Iterations: %d\n", *iterations)
 	fmt.Printf("Output Directory: %s\n", *outputDir)
+	fmt.Printf("Signing: %s\n", repro.signing)
+	if repro.seeds != nil {
+		fmt.Printf("Seed: %s\n", repro.seedHex)
+		if repro.signing == msp.SigningHedged {
+			fmt.Println("  Note: hedged signatures differ between runs; only the keys are reproducible")
+		}
+	}
+	for _, algorithm := range algorithms {
+		if algorithm.IsStateful() {
+			if state.dir != "" {
+				fmt.Printf("Stateful key state: %s (%d one-time keys reserved per update)\n", state.dir, state.reserve)
+			} else {
+				fmt.Println("Stateful key state: in memory (use --state-dir for durable state)")
+			}
+			break
+		}
+	}
+	if token.token != nil {
+		fmt.Printf("Keys: %s (benchmarked keys are generated and used on the token)\n", token.token.Name())
+	}
 	fmt.Println()
 
 	// Create output directory
@@ -32,20 +151,14 @@ func main() {
 		log.Fatalf("Failed to create output directory: %v", err)
 	}
 
-	// Define algorithms to test
-	algorithms := []msp.SignatureAlgorithm{
-		msp.ECDSA,
-		msp.MLDSA44,
-		msp.MLDSA65,
-		msp.MLDSA87,
-	}
-
 	algorithmNames := make([]string, len(algorithms))
 	for i, alg := range algorithms {
 		algorithmNames[i] = alg.String()
 	}
 
 	collector := metrics.NewMetricsCollector(*message, *iterations, algorithmNames)
+	collector.SetSummaryOptions(metrics.SummaryOptions{Baseline: *baseline, Weights: weights})
+	collector.SetReproducibility(repro.seedHex, repro.signing.String())
 
 	// Validate implementation if requested
 	if *validate {
@@ -53,10 +166,28 @@ func main() {
 		if err := validateImplementation(algorithms); err != nil {
 			log.Fatalf("Implementation validation failed: %v", err)
 		}
+		if err := validateKEMs(kems); err != nil {
+			log.Fatalf("KEM validation failed: %v", err)
+		}
+		if *acvpDir != "" {
+			if err := validateACVP(*acvpDir); err != nil {
+				log.Fatalf("ACVP validation failed: %v", err)
+			}
+		}
 		fmt.Println("✓ Implementation validation passed")
 		fmt.Println()
 	}
 
+	// The results file name is fixed up front so profiles can share it
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	filename := filepath.Join(*outputDir, fmt.Sprintf("crypto_benchmark_%s.json", timestamp))
+	profiler := phaseProfiler{
+		prefix: strings.TrimSuffix(filename, filepath.Ext(filename)),
+		cpu:    *cpuProfile,
+		heap:   *memProfile,
+		trace:  *execTrace,
+	}
+
 	// Run benchmarks
 	fmt.Println("Step 2: Running Benchmarks")
 	startTime := time.Now()
@@ -65,13 +196,19 @@ func main() {
 		fmt.Printf("Running benchmark %d/%d: %s\n", i+1, len(algorithms), algorithm.String())
 
 		// Create MSP instance
-		mspInstance, err := msp.NewEnhancedMSP(algorithm)
+		mspInstance, err := msp.NewEnhancedMSPWithOptions(context.Background(), algorithm, token.apply(state.apply(repro.options())))
 		if err != nil {
 			log.Fatalf("Failed to create MSP for %s: %v", algorithm.String(), err)
 		}
 
-		// Run benchmark
-		benchmarkResult, err := mspInstance.Benchmark([]byte(*message), *iterations)
+		// Profile only the benchmark phase of this algorithm
+		var benchmarkResult *msp.CryptoMetrics
+		profiles, err := profiler.run(algorithm.String(), func() error {
+			var err error
+			benchmarkResult, err = mspInstance.Benchmark([]byte(*message), *iterations)
+			mspInstance.Close()
+			return err
+		})
 		if err != nil {
 			log.Fatalf("Benchmark failed for %s: %v", algorithm.String(), err)
 		}
@@ -84,8 +221,48 @@ func main() {
 		fmt.Printf("  Signing: %.3f ms\n", benchmarkResult.SignTimeMs)
 		fmt.Printf("  Verification: %.3f ms\n", benchmarkResult.VerifyTimeMs)
 		fmt.Printf("  Public Key: %d bytes\n", benchmarkResult.PublicKeyBytes)
-		fmt.Printf("  Private Key: %d bytes\n", benchmarkResult.PrivateKeyBytes)
+		if benchmarkResult.KeyProvider != "" {
+			fmt.Printf("  Private Key: held by %s\n", benchmarkResult.KeyProvider)
+		} else {
+			fmt.Printf("  Private Key: %d bytes\n", benchmarkResult.PrivateKeyBytes)
+		}
 		fmt.Printf("  Signature: %d bytes\n", benchmarkResult.SignatureBytes)
+		if benchmarkResult.MaxSignatures > 0 {
+			fmt.Printf("  State Update: %.3f ms per signature (%d signatures per key)\n", benchmarkResult.StateUpdateTimeMs, benchmarkResult.MaxSignatures)
+		}
+		for _, profile := range profiles {
+			fmt.Printf("  Profile: %s\n", profile)
+		}
+		fmt.Println()
+	}
+
+	for i, algorithm := range kems {
+		fmt.Printf("Running KEM benchmark %d/%d: %s\n", i+1, len(kems), algorithm)
+
+		k, err := kem.New(algorithm)
+		if err != nil {
+			log.Fatalf("Failed to create KEM %s: %v", algorithm, err)
+		}
+		var kemResult *kem.Metrics
+		profiles, err := profiler.run(algorithm.String(), func() error {
+			var err error
+			kemResult, err = kem.Benchmark(k, *iterations)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("KEM benchmark failed for %s: %v", algorithm, err)
+		}
+		collector.AddKEMResult(*kemResult)
+
+		fmt.Printf("  Key Generation: %.3f ms\n", kemResult.KeygenTimeMs)
+		fmt.Printf("  Encapsulation: %.3f ms\n", kemResult.EncapsulateTimeMs)
+		fmt.Printf("  Decapsulation: %.3f ms\n", kemResult.DecapsulateTimeMs)
+		fmt.Printf("  Encapsulation Key: %d bytes\n", kemResult.EncapsulationKeyBytes)
+		fmt.Printf("  Decapsulation Key: %d bytes\n", kemResult.DecapsulationKeyBytes)
+		fmt.Printf("  Ciphertext: %d bytes\n", kemResult.CiphertextBytes)
+		for _, profile := range profiles {
+			fmt.Printf("  Profile: %s\n", profile)
+		}
 		fmt.Println()
 	}
 
@@ -95,15 +272,45 @@ func main() {
 	// Print summary
 	collector.PrintSummary()
 
-	// Save results
+	// Save results. The result is built once so that every artifact of the
+	// run carries the same timestamp and duration.
 	fmt.Println("\nStep 3: Saving Results")
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := filepath.Join(*outputDir, fmt.Sprintf("crypto_benchmark_%s.json", timestamp))
-
-	if err := collector.SaveResults(filename); err != nil {
+	result := collector.BuildResult()
+	if err := metrics.SaveResult(filename, result); err != nil {
 		log.Fatalf("Failed to save results: %v", err)
 	}
 
+	exportOptions := metrics.DefaultExportOptions()
+	exportOptions.Baseline = *baseline
+	for _, format := range splitList(*export) {
+		exporter, err := metrics.GetExporter(format)
+		if err != nil {
+			log.Fatalf("Failed to export results: %v", err)
+		}
+		target := exportFilename(filename, exporter)
+		if err := metrics.ExportFile(target, result, exporter, exportOptions); err != nil {
+			log.Fatalf("Failed to export results: %v", err)
+		}
+		fmt.Printf("Exported %s table to: %s\n", format, target)
+	}
+
+	if *report {
+		reportFile := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".html"
+		input := metrics.ReportInput{Name: filepath.Base(filename), Result: result}
+		if err := metrics.SaveHTMLReport(reportFile, []metrics.ReportInput{input}); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		fmt.Printf("HTML report saved to: %s\n", reportFile)
+	}
+
+	if *history != "" {
+		entry := metrics.NewHistoryEntry(result, filename, result.Environment.HistoryKey())
+		if err := metrics.OpenHistoryStore(*history).Append(entry); err != nil {
+			log.Fatalf("Failed to record run in history: %v", err)
+		}
+		fmt.Printf("Run recorded in history: %s\n", *history)
+	}
+
 	fmt.Println("\n✓ Benchmark completed successfully!")
 	fmt.Printf("Results saved to: %s\n", filename)
 }
@@ -116,111 +323,135 @@ func validateImplementation(algorithms []msp.SignatureAlgorithm) error {
 	// Test each algorithm for comprehensive functionality
 	for _, algorithm := range algorithms {
 		fmt.Printf("Validating %s...\n", algorithm.String())
-
-		// Test 1: Basic MSP creation
-		mspInstance, err := msp.NewEnhancedMSP(algorithm)
-		if err != nil {
-			return fmt.Errorf("failed to create MSP for %s: %v", algorithm.String(), err)
-		}
-
-		// Test 2: Multiple message signing and verification
-		testMessages := [][]byte{
-			[]byte("Short test"),
-			[]byte("This is a longer test message with more content to validate"),
-			[]byte("Test with special chars: !@#$%^&*()_+-=[]{}|;':\",./<>?"),
-			[]byte("Empty message test"),
+		if err := validateAlgorithm(algorithm, algorithms); err != nil {
+			return err
 		}
+	}
 
-		for i, testMessage := range testMessages {
-			// Test signing
-			signature, err := mspInstance.Sign(testMessage)
-			if err != nil {
-				return fmt.Errorf("signing failed for %s (message %d): %v", algorithm.String(), i+1, err)
-			}
-
-			// Test verification
-			valid, err := mspInstance.Verify(testMessage, signature)
-			if err != nil {
-				return fmt.Errorf("verification failed for %s (message %d): %v", algorithm.String(), i+1, err)
-			}
+	fmt.Println("All validations passed - no stub code detected!")
+	fmt.Println("✓ Real cryptographic implementations confirmed")
+	return nil
+}
 
-			if !valid {
-				return fmt.Errorf("signature verification returned false for %s (message %d)", algorithm.String(), i+1)
-			}
+// validateAlgorithm runs the validation tests of one algorithm, closing every
+// MSP it creates
+func validateAlgorithm(algorithm msp.SignatureAlgorithm, algorithms []msp.SignatureAlgorithm) error {
+	// Test 1: Basic MSP creation
+	mspInstance, err := msp.NewEnhancedMSP(algorithm)
+	if err != nil {
+		return fmt.Errorf("failed to create MSP for %s: %v", algorithm.String(), err)
+	}
+	defer mspInstance.Close()
+
+	// Test 2: Multiple message signing and verification
+	testMessages := [][]byte{
+		[]byte("Short test"),
+		[]byte("This is a longer test message with more content to validate"),
+		[]byte("Test with special chars: !@#$%^&*()_+-=[]{}|;':\",./<>?"),
+		[]byte("Empty message test"),
+	}
 
-			// Test signature size is reasonable
-			if len(signature) == 0 {
-				return fmt.Errorf("signature is empty for %s (message %d)", algorithm.String(), i+1)
-			}
+	for i, testMessage := range testMessages {
+		// Test signing
+		signature, err := mspInstance.Sign(testMessage)
+		if err != nil {
+			return fmt.Errorf("signing failed for %s (message %d): %v", algorithm.String(), i+1, err)
 		}
 
-		// Test 3: Key extraction and validation
-		publicKeyBytes, err := mspInstance.GetPublicKeyBytes()
+		// Test verification
+		valid, err := mspInstance.Verify(testMessage, signature)
 		if err != nil {
-			return fmt.Errorf("failed to get public key bytes for %s: %v", algorithm.String(), err)
+			return fmt.Errorf("verification failed for %s (message %d): %v", algorithm.String(), i+1, err)
 		}
 
-		if len(publicKeyBytes) == 0 {
-			return fmt.Errorf("public key bytes is empty for %s", algorithm.String())
+		if !valid {
+			return fmt.Errorf("signature verification returned false for %s (message %d)", algorithm.String(), i+1)
 		}
 
-		privateKeyBytes, err := mspInstance.GetPrivateKeyBytes()
-		if err != nil {
-			return fmt.Errorf("failed to get private key bytes for %s: %v", algorithm.String(), err)
+		// Test signature size is reasonable
+		if len(signature) == 0 {
+			return fmt.Errorf("signature is empty for %s (message %d)", algorithm.String(), i+1)
 		}
+	}
 
-		if len(privateKeyBytes) == 0 {
-			return fmt.Errorf("private key bytes is empty for %s", algorithm.String())
-		}
+	// Test 3: Key extraction and validation
+	publicKeyBytes, err := mspInstance.GetPublicKeyBytes()
+	if err != nil {
+		return fmt.Errorf("failed to get public key bytes for %s: %v", algorithm.String(), err)
+	}
 
-		// Test 4: Cross-instance verification (realistic scenario)
-		signingMSP, err := msp.NewEnhancedMSP(algorithm)
-		if err != nil {
-			return fmt.Errorf("failed to create signing MSP for %s: %v", algorithm.String(), err)
-		}
+	if len(publicKeyBytes) == 0 {
+		return fmt.Errorf("public key bytes is empty for %s", algorithm.String())
+	}
 
-		verifyingMSP, err := msp.NewEnhancedMSP(algorithm)
-		if err != nil {
-			return fmt.Errorf("failed to create verifying MSP for %s: %v", algorithm.String(), err)
-		}
+	privateKeyBytes, err := mspInstance.GetPrivateKeyBytes()
+	if err != nil {
+		return fmt.Errorf("failed to get private key bytes for %s: %v", algorithm.String(), err)
+	}
 
-		crossTestMessage := []byte("Cross-instance verification test")
-		crossSignature, err := signingMSP.Sign(crossTestMessage)
-		if err != nil {
-			return fmt.Errorf("cross-instance signing failed for %s: %v", algorithm.String(), err)
-		}
+	defer clear(privateKeyBytes)
 
-		// Get public key from signing MSP and set it in verifying MSP
-		publicKeyBytes, err = signingMSP.GetPublicKeyBytes()
-		if err != nil {
-			return fmt.Errorf("failed to get public key for cross-instance test: %v", err)
-		}
+	if len(privateKeyBytes) == 0 {
+		return fmt.Errorf("private key bytes is empty for %s", algorithm.String())
+	}
 
-		// This should work for the cross-instance test
-		// Note: This is a simplified test - in real scenarios, you'd need proper key exchange
-		valid, err := verifyingMSP.Verify(crossTestMessage, crossSignature)
-		if err == nil && !valid {
-			// This is expected for cross-instance without proper key setup
-			// We'll just verify that the verification doesn't crash
-		}
+	// Test 4: Cross-instance verification (realistic scenario)
+	signingMSP, err := msp.NewEnhancedMSP(algorithm)
+	if err != nil {
+		return fmt.Errorf("failed to create signing MSP for %s: %v", algorithm.String(), err)
+	}
+	defer signingMSP.Close()
 
-		// Test 5: Performance timing validation (ensure operations are measurable)
-		start := time.Now()
-		_, err = mspInstance.Sign([]byte("Performance test message"))
-		signTime := time.Since(start)
-		if err != nil {
-			return fmt.Errorf("performance test signing failed for %s: %v", algorithm.String(), err)
-		}
+	verifyingMSP, err := msp.NewEnhancedMSP(algorithm)
+	if err != nil {
+		return fmt.Errorf("failed to create verifying MSP for %s: %v", algorithm.String(), err)
+	}
+	defer verifyingMSP.Close()
 
-		// Ensure signing is measurable (at least 1 microsecond for accurate measurement)
-		if signTime < time.Microsecond {
-			signTime = time.Microsecond
-		}
+	crossTestMessage := []byte("Cross-instance verification test")
+	crossSignature, err := signingMSP.Sign(crossTestMessage)
+	if err != nil {
+		return fmt.Errorf("cross-instance signing failed for %s: %v", algorithm.String(), err)
+	}
 
-		fmt.Printf("  ✓ %s validation passed (sign time: %v)\n", algorithm.String(), signTime)
+	// Get public key from signing MSP and set it in verifying MSP
+	publicKeyBytes, err = signingMSP.GetPublicKeyBytes()
+	if err != nil {
+		return fmt.Errorf("failed to get public key for cross-instance test: %v", err)
 	}
 
-	fmt.Println("All validations passed - no stub code detected!")
-	fmt.Println("✓ Real cryptographic implementations confirmed")
+	// This should work for the cross-instance test
+	// Note: This is a simplified test - in real scenarios, you'd need proper key exchange
+	valid, err := verifyingMSP.Verify(crossTestMessage, crossSignature)
+	if err == nil && !valid {
+		// This is expected for cross-instance without proper key setup
+		// We'll just verify that the verification doesn't crash
+	}
+
+	// Test 5: Performance timing validation (ensure operations are measurable)
+	start := time.Now()
+	_, err = mspInstance.Sign([]byte("Performance test message"))
+	signTime := time.Since(start)
+	if err != nil {
+		return fmt.Errorf("performance test signing failed for %s: %v", algorithm.String(), err)
+	}
+
+	// Ensure signing is measurable (at least 1 microsecond for accurate measurement)
+	if signTime < time.Microsecond {
+		signTime = time.Microsecond
+	}
+
+	// Test 6: Tracing instrumentation emits the expected spans
+	if err := validateTracing(algorithm); err != nil {
+		return fmt.Errorf("tracing validation failed for %s: %v", algorithm.String(), err)
+	}
+
+	// Test 7: Tampered, malformed and confused inputs are rejected without panics
+	rejected, err := validateAdversarial(algorithm, algorithms)
+	if err != nil {
+		return fmt.Errorf("adversarial validation failed for %s: %v", algorithm.String(), err)
+	}
+
+	fmt.Printf("  ✓ %s validation passed (sign time: %v, %d adversarial inputs rejected)\n", algorithm.String(), signTime, rejected)
 	return nil
 }
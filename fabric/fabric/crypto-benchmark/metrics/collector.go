@@ -7,14 +7,19 @@ import (
 	"path/filepath"
 	"time"
 
+	"crypto-benchmark/kem"
 	"crypto-benchmark/msp"
 )
 
 // BenchmarkResult holds the complete benchmark results for all algorithms
 type BenchmarkResult struct {
+	SchemaVersion     int            `json:"schema_version"`
+	Kind              string         `json:"kind"`
 	TestConfiguration TestConfig     `json:"test_configuration"`
 	Results          []msp.CryptoMetrics `json:"results"`
+	KEMResults       []kem.Metrics   `json:"kem_results,omitempty"`
 	Summary          Summary         `json:"summary"`
+	Environment      *Environment    `json:"environment,omitempty"`
 	Timestamp        string          `json:"timestamp"`
 }
 
@@ -24,6 +29,8 @@ type TestConfig struct {
 	Iterations     int    `json:"iterations"`
 	Algorithms     []string `json:"algorithms"`
 	TestDuration   string `json:"test_duration"`
+	Seed           string `json:"seed,omitempty"`
+	SigningMode    string `json:"signing_mode,omitempty"`
 }
 
 // Summary provides statistical summary of the benchmark results
@@ -34,6 +41,12 @@ type Summary struct {
 	SmallestPubKey   AlgorithmStats `json:"smallest_public_key"`
 	SmallestPrivKey  AlgorithmStats `json:"smallest_private_key"`
 	SmallestSig      AlgorithmStats `json:"smallest_signature"`
+
+	// Full rankings relative to a baseline algorithm, lowest value first
+	Baseline    string          `json:"baseline,omitempty"`
+	Rankings    []MetricRanking `json:"rankings,omitempty"`
+	CostWeights CostWeights     `json:"cost_weights"`
+	CostRanking []RankEntry     `json:"cost_ranking,omitempty"`
 }
 
 // AlgorithmStats holds statistics for a specific metric
@@ -45,9 +58,12 @@ type AlgorithmStats struct {
 
 // MetricsCollector handles the collection and storage of benchmark metrics
 type MetricsCollector struct {
-	results    []msp.CryptoMetrics
-	config     TestConfig
-	startTime  time.Time
+	results        []msp.CryptoMetrics
+	kemResults     []kem.Metrics
+	config         TestConfig
+	environment    Environment
+	summaryOptions SummaryOptions
+	startTime      time.Time
 }
 
 // NewMetricsCollector creates a new metrics collector
@@ -59,10 +75,19 @@ func NewMetricsCollector(testMessage string, iterations int, algorithms []string
 			Iterations:  iterations,
 			Algorithms:  algorithms,
 		},
-		startTime: time.Now(),
+		environment:    CaptureEnvironment(),
+		summaryOptions: DefaultSummaryOptions(),
+		startTime:      time.Now(),
 	}
 }
 
+// SetReproducibility records the hex master seed the keys were derived from
+// (empty for random keys) and the signing mode, so that a run can be repeated
+func (mc *MetricsCollector) SetReproducibility(seed, signingMode string) {
+	mc.config.Seed = seed
+	mc.config.SigningMode = signingMode
+}
+
 // AddResult adds a benchmark result to the collector
 func (mc *MetricsCollector) AddResult(result msp.CryptoMetrics) {
 	mc.results = append(mc.results, result)
@@ -153,42 +178,65 @@ func (mc *MetricsCollector) GenerateSummary() Summary {
 		Value:     float64(smallestSig.SignatureBytes),
 		Unit:      "bytes",
 	}
-	
+
+	summary.Baseline = mc.summaryOptions.Baseline
+	summary.CostWeights = mc.summaryOptions.Weights
+	summary.Rankings, summary.CostRanking = rankResults(mc.results, mc.summaryOptions)
+
 	return summary
 }
 
-// SaveResults saves the benchmark results to a JSON file
-func (mc *MetricsCollector) SaveResults(filename string) error {
+// BuildResult assembles the complete benchmark result for the collected metrics
+func (mc *MetricsCollector) BuildResult() BenchmarkResult {
 	mc.config.TestDuration = time.Since(mc.startTime).String()
-	
-	result := BenchmarkResult{
+
+	return BenchmarkResult{
+		SchemaVersion:     SchemaVersion,
+		Kind:              KindCryptoBenchmark,
 		TestConfiguration: mc.config,
 		Results:          mc.results,
+		KEMResults:       mc.kemResults,
 		Summary:          mc.GenerateSummary(),
+		Environment:      &mc.environment,
 		Timestamp:        time.Now().Format(time.RFC3339),
 	}
-	
+}
+
+// SaveResults saves the benchmark results to a JSON file
+func (mc *MetricsCollector) SaveResults(filename string) error {
+	return SaveResult(filename, mc.BuildResult())
+}
+
+// SaveResult saves a built result to a JSON file, so that every artifact of a
+// run can be written from the same result
+func SaveResult(filename string, result BenchmarkResult) error {
 	// Ensure the results directory exists
 	dir := filepath.Dir(filename)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create results directory: %v", err)
 	}
-	
+
 	// Marshal to JSON with pretty printing
 	jsonData, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal results to JSON: %v", err)
 	}
-	
+
 	// Write to file
 	err = os.WriteFile(filename, jsonData, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write results to file: %v", err)
 	}
-	
+
 	return nil
 }
 
+// AddKEMResult adds a key encapsulation benchmark result, reported next to the
+// signature results in the same file
+func (mc *MetricsCollector) AddKEMResult(result kem.Metrics) {
+	mc.kemResults = append(mc.kemResults, result)
+}
+
 // GetResults returns the current results
 func (mc *MetricsCollector) GetResults() []msp.CryptoMetrics {
 	return mc.results
@@ -202,6 +250,13 @@ func (mc *MetricsCollector) PrintSummary() {
 	fmt.Printf("  Iterations: %d\n", mc.config.Iterations)
 	fmt.Printf("  Duration: %s\n", mc.config.TestDuration)
 	fmt.Printf("  Algorithms: %v\n", mc.config.Algorithms)
+
+	env := mc.environment
+	fmt.Printf("Environment:\n")
+	fmt.Printf("  Host: %s (%s)\n", env.Hostname, env.HostFingerprint)
+	fmt.Printf("  CPU: %s, %d cores, flags %v, governor %s\n", env.CPUModel, env.NumCPU, env.CPUFlags, env.FrequencyGovernor)
+	fmt.Printf("  Kernel: %s %s/%s\n", env.Kernel, env.GOOS, env.GOARCH)
+	fmt.Printf("  Build: %s, circl %s, revision %s\n", env.GoVersion, env.CirclVersion, env.VCSRevision)
 	
 	fmt.Println("\nResults by Algorithm:")
 	for _, result := range mc.results {
@@ -213,6 +268,16 @@ func (mc *MetricsCollector) PrintSummary() {
 		fmt.Printf("  Private Key: %d bytes\n", result.PrivateKeyBytes)
 		fmt.Printf("  Signature: %d bytes\n", result.SignatureBytes)
 	}
+
+	if len(mc.kemResults) > 0 {
+		fmt.Println("\nKey Encapsulation:")
+		fmt.Printf("  %-16s %9s %9s %9s %8s %8s %10s\n", "Algorithm", "Keygen", "Encaps", "Decaps", "Pub", "Priv", "Ciphertext")
+		for _, result := range mc.kemResults {
+			fmt.Printf("  %-16s %7.3fms %7.3fms %7.3fms %6dB %6dB %9dB\n", result.Algorithm,
+				result.KeygenTimeMs, result.EncapsulateTimeMs, result.DecapsulateTimeMs,
+				result.EncapsulationKeyBytes, result.DecapsulationKeyBytes, result.CiphertextBytes)
+		}
+	}
 	
 	summary := mc.GenerateSummary()
 	fmt.Println("\nPerformance Leaders:")
@@ -228,4 +293,6 @@ func (mc *MetricsCollector) PrintSummary() {
 		summary.SmallestPrivKey.Algorithm, int(summary.SmallestPrivKey.Value))
 	fmt.Printf("  Smallest Signature: %s (%d bytes)\n", 
 		summary.SmallestSig.Algorithm, int(summary.SmallestSig.Value))
+
+	printRankings(summary)
 }
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+
+	"crypto-benchmark/msp"
+)
+
+// Supported significance tests for CompareResults
+const (
+	TestMannWhitney = "mannwhitney"
+	TestWelch       = "welch"
+)
+
+// CompareOptions configures how two benchmark results are compared
+type CompareOptions struct {
+	Threshold float64 // Regression threshold in percent of the baseline mean
+	Alpha     float64 // Significance level for the statistical test
+	Test      string  // TestMannWhitney or TestWelch
+}
+
+// DefaultCompareOptions returns the options used when none are configured
+func DefaultCompareOptions() CompareOptions {
+	return CompareOptions{
+		Threshold: 5.0,
+		Alpha:     0.05,
+		Test:      TestMannWhitney,
+	}
+}
+
+// MetricDelta holds the change of one operation for one algorithm between two runs
+type MetricDelta struct {
+	Algorithm    string  `json:"algorithm"`
+	Operation    string  `json:"operation"`
+	BaselineMs   float64 `json:"baseline_ms"`
+	CandidateMs  float64 `json:"candidate_ms"`
+	DeltaPercent float64 `json:"delta_percent"`
+	PValue       float64 `json:"p_value"`
+	Tested       bool    `json:"tested"`
+	Significant  bool    `json:"significant"`
+	Regression   bool    `json:"regression"`
+}
+
+// Comparison holds all deltas between a baseline and a candidate results file
+type Comparison struct {
+	Baseline  string         `json:"baseline"`
+	Candidate string         `json:"candidate"`
+	Options   CompareOptions `json:"-"`
+	Deltas    []MetricDelta  `json:"deltas"`
+}
+
+// CompareResults computes per-algorithm and per-operation deltas of candidate against baseline
+func CompareResults(baseline, candidate *BenchmarkResult, opts CompareOptions) (*Comparison, error) {
+	if opts.Test != TestMannWhitney && opts.Test != TestWelch {
+		return nil, fmt.Errorf("unsupported significance test: %s", opts.Test)
+	}
+
+	comparison := &Comparison{
+		Baseline:  baseline.Timestamp,
+		Candidate: candidate.Timestamp,
+		Options:   opts,
+	}
+
+	for _, base := range baseline.Results {
+		cand, ok := findResult(candidate.Results, base.Algorithm)
+		if !ok {
+			continue
+		}
+
+		for _, op := range timedOperations(base, cand) {
+			comparison.Deltas = append(comparison.Deltas, compareOperation(base.Algorithm, op, opts))
+		}
+	}
+
+	return comparison, nil
+}
+
+// Regressions returns the deltas that exceed the configured regression threshold
+func (c *Comparison) Regressions() []MetricDelta {
+	var regressions []MetricDelta
+	for _, d := range c.Deltas {
+		if d.Regression {
+			regressions = append(regressions, d)
+		}
+	}
+	return regressions
+}
+
+// PrintComparison prints a human-readable table of the comparison
+func (c *Comparison) PrintComparison() {
+	fmt.Printf("\n=== COMPARISON: %s -> %s ===\n", c.Baseline, c.Candidate)
+	fmt.Printf("Threshold: %.1f%%  Alpha: %.3f  Test: %s\n\n", c.Options.Threshold, c.Options.Alpha, c.Options.Test)
+	fmt.Printf("%-12s %-8s %12s %12s %9s %9s  %s\n", "Algorithm", "Op", "Base (ms)", "Cand (ms)", "Delta", "p-value", "Status")
+
+	for _, d := range c.Deltas {
+		pValue := "n/a"
+		if d.Tested {
+			pValue = fmt.Sprintf("%.4f", d.PValue)
+		}
+
+		status := "ok"
+		switch {
+		case d.Regression:
+			status = "REGRESSION"
+		case d.Significant && d.DeltaPercent < 0:
+			status = "improved"
+		case !d.Significant && d.Tested:
+			status = "noise"
+		}
+
+		fmt.Printf("%-12s %-8s %12.4f %12.4f %+8.2f%% %9s  %s\n",
+			d.Algorithm, d.Operation, d.BaselineMs, d.CandidateMs, d.DeltaPercent, pValue, status)
+	}
+}
+
+// operationSamples pairs the averages and raw samples of one operation from two runs
+type operationSamples struct {
+	name                     string
+	baseMean, candMean       float64
+	baseSamples, candSamples []float64
+}
+
+// timedOperations collects the keygen, sign and verify timings of two results
+func timedOperations(base, cand msp.CryptoMetrics) []operationSamples {
+	return []operationSamples{
+		{"keygen", base.KeygenTimeMs, cand.KeygenTimeMs, base.KeygenSamplesMs, cand.KeygenSamplesMs},
+		{"sign", base.SignTimeMs, cand.SignTimeMs, base.SignSamplesMs, cand.SignSamplesMs},
+		{"verify", base.VerifyTimeMs, cand.VerifyTimeMs, base.VerifySamplesMs, cand.VerifySamplesMs},
+	}
+}
+
+// compareOperation computes the delta and significance for one operation
+func compareOperation(algorithm string, op operationSamples, opts CompareOptions) MetricDelta {
+	delta := MetricDelta{
+		Algorithm:   algorithm,
+		Operation:   op.name,
+		BaselineMs:  op.baseMean,
+		CandidateMs: op.candMean,
+		PValue:      1,
+	}
+
+	if op.baseMean > 0 {
+		delta.DeltaPercent = (op.candMean - op.baseMean) / op.baseMean * 100
+	} else if op.candMean > 0 {
+		delta.DeltaPercent = math.Inf(1)
+	}
+
+	// Files written before raw samples were recorded can only be compared by mean
+	if len(op.baseSamples) >= 2 && len(op.candSamples) >= 2 {
+		delta.Tested = true
+		if opts.Test == TestWelch {
+			_, delta.PValue = WelchTTest(op.candSamples, op.baseSamples)
+		} else {
+			_, delta.PValue = MannWhitneyU(op.candSamples, op.baseSamples)
+		}
+		delta.Significant = delta.PValue < opts.Alpha
+	}
+
+	exceeds := delta.DeltaPercent > opts.Threshold
+	delta.Regression = exceeds && (delta.Significant || !delta.Tested)
+	return delta
+}
+
+// findResult returns the result for the named algorithm
+func findResult(results []msp.CryptoMetrics, algorithm string) (msp.CryptoMetrics, bool) {
+	for _, r := range results {
+		if r.Algorithm == algorithm {
+			return r, true
+		}
+	}
+	return msp.CryptoMetrics{}, false
+}
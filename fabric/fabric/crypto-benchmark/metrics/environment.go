@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Environment describes the host and build that produced a benchmark result
+type Environment struct {
+	Hostname          string            `json:"hostname"`
+	HostFingerprint   string            `json:"host_fingerprint"`
+	CPUModel          string            `json:"cpu_model"`
+	CPUFlags          []string          `json:"cpu_flags"`
+	NumCPU            int               `json:"num_cpu"`
+	GOMAXPROCS        int               `json:"gomaxprocs"`
+	FrequencyGovernor string            `json:"frequency_governor"`
+	Kernel            string            `json:"kernel"`
+	GoVersion         string            `json:"go_version"`
+	GOOS              string            `json:"goos"`
+	GOARCH            string            `json:"goarch"`
+	GOAMD64           string            `json:"goamd64,omitempty"`
+	CirclVersion      string            `json:"circl_version"`
+	BuildFlags        map[string]string `json:"build_flags,omitempty"`
+	VCSRevision       string            `json:"vcs_revision"`
+	VCSTime           string            `json:"vcs_time,omitempty"`
+	VCSModified       bool              `json:"vcs_modified"`
+}
+
+// buildFlagSettings are the build info settings that affect generated code
+var buildFlagSettings = []string{"-buildmode", "-compiler", "-tags", "-trimpath", "-race", "-gcflags", "-ldflags", "-asmflags", "CGO_ENABLED", "GOEXPERIMENT"}
+
+// CaptureEnvironment inspects the running host and the binary's build info
+func CaptureEnvironment() Environment {
+	hostname, _ := os.Hostname()
+	env := Environment{
+		Hostname:          hostname,
+		CPUFlags:          cpuFlags(),
+		NumCPU:            runtime.NumCPU(),
+		GOMAXPROCS:        runtime.GOMAXPROCS(0),
+		FrequencyGovernor: readSysValue("/sys/devices/system/cpu/cpu0/cpufreq/scaling_governor"),
+		Kernel:            readSysValue("/proc/sys/kernel/osrelease"),
+		GoVersion:         runtime.Version(),
+		GOOS:              runtime.GOOS,
+		GOARCH:            runtime.GOARCH,
+		CirclVersion:      "unknown",
+		VCSRevision:       "unknown",
+	}
+
+	if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+		env.CPUModel = parseCPUInfoField(string(data), "model name")
+	}
+	if env.CPUModel == "" {
+		env.CPUModel = "unknown"
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		env.applyBuildInfo(info)
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s/%s|%d", hostname, env.CPUModel, env.GOOS, env.GOARCH, env.NumCPU)))
+	env.HostFingerprint = hex.EncodeToString(sum[:6])
+	return env
+}
+
+// HistoryKey returns the history store key of the environment
+func (env Environment) HistoryKey() HistoryKey {
+	return HistoryKey{
+		Commit:          env.VCSRevision,
+		HostFingerprint: env.HostFingerprint,
+		GoVersion:       env.GoVersion,
+		CirclVersion:    env.CirclVersion,
+	}
+}
+
+// applyBuildInfo records the VCS, architecture level, flags and circl version of the build
+func (env *Environment) applyBuildInfo(info *debug.BuildInfo) {
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			env.VCSRevision = setting.Value
+		case "vcs.time":
+			env.VCSTime = setting.Value
+		case "vcs.modified":
+			env.VCSModified = setting.Value == "true"
+		case "GOAMD64":
+			env.GOAMD64 = setting.Value
+		default:
+			for _, flag := range buildFlagSettings {
+				if setting.Key == flag {
+					if env.BuildFlags == nil {
+						env.BuildFlags = map[string]string{}
+					}
+					env.BuildFlags[setting.Key] = setting.Value
+				}
+			}
+		}
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == circlModulePath {
+			env.CirclVersion = dep.Version
+			if dep.Replace != nil {
+				env.CirclVersion += " => " + dep.Replace.Path + " " + dep.Replace.Version
+			}
+		}
+	}
+}
+
+// cpuFlags lists the instruction set extensions relevant to the benchmarked primitives
+func cpuFlags() []string {
+	var flags []string
+	add := func(present bool, name string) {
+		if present {
+			flags = append(flags, name)
+		}
+	}
+
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		add(cpu.X86.HasAES, "aes")
+		add(cpu.X86.HasPCLMULQDQ, "pclmulqdq")
+		add(cpu.X86.HasSSE41, "sse4.1")
+		add(cpu.X86.HasAVX, "avx")
+		add(cpu.X86.HasAVX2, "avx2")
+		add(cpu.X86.HasAVX512F, "avx512f")
+		add(cpu.X86.HasBMI2, "bmi2")
+		add(cpu.X86.HasADX, "adx")
+	case "arm64":
+		add(cpu.ARM64.HasASIMD, "asimd")
+		add(cpu.ARM64.HasAES, "aes")
+		add(cpu.ARM64.HasPMULL, "pmull")
+		add(cpu.ARM64.HasSHA2, "sha2")
+		add(cpu.ARM64.HasSHA3, "sha3")
+		add(cpu.ARM64.HasSVE, "sve")
+	}
+	return flags
+}
+
+// readSysValue reads a single-line value from procfs or sysfs
+func readSysValue(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// parseCPUInfoField returns the first value of a field in /proc/cpuinfo format
+func parseCPUInfoField(cpuinfo, field string) string {
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.TrimSpace(name) == field {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
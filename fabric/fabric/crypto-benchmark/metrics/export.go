@@ -0,0 +1,278 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"crypto-benchmark/msp"
+)
+
+// ExportOptions controls number formatting in exported tables
+type ExportOptions struct {
+	SignificantFigures int    // Significant figures for timings and ratios
+	Baseline           string // Algorithm used as the denominator of ratios
+}
+
+// DefaultExportOptions returns three significant figures relative to ECDSA
+func DefaultExportOptions() ExportOptions {
+	return ExportOptions{
+		SignificantFigures: 3,
+		Baseline:           msp.ECDSA.String(),
+	}
+}
+
+// Exporter writes a benchmark result as a table in some output format
+type Exporter interface {
+	Name() string
+	Extension() string
+	Export(w io.Writer, result BenchmarkResult, opts ExportOptions) error
+}
+
+// exporters holds the registered exporters by name
+var exporters = map[string]Exporter{}
+
+// RegisterExporter makes an exporter available by its name
+func RegisterExporter(exporter Exporter) {
+	exporters[exporter.Name()] = exporter
+}
+
+// GetExporter returns the exporter registered under the given name
+func GetExporter(name string) (Exporter, error) {
+	exporter, ok := exporters[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export format: %s (available: %s)", name, strings.Join(ExporterNames(), ", "))
+	}
+	return exporter, nil
+}
+
+// ExporterNames returns the names of all registered exporters in sorted order
+func ExporterNames() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterExporter(csvExporter{})
+	RegisterExporter(markdownExporter{})
+	RegisterExporter(latexExporter{})
+}
+
+// ExportFile writes the result with the exporter to the given file
+func ExportFile(filename string, result BenchmarkResult, exporter Exporter, opts ExportOptions) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create export directory: %v", err)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %v", err)
+	}
+	defer file.Close()
+
+	if err := exporter.Export(file, result, opts); err != nil {
+		return fmt.Errorf("failed to export %s: %v", exporter.Name(), err)
+	}
+	return file.Close()
+}
+
+// tableColumn describes one metric column of an exported table
+type tableColumn struct {
+	operation string
+	label     string
+	unit      string
+}
+
+// tableColumns lists the metric columns in the order they are exported
+var tableColumns = []tableColumn{
+	{"keygen", "Key Generation", "ms"},
+	{"sign", "Signing", "ms"},
+	{"verify", "Verification", "ms"},
+	{"public_key", "Public Key", "bytes"},
+	{"private_key", "Private Key", "bytes"},
+	{"signature", "Signature", "bytes"},
+}
+
+// tableCell holds the formatted value and ratio of one metric
+type tableCell struct {
+	value string
+	ratio string
+}
+
+// buildTable formats every metric of every algorithm, relative to the baseline
+func buildTable(result BenchmarkResult, opts ExportOptions) [][]tableCell {
+	baseline, hasBaseline := findResult(result.Results, opts.Baseline)
+
+	rows := make([][]tableCell, len(result.Results))
+	for i, r := range result.Results {
+		rows[i] = make([]tableCell, len(tableColumns))
+		for j, col := range tableColumns {
+			value, _ := OperationValue(r, col.operation)
+
+			cell := tableCell{}
+			if col.unit == "bytes" {
+				cell.value = strconv.Itoa(int(value))
+			} else {
+				cell.value = FormatSignificant(value, opts.SignificantFigures)
+			}
+
+			if hasBaseline {
+				base, _ := OperationValue(baseline, col.operation)
+				if base > 0 {
+					cell.ratio = FormatSignificant(value/base, opts.SignificantFigures)
+				}
+			}
+			rows[i][j] = cell
+		}
+	}
+	return rows
+}
+
+// FormatSignificant formats a value rounded to the given number of significant figures
+func FormatSignificant(value float64, figures int) string {
+	if value == 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	if figures < 1 {
+		figures = 1
+	}
+
+	magnitude := int(math.Floor(math.Log10(math.Abs(value))))
+	decimals := figures - 1 - magnitude
+	if decimals <= 0 {
+		scale := math.Pow(10, float64(-decimals))
+		return strconv.FormatFloat(math.Round(value/scale)*scale, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(value, 'f', decimals, 64)
+}
+
+// csvExporter writes one row per algorithm with separate value and ratio columns
+type csvExporter struct{}
+
+func (csvExporter) Name() string      { return "csv" }
+func (csvExporter) Extension() string { return ".csv" }
+
+// Export writes the result as CSV
+func (csvExporter) Export(w io.Writer, result BenchmarkResult, opts ExportOptions) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"algorithm"}
+	for _, col := range tableColumns {
+		header = append(header,
+			fmt.Sprintf("%s_%s", col.operation, col.unit),
+			fmt.Sprintf("%s_vs_%s", col.operation, opts.Baseline))
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for i, row := range buildTable(result, opts) {
+		record := []string{result.Results[i].Algorithm}
+		for _, cell := range row {
+			record = append(record, cell.value, cell.ratio)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// markdownExporter writes a GitHub-flavoured Markdown table
+type markdownExporter struct{}
+
+func (markdownExporter) Name() string      { return "markdown" }
+func (markdownExporter) Extension() string { return ".md" }
+
+// Export writes the result as a Markdown table
+func (markdownExporter) Export(w io.Writer, result BenchmarkResult, opts ExportOptions) error {
+	var b strings.Builder
+
+	b.WriteString("| Algorithm |")
+	for _, col := range tableColumns {
+		fmt.Fprintf(&b, " %s (%s) |", col.label, col.unit)
+	}
+	b.WriteString("\n|:---|")
+	for range tableColumns {
+		b.WriteString("---:|")
+	}
+	b.WriteString("\n")
+
+	for i, row := range buildTable(result, opts) {
+		fmt.Fprintf(&b, "| %s |", result.Results[i].Algorithm)
+		for _, cell := range row {
+			if cell.ratio != "" {
+				fmt.Fprintf(&b, " %s (%s×) |", cell.value, cell.ratio)
+			} else {
+				fmt.Fprintf(&b, " %s |", cell.value)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "\n_Ratios in parentheses are relative to %s; %d iterations per algorithm._\n",
+		opts.Baseline, result.TestConfiguration.Iterations)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// latexExporter writes a booktabs tabular environment
+type latexExporter struct{}
+
+func (latexExporter) Name() string      { return "latex" }
+func (latexExporter) Extension() string { return ".tex" }
+
+// Export writes the result as a LaTeX booktabs table
+func (latexExporter) Export(w io.Writer, result BenchmarkResult, opts ExportOptions) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%% Generated from benchmark results of %s; requires \\usepackage{booktabs}\n", result.Timestamp)
+	b.WriteString("\\begin{tabular}{l" + strings.Repeat("r", len(tableColumns)) + "}\n\\toprule\n")
+	b.WriteString("Algorithm")
+	for _, col := range tableColumns {
+		fmt.Fprintf(&b, " & %s (%s)", col.label, col.unit)
+	}
+	b.WriteString(" \\\\\n\\midrule\n")
+
+	for i, row := range buildTable(result, opts) {
+		b.WriteString(latexEscape(result.Results[i].Algorithm))
+		for _, cell := range row {
+			if cell.ratio != "" {
+				fmt.Fprintf(&b, " & %s (%s$\\times$)", cell.value, cell.ratio)
+			} else {
+				fmt.Fprintf(&b, " & %s", cell.value)
+			}
+		}
+		b.WriteString(" \\\\\n")
+	}
+
+	b.WriteString("\\bottomrule\n\\end{tabular}\n")
+	fmt.Fprintf(&b, "%% Ratios in parentheses are relative to %s\n", latexEscape(opts.Baseline))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// latexEscape escapes characters with special meaning in LaTeX text
+func latexEscape(s string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		`&`, `\&`, `%`, `\%`, `$`, `\$`, `#`, `\#`,
+		`_`, `\_`, `{`, `\{`, `}`, `\}`,
+		`~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`,
+	)
+	return replacer.Replace(s)
+}
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"crypto-benchmark/msp"
+)
+
+// DefaultHistoryFile is the append-only JSONL index of every benchmark run
+const DefaultHistoryFile = "results/history.jsonl"
+
+// circlModulePath is used to look up the linked circl version in the build info
+const circlModulePath = "github.com/cloudflare/circl"
+
+// HistoryKey identifies the code, toolchain and host that produced a run
+type HistoryKey struct {
+	Commit          string `json:"commit"`
+	HostFingerprint string `json:"host_fingerprint"`
+	GoVersion       string `json:"go_version"`
+	CirclVersion    string `json:"circl_version"`
+}
+
+// HistoryEntry is one line of the history index
+type HistoryEntry struct {
+	HistoryKey
+	Timestamp   string              `json:"timestamp"`
+	ResultsFile string              `json:"results_file,omitempty"`
+	Iterations  int                 `json:"iterations"`
+	Results     []msp.CryptoMetrics `json:"results"`
+}
+
+// TrendQuery selects the runs and the metric to report in a trend
+type TrendQuery struct {
+	Algorithm       string
+	Operation       string
+	HostFingerprint string
+	GoVersion       string
+	CirclVersion    string
+}
+
+// TrendPoint is one run's value of the queried metric
+type TrendPoint struct {
+	HistoryKey
+	Timestamp string
+	Value     float64
+	Unit      string
+}
+
+// HistoryStore is an append-only JSONL store of benchmark runs
+type HistoryStore struct {
+	path string
+}
+
+// OpenHistoryStore returns a store backed by the given JSONL file
+func OpenHistoryStore(path string) *HistoryStore {
+	return &HistoryStore{path: path}
+}
+
+// NewHistoryEntry builds an index entry from a complete benchmark result
+func NewHistoryEntry(result BenchmarkResult, resultsFile string, key HistoryKey) HistoryEntry {
+	// Raw samples stay in the results file to keep the index small
+	results := make([]msp.CryptoMetrics, len(result.Results))
+	for i, r := range result.Results {
+		r.KeygenSamplesMs = nil
+		r.SignSamplesMs = nil
+		r.VerifySamplesMs = nil
+		results[i] = r
+	}
+
+	return HistoryEntry{
+		HistoryKey:  key,
+		Timestamp:   result.Timestamp,
+		ResultsFile: resultsFile,
+		Iterations:  result.TestConfiguration.Iterations,
+		Results:     results,
+	}
+}
+
+// Append writes one entry to the end of the store and syncs it to disk
+func (hs *HistoryStore) Append(entry HistoryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(hs.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %v", err)
+	}
+	line = append(line, '\n')
+
+	file, err := os.OpenFile(hs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history store: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("failed to append history entry: %v", err)
+	}
+	return file.Sync()
+}
+
+// Load reads every entry in the store, skipping lines that cannot be parsed
+// (for example a partial line left behind by an interrupted run)
+func (hs *HistoryStore) Load() ([]HistoryEntry, error) {
+	file, err := os.Open(hs.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history store: %v", err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var entry HistoryEntry
+			if json.Unmarshal(line, &entry) == nil {
+				entries = append(entries, entry)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history store: %v", err)
+		}
+	}
+
+	return entries, nil
+}
+
+// Trend returns the queried metric for every matching run, oldest first
+func (hs *HistoryStore) Trend(query TrendQuery) ([]TrendPoint, error) {
+	unit, ok := OperationUnit(query.Operation)
+	if !ok {
+		return nil, fmt.Errorf("unsupported operation: %s", query.Operation)
+	}
+
+	entries, err := hs.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var points []TrendPoint
+	for _, entry := range entries {
+		if !query.matches(entry.HistoryKey) {
+			continue
+		}
+
+		result, ok := findResult(entry.Results, query.Algorithm)
+		if !ok {
+			continue
+		}
+
+		value, _ := OperationValue(result, query.Operation)
+		points = append(points, TrendPoint{
+			HistoryKey: entry.HistoryKey,
+			Timestamp:  entry.Timestamp,
+			Value:      value,
+			Unit:       unit,
+		})
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return parseTimestamp(points[i].Timestamp).Before(parseTimestamp(points[j].Timestamp))
+	})
+	return points, nil
+}
+
+// parseTimestamp parses an RFC 3339 timestamp, treating malformed values as the zero time
+func parseTimestamp(timestamp string) time.Time {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// matches reports whether a run satisfies the key filters of the query
+func (q TrendQuery) matches(key HistoryKey) bool {
+	return (q.HostFingerprint == "" || q.HostFingerprint == key.HostFingerprint) &&
+		(q.GoVersion == "" || q.GoVersion == key.GoVersion) &&
+		(q.CirclVersion == "" || q.CirclVersion == key.CirclVersion)
+}
+
+// Operations lists the metric names understood by OperationValue
+var Operations = []string{"keygen", "sign", "verify", "public_key", "private_key", "signature"}
+
+// OperationValue returns the named timing or size metric of a result
+func OperationValue(result msp.CryptoMetrics, operation string) (float64, bool) {
+	switch operation {
+	case "keygen":
+		return result.KeygenTimeMs, true
+	case "sign":
+		return result.SignTimeMs, true
+	case "verify":
+		return result.VerifyTimeMs, true
+	case "public_key":
+		return float64(result.PublicKeyBytes), true
+	case "private_key":
+		return float64(result.PrivateKeyBytes), true
+	case "signature":
+		return float64(result.SignatureBytes), true
+	default:
+		return 0, false
+	}
+}
+
+// OperationUnit returns the unit of the named metric
+func OperationUnit(operation string) (string, bool) {
+	switch operation {
+	case "keygen", "sign", "verify":
+		return "ms", true
+	case "public_key", "private_key", "signature":
+		return "bytes", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"crypto-benchmark/msp"
+)
+
+// DefaultLatencyBuckets are histogram upper bounds in seconds, from 10µs to 100ms
+var DefaultLatencyBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1,
+}
+
+// Prometheus metric names exposed by PrometheusExporter
+const (
+	metricOperationDuration = "pqc_crypto_operation_duration_seconds"
+	metricOperationMean     = "pqc_crypto_operation_mean_seconds"
+	metricKeySize           = "pqc_crypto_key_size_bytes"
+	metricSignatureSize     = "pqc_crypto_signature_size_bytes"
+	metricRunsTotal         = "pqc_crypto_benchmark_runs_total"
+	metricRunFailuresTotal  = "pqc_crypto_benchmark_run_failures_total"
+	metricLastRun           = "pqc_crypto_benchmark_last_run_timestamp_seconds"
+	metricBuildInfo         = "pqc_crypto_benchmark_build_info"
+)
+
+// seriesKey identifies one algorithm and operation pair
+type seriesKey struct {
+	algorithm string
+	operation string
+}
+
+// histogram is a cumulative Prometheus histogram
+type histogram struct {
+	counts []uint64 // Per-bucket counts, not cumulative; the last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+// PrometheusExporter accumulates benchmark results and serves them in the
+// Prometheus text exposition format
+type PrometheusExporter struct {
+	mu          sync.Mutex
+	buckets     []float64
+	environment Environment
+	histograms  map[seriesKey]*histogram
+	means       map[seriesKey]float64
+	latest      map[string]msp.CryptoMetrics
+	runs        map[string]uint64
+	failures    map[string]uint64
+	lastRun     map[string]time.Time
+}
+
+// NewPrometheusExporter creates an exporter with the given latency buckets in seconds
+func NewPrometheusExporter(buckets []float64, environment Environment) *PrometheusExporter {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &PrometheusExporter{
+		buckets:     sorted,
+		environment: environment,
+		histograms:  make(map[seriesKey]*histogram),
+		means:       make(map[seriesKey]float64),
+		latest:      make(map[string]msp.CryptoMetrics),
+		runs:        make(map[string]uint64),
+		failures:    make(map[string]uint64),
+		lastRun:     make(map[string]time.Time),
+	}
+}
+
+// Observe records every raw sample of a benchmark result
+func (pe *PrometheusExporter) Observe(result msp.CryptoMetrics) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	for _, op := range []string{"keygen", "sign", "verify"} {
+		key := seriesKey{algorithm: result.Algorithm, operation: op}
+		h, ok := pe.histograms[key]
+		if !ok {
+			h = &histogram{counts: make([]uint64, len(pe.buckets)+1)}
+			pe.histograms[key] = h
+		}
+
+		for _, ms := range OperationSamples(result, op) {
+			seconds := ms / 1e3
+			bucket := sort.SearchFloat64s(pe.buckets, seconds)
+			h.counts[bucket]++
+			h.sum += seconds
+			h.count++
+		}
+
+		mean, _ := OperationValue(result, op)
+		pe.means[key] = mean / 1e3
+	}
+
+	pe.latest[result.Algorithm] = result
+	pe.runs[result.Algorithm]++
+	pe.lastRun[result.Algorithm] = time.Now()
+}
+
+// ObserveFailure counts a benchmark run that did not complete
+func (pe *PrometheusExporter) ObserveFailure(algorithm string) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	pe.failures[algorithm]++
+}
+
+// ServeHTTP writes the current metrics in the Prometheus text format
+func (pe *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	pe.WriteMetrics(w)
+}
+
+// WriteMetrics writes the current metrics in the Prometheus text format
+func (pe *PrometheusExporter) WriteMetrics(w io.Writer) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	var b strings.Builder
+
+	writeMetricHeader(&b, metricBuildInfo, "gauge", "Host and build that produced the benchmark metrics")
+	fmt.Fprintf(&b, "%s{%s} 1\n", metricBuildInfo, formatLabels(
+		"host", pe.environment.Hostname,
+		"host_fingerprint", pe.environment.HostFingerprint,
+		"go_version", pe.environment.GoVersion,
+		"circl_version", pe.environment.CirclVersion,
+		"revision", pe.environment.VCSRevision,
+	))
+
+	keys := make([]seriesKey, 0, len(pe.histograms))
+	for key := range pe.histograms {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].algorithm != keys[j].algorithm {
+			return keys[i].algorithm < keys[j].algorithm
+		}
+		return keys[i].operation < keys[j].operation
+	})
+
+	writeMetricHeader(&b, metricOperationDuration, "histogram", "Latency of individual cryptographic operations")
+	for _, key := range keys {
+		h := pe.histograms[key]
+		var cumulative uint64
+		for i, upper := range pe.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "%s_bucket{%s} %d\n", metricOperationDuration,
+				formatLabels("algorithm", key.algorithm, "operation", key.operation, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s} %d\n", metricOperationDuration,
+			formatLabels("algorithm", key.algorithm, "operation", key.operation, "le", "+Inf"), h.count)
+		labels := formatLabels("algorithm", key.algorithm, "operation", key.operation)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", metricOperationDuration, labels, formatFloat(h.sum))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", metricOperationDuration, labels, h.count)
+	}
+
+	writeMetricHeader(&b, metricOperationMean, "gauge", "Mean operation latency of the most recent run")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s{%s} %s\n", metricOperationMean,
+			formatLabels("algorithm", key.algorithm, "operation", key.operation), formatFloat(pe.means[key]))
+	}
+
+	algorithms := make([]string, 0, len(pe.latest))
+	for alg := range pe.latest {
+		algorithms = append(algorithms, alg)
+	}
+	sort.Strings(algorithms)
+
+	writeMetricHeader(&b, metricKeySize, "gauge", "Encoded key size")
+	for _, alg := range algorithms {
+		r := pe.latest[alg]
+		fmt.Fprintf(&b, "%s{%s} %d\n", metricKeySize, formatLabels("algorithm", alg, "key", "public"), r.PublicKeyBytes)
+		fmt.Fprintf(&b, "%s{%s} %d\n", metricKeySize, formatLabels("algorithm", alg, "key", "private"), r.PrivateKeyBytes)
+	}
+
+	writeMetricHeader(&b, metricSignatureSize, "gauge", "Encoded signature size")
+	for _, alg := range algorithms {
+		fmt.Fprintf(&b, "%s{%s} %d\n", metricSignatureSize, formatLabels("algorithm", alg), pe.latest[alg].SignatureBytes)
+	}
+
+	writeMetricHeader(&b, metricRunsTotal, "counter", "Completed benchmark runs")
+	for _, alg := range algorithms {
+		fmt.Fprintf(&b, "%s{%s} %d\n", metricRunsTotal, formatLabels("algorithm", alg), pe.runs[alg])
+	}
+
+	failed := make([]string, 0, len(pe.failures))
+	for alg := range pe.failures {
+		failed = append(failed, alg)
+	}
+	sort.Strings(failed)
+	writeMetricHeader(&b, metricRunFailuresTotal, "counter", "Benchmark runs that returned an error")
+	for _, alg := range failed {
+		fmt.Fprintf(&b, "%s{%s} %d\n", metricRunFailuresTotal, formatLabels("algorithm", alg), pe.failures[alg])
+	}
+
+	writeMetricHeader(&b, metricLastRun, "gauge", "Unix time of the most recent completed run")
+	for _, alg := range algorithms {
+		fmt.Fprintf(&b, "%s{%s} %d\n", metricLastRun, formatLabels("algorithm", alg), pe.lastRun[alg].Unix())
+	}
+
+	io.WriteString(w, b.String())
+}
+
+// writeMetricHeader writes the HELP and TYPE lines of a metric family
+func writeMetricHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// formatLabels renders alternating label names and values as a label set
+func formatLabels(pairs ...string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], escaper.Replace(pairs[i+1])))
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatFloat renders a sample value the way the Prometheus client libraries do
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,256 @@
+package metrics
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"crypto-benchmark/msp"
+)
+
+// histogramBins is the number of bins in latency histograms
+const histogramBins = 30
+
+// ReportInput is one results file to include in an HTML report
+type ReportInput struct {
+	Name   string
+	Result BenchmarkResult
+}
+
+// reportRun is the template view of one results file
+type reportRun struct {
+	Name          string
+	Result        BenchmarkResult
+	Distributions []template.HTML
+	HasSamples    bool
+}
+
+// reportView is the template view of the whole report
+type reportView struct {
+	Generated    string
+	Runs         []reportRun
+	TimingCharts []template.HTML
+	SizeChart    template.HTML
+}
+
+// SaveHTMLReport writes a self-contained HTML report to the given file
+func SaveHTMLReport(filename string, inputs []ReportInput) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %v", err)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %v", err)
+	}
+	defer file.Close()
+
+	if err := WriteHTMLReport(file, inputs); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// WriteHTMLReport renders the report with inline SVG charts and no external assets
+func WriteHTMLReport(w io.Writer, inputs []ReportInput) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("no results to report")
+	}
+
+	view := reportView{
+		Generated: time.Now().Format(time.RFC3339),
+	}
+
+	// Timing bar charts compare every run side by side for each operation
+	algorithms := reportAlgorithms(inputs)
+	for _, op := range []string{"keygen", "sign", "verify"} {
+		var series []chartSeries
+		for _, input := range inputs {
+			s := chartSeries{Name: input.Name}
+			for _, alg := range algorithms {
+				r, _ := findResult(input.Result.Results, alg)
+				value, _ := OperationValue(r, op)
+				s.Values = append(s.Values, value)
+			}
+			series = append(series, s)
+		}
+		view.TimingCharts = append(view.TimingCharts, svgBarChart(operationTitle(op)+" (mean)", "ms", algorithms, series))
+	}
+
+	// Sizes do not vary between runs, so the first file is representative
+	first := inputs[0].Result
+	var sizeSeries []chartSeries
+	for _, op := range []string{"public_key", "private_key", "signature"} {
+		s := chartSeries{Name: operationTitle(op)}
+		for _, r := range first.Results {
+			value, _ := OperationValue(r, op)
+			s.Values = append(s.Values, value)
+		}
+		sizeSeries = append(sizeSeries, s)
+	}
+	view.SizeChart = svgBarChart("Key and Signature Sizes", "bytes", resultAlgorithms(first.Results), sizeSeries)
+
+	for _, input := range inputs {
+		run := reportRun{Name: input.Name, Result: input.Result}
+		for _, op := range []string{"keygen", "sign", "verify"} {
+			histogram, cdf, ok := distributionCharts(input.Result.Results, op)
+			if !ok {
+				continue
+			}
+			run.HasSamples = true
+			run.Distributions = append(run.Distributions, histogram, cdf)
+		}
+		view.Runs = append(view.Runs, run)
+	}
+
+	return reportTemplate.Execute(w, view)
+}
+
+// distributionCharts renders the latency histogram and CDF of one operation across algorithms
+func distributionCharts(results []msp.CryptoMetrics, op string) (template.HTML, template.HTML, bool) {
+	var all []float64
+	for _, r := range results {
+		all = append(all, OperationSamples(r, op)...)
+	}
+	if len(all) == 0 {
+		return "", "", false
+	}
+
+	// Clip the axis at the 99th percentile so single outliers do not flatten the plot
+	xMax := Percentile(all, 99)
+
+	var histLines, cdfLines []chartLine
+	var yMax float64
+	for _, r := range results {
+		samples := OperationSamples(r, op)
+		if len(samples) == 0 {
+			continue
+		}
+
+		hist := histogramLine(samples, xMax, histogramBins)
+		for _, p := range hist {
+			if p[1] > yMax {
+				yMax = p[1]
+			}
+		}
+		histLines = append(histLines, chartLine{Name: r.Algorithm, Points: hist})
+		cdfLines = append(cdfLines, chartLine{Name: r.Algorithm, Points: cdfLine(samples)})
+	}
+
+	title := operationTitle(op)
+	histogram := svgLineChart(title+" Latency Histogram", "ms", "fraction of samples", histLines, xMax, yMax)
+	cdf := svgLineChart(title+" Latency CDF", "ms", "cumulative fraction", cdfLines, xMax, 1)
+	return histogram, cdf, true
+}
+
+// OperationSamples returns the raw timing samples of the named operation
+func OperationSamples(result msp.CryptoMetrics, operation string) []float64 {
+	switch operation {
+	case "keygen":
+		return result.KeygenSamplesMs
+	case "sign":
+		return result.SignSamplesMs
+	case "verify":
+		return result.VerifySamplesMs
+	default:
+		return nil
+	}
+}
+
+// operationTitle returns a display name for an operation
+func operationTitle(operation string) string {
+	for _, col := range tableColumns {
+		if col.operation == operation {
+			return col.label
+		}
+	}
+	return operation
+}
+
+// reportAlgorithms returns the algorithms present in any input, in first-seen order
+func reportAlgorithms(inputs []ReportInput) []string {
+	seen := map[string]bool{}
+	var algorithms []string
+	for _, input := range inputs {
+		for _, alg := range resultAlgorithms(input.Result.Results) {
+			if !seen[alg] {
+				seen[alg] = true
+				algorithms = append(algorithms, alg)
+			}
+		}
+	}
+	return algorithms
+}
+
+// resultAlgorithms returns the algorithm names of the results in order
+func resultAlgorithms(results []msp.CryptoMetrics) []string {
+	algorithms := make([]string, len(results))
+	for i, r := range results {
+		algorithms[i] = r.Algorithm
+	}
+	return algorithms
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"sig": func(v float64) string { return FormatSignificant(v, 4) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>ECDSA vs ML-DSA Benchmark Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 1340px; color: #222; }
+h1, h2, h3 { font-weight: 600; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.charts { display: flex; flex-wrap: wrap; gap: 1em; }
+.note { color: #666; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>ECDSA vs ML-DSA Benchmark Report</h1>
+<p class="note">Generated {{.Generated}} from {{len .Runs}} results file(s).</p>
+
+<h2>Operation Timings</h2>
+<div class="charts">{{range .TimingCharts}}{{.}}{{end}}</div>
+
+<h2>Key and Signature Sizes</h2>
+<div class="charts">{{.SizeChart}}</div>
+
+{{range .Runs}}
+<h2>Run: {{.Name}}</h2>
+<table>
+<tr><th>Algorithm</th><th>Key Generation (ms)</th><th>Signing (ms)</th><th>Verification (ms)</th><th>Public Key (bytes)</th><th>Private Key (bytes)</th><th>Signature (bytes)</th></tr>
+{{range .Result.Results}}<tr><td>{{.Algorithm}}</td><td>{{sig .KeygenTimeMs}}</td><td>{{sig .SignTimeMs}}</td><td>{{sig .VerifyTimeMs}}</td><td>{{.PublicKeyBytes}}</td><td>{{.PrivateKeyBytes}}</td><td>{{.SignatureBytes}}</td></tr>
+{{end}}</table>
+
+<h3>Latency Distributions</h3>
+{{if .HasSamples}}<div class="charts">{{range .Distributions}}{{.}}{{end}}</div>
+{{else}}<p class="note">This results file has no raw samples; re-run the benchmark to record them.</p>{{end}}
+
+<h3>Environment</h3>
+<table>
+<tr><td>Timestamp</td><td>{{.Result.Timestamp}}</td></tr>
+<tr><td>Iterations</td><td>{{.Result.TestConfiguration.Iterations}}</td></tr>
+<tr><td>Test Duration</td><td>{{.Result.TestConfiguration.TestDuration}}</td></tr>
+<tr><td>Test Message</td><td>{{.Result.TestConfiguration.TestMessage}}</td></tr>
+<tr><td>Algorithms</td><td>{{range $i, $a := .Result.TestConfiguration.Algorithms}}{{if $i}}, {{end}}{{$a}}{{end}}</td></tr>
+{{with .Result.Environment}}<tr><td>Host</td><td>{{.Hostname}} ({{.HostFingerprint}})</td></tr>
+<tr><td>CPU</td><td>{{.CPUModel}}, {{.NumCPU}} cores (GOMAXPROCS {{.GOMAXPROCS}})</td></tr>
+<tr><td>CPU Flags</td><td>{{range $i, $f := .CPUFlags}}{{if $i}} {{end}}{{$f}}{{end}}</td></tr>
+<tr><td>Frequency Governor</td><td>{{.FrequencyGovernor}}</td></tr>
+<tr><td>Kernel</td><td>{{.Kernel}}</td></tr>
+<tr><td>Go</td><td>{{.GoVersion}} {{.GOOS}}/{{.GOARCH}}{{if .GOAMD64}} GOAMD64={{.GOAMD64}}{{end}}</td></tr>
+<tr><td>circl</td><td>{{.CirclVersion}}</td></tr>
+<tr><td>Build Flags</td><td>{{range $k, $v := .BuildFlags}}{{$k}}={{$v}} {{end}}</td></tr>
+<tr><td>Revision</td><td>{{.VCSRevision}}{{if .VCSModified}} (modified){{end}} {{.VCSTime}}</td></tr>
+{{else}}<tr><td>Host and Build</td><td>not recorded in this results file</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
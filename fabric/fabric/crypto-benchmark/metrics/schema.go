@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// SchemaVersion is the results file schema version written by this build.
+// Adding optional fields keeps the version; renaming, removing or changing the
+// meaning of a field bumps it and adds a migration below.
+const SchemaVersion = 2
+
+// Kinds of results files sharing the results directory
+const (
+	KindCryptoBenchmark      = "crypto_benchmark"
+	KindBlockchainSimulation = "blockchain_simulation"
+)
+
+// SimulationInfo describes the parameters of a blockchain throughput simulation
+type SimulationInfo struct {
+	Timestamp         string   `json:"timestamp"`
+	TxPerBlock        int      `json:"tx_per_block"`
+	TotalBlocks       int      `json:"total_blocks"`
+	TotalTransactions int      `json:"total_transactions"`
+	SimulationTypes   []string `json:"simulation_types"`
+}
+
+// SimulationMetrics holds the throughput results of one algorithm in one simulation mode
+type SimulationMetrics struct {
+	Algorithm         string  `json:"algorithm"`
+	TPS               float64 `json:"tps"`
+	AvgSignTimeMs     float64 `json:"avg_sign_time_ms"`
+	AvgVerifyTimeMs   float64 `json:"avg_verify_time_ms"`
+	AvgTxLatencyMs    float64 `json:"avg_tx_latency_ms"`
+	AvgBlockTimeMs    float64 `json:"avg_block_time_ms"`
+	AvgBlockSizeBytes float64 `json:"avg_block_size_bytes"`
+}
+
+// SimulationResult is a results file written by bk_tps.py
+type SimulationResult struct {
+	SchemaVersion     int                 `json:"schema_version"`
+	Kind              string              `json:"kind"`
+	SimulationInfo    SimulationInfo      `json:"simulation_info"`
+	SequentialResults []SimulationMetrics `json:"sequential_results"`
+	ParallelResults   []SimulationMetrics `json:"parallel_results"`
+}
+
+// ResultsFile is a results file of either kind, upgraded to the current schema
+type ResultsFile struct {
+	Kind            string
+	OriginalVersion int
+	Crypto          *BenchmarkResult
+	Simulation      *SimulationResult
+}
+
+// migration upgrades a decoded document by exactly one schema version in place
+type migration func(doc map[string]interface{}) error
+
+// migrations lists the upgrade steps per kind; entry i upgrades version i+1 to i+2
+var migrations = map[string][]migration{
+	KindCryptoBenchmark: {
+		// v1 -> v2: files gained schema_version and kind; the payload is unchanged
+		func(doc map[string]interface{}) error { return nil },
+	},
+	KindBlockchainSimulation: {
+		// v1 -> v2: sequential results used avg_latency_ms while parallel results
+		// used avg_tx_latency_ms for the same quantity
+		func(doc map[string]interface{}) error {
+			results, _ := doc["sequential_results"].([]interface{})
+			for _, r := range results {
+				entry, ok := r.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if latency, ok := entry["avg_latency_ms"]; ok {
+					if _, exists := entry["avg_tx_latency_ms"]; !exists {
+						entry["avg_tx_latency_ms"] = latency
+					}
+					delete(entry, "avg_latency_ms")
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// LoadResultsFile reads a results file of any kind and upgrades it to the current schema
+func LoadResultsFile(filename string) (*ResultsFile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results file: %v", err)
+	}
+
+	file, err := ParseResultsFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse results file %s: %v", filename, err)
+	}
+	return file, nil
+}
+
+// LoadResults reads a crypto benchmark results file written by SaveResults,
+// upgrading files written with older schema versions
+func LoadResults(filename string) (*BenchmarkResult, error) {
+	file, err := LoadResultsFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if file.Crypto == nil {
+		return nil, fmt.Errorf("%s is a %s file, not a %s file", filename, file.Kind, KindCryptoBenchmark)
+	}
+	return file.Crypto, nil
+}
+
+// ParseResultsFile decodes and upgrades a results document
+func ParseResultsFile(data []byte) (*ResultsFile, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	kind, version, err := UpgradeDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ResultsFile{Kind: kind, OriginalVersion: version}
+	switch kind {
+	case KindCryptoBenchmark:
+		file.Crypto = &BenchmarkResult{}
+		err = json.Unmarshal(upgraded, file.Crypto)
+	case KindBlockchainSimulation:
+		file.Simulation = &SimulationResult{}
+		err = json.Unmarshal(upgraded, file.Simulation)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// UpgradeDocument applies every migration needed to bring a decoded document to
+// SchemaVersion and returns its kind and original version
+func UpgradeDocument(doc map[string]interface{}) (string, int, error) {
+	kind, err := documentKind(doc)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Files written before versioning have no schema_version and are version 1
+	version := 1
+	if v, ok := doc["schema_version"].(float64); ok {
+		version = int(v)
+	}
+	if version < 1 {
+		return "", 0, fmt.Errorf("invalid schema version: %d", version)
+	}
+	if version > SchemaVersion {
+		return "", 0, fmt.Errorf("schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	original := version
+	for ; version < SchemaVersion; version++ {
+		if err := migrations[kind][version-1](doc); err != nil {
+			return "", 0, fmt.Errorf("failed to migrate %s from version %d: %v", kind, version, err)
+		}
+	}
+
+	doc["schema_version"] = SchemaVersion
+	doc["kind"] = kind
+	return kind, original, nil
+}
+
+// documentKind identifies the kind of a document from its kind field or its shape
+func documentKind(doc map[string]interface{}) (string, error) {
+	if kind, ok := doc["kind"].(string); ok {
+		if _, known := migrations[kind]; !known {
+			return "", fmt.Errorf("unknown results kind: %s", kind)
+		}
+		return kind, nil
+	}
+
+	if _, ok := doc["test_configuration"]; ok {
+		return KindCryptoBenchmark, nil
+	}
+	if _, ok := doc["simulation_info"]; ok {
+		return KindBlockchainSimulation, nil
+	}
+	return "", fmt.Errorf("unrecognized results file layout")
+}
+
+// MigrateFile upgrades a results file to the current schema and writes it to dst,
+// which may be the same path as src
+func MigrateFile(src, dst string) (*ResultsFile, error) {
+	file, err := LoadResultsFile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload interface{} = file.Crypto
+	if file.Simulation != nil {
+		payload = file.Simulation
+	}
+
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal migrated results: %v", err)
+	}
+
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write migrated results: %v", err)
+	}
+	return file, nil
+}
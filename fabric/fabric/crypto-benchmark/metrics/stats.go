@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+)
+
+// Mean returns the arithmetic mean of the samples
+func Mean(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	var total float64
+	for _, s := range samples {
+		total += s
+	}
+	return total / float64(len(samples))
+}
+
+// Variance returns the unbiased sample variance of the samples
+func Variance(samples []float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	mean := Mean(samples)
+	var sum float64
+	for _, s := range samples {
+		d := s - mean
+		sum += d * d
+	}
+	return sum / float64(len(samples)-1)
+}
+
+// WelchTTest performs Welch's unequal-variance t-test and returns the t statistic
+// and the two-sided p-value
+func WelchTTest(a, b []float64) (t float64, p float64) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, 1
+	}
+
+	n1, n2 := float64(len(a)), float64(len(b))
+	s1, s2 := Variance(a)/n1, Variance(b)/n2
+	diff := Mean(a) - Mean(b)
+
+	if s1+s2 == 0 {
+		if diff == 0 {
+			return 0, 1
+		}
+		return math.Copysign(math.Inf(1), diff), 0
+	}
+
+	t = diff / math.Sqrt(s1+s2)
+	df := (s1 + s2) * (s1 + s2) / (s1*s1/(n1-1) + s2*s2/(n2-1))
+	p = regularizedIncompleteBeta(df/(df+t*t), df/2, 0.5)
+	return t, p
+}
+
+// MannWhitneyU performs the Mann-Whitney U test using the normal approximation
+// with tie correction and returns the U statistic of a and the two-sided p-value
+func MannWhitneyU(a, b []float64) (u float64, p float64) {
+	n1, n2 := len(a), len(b)
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	type ranked struct {
+		value float64
+		first bool
+	}
+	combined := make([]ranked, 0, n1+n2)
+	for _, v := range a {
+		combined = append(combined, ranked{value: v, first: true})
+	}
+	for _, v := range b {
+		combined = append(combined, ranked{value: v})
+	}
+	sort.Slice(combined, func(i, j int) bool { return combined[i].value < combined[j].value })
+
+	// Assign average ranks to ties and accumulate the tie correction term
+	var rankSum, tieTerm float64
+	for i := 0; i < len(combined); {
+		j := i
+		for j < len(combined) && combined[j].value == combined[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if combined[k].first {
+				rankSum += rank
+			}
+		}
+		ties := float64(j - i)
+		tieTerm += ties*ties*ties - ties
+		i = j
+	}
+
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+	u = rankSum - fn1*(fn1+1)/2
+	mu := fn1 * fn2 / 2
+	sigma := math.Sqrt(fn1 * fn2 / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return u, 1
+	}
+
+	// Continuity correction towards the mean
+	z := math.Abs(u-mu) - 0.5
+	if z < 0 {
+		z = 0
+	}
+	z /= sigma
+	return u, math.Erfc(z / math.Sqrt2)
+}
+
+// regularizedIncompleteBeta evaluates I_x(a, b) using a continued fraction expansion
+func regularizedIncompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// The continued fraction converges quickly only on one side of the mean
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction for the incomplete beta function
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+
+	qab, qap, qam := a+b, a+1, a-1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+
+		if math.Abs(del-1) < epsilon {
+			break
+		}
+	}
+
+	return h
+}
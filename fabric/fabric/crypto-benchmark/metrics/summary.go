@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"crypto-benchmark/msp"
+)
+
+// CostWeights configures the composite cost-per-transaction score.
+//
+// For every transaction the client signs once and each endorsement is verified
+// at commit time, while the signatures travel in every block copy:
+//
+//	cost = Sign*sign_ms + Verify*verify_ms*Endorsers + Bytes*signature_bytes*(Endorsers+1)*Blocks
+//
+// Bytes is the weight of one stored byte in the same ms-equivalent units.
+type CostWeights struct {
+	Sign      float64 `json:"sign"`
+	Verify    float64 `json:"verify"`
+	Endorsers int     `json:"endorsers"`
+	Bytes     float64 `json:"bytes"`
+	Blocks    int     `json:"blocks"`
+}
+
+// DefaultCostWeights returns weights for a three-endorser policy on a single ledger copy
+func DefaultCostWeights() CostWeights {
+	return CostWeights{
+		Sign:      1,
+		Verify:    1,
+		Endorsers: 3,
+		Bytes:     0.0001,
+		Blocks:    1,
+	}
+}
+
+// ParseCostWeights parses "sign=1,verify=1,endorsers=3,bytes=0.0001,blocks=1",
+// starting from the defaults for any weight that is not given
+func ParseCostWeights(value string) (CostWeights, error) {
+	weights := DefaultCostWeights()
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, raw, found := strings.Cut(item, "=")
+		if !found {
+			return weights, fmt.Errorf("invalid cost weight %q, expected name=value", item)
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return weights, fmt.Errorf("invalid cost weight %q: %v", item, err)
+		}
+
+		switch strings.TrimSpace(name) {
+		case "sign":
+			weights.Sign = number
+		case "verify":
+			weights.Verify = number
+		case "endorsers":
+			weights.Endorsers = int(number)
+		case "bytes":
+			weights.Bytes = number
+		case "blocks":
+			weights.Blocks = int(number)
+		default:
+			return weights, fmt.Errorf("unknown cost weight: %s", name)
+		}
+	}
+	return weights, nil
+}
+
+// Cost returns the composite cost-per-transaction score of a result
+func (w CostWeights) Cost(result msp.CryptoMetrics) float64 {
+	signatureBytes := float64(result.SignatureBytes * (w.Endorsers + 1) * w.Blocks)
+	return w.Sign*result.SignTimeMs + w.Verify*result.VerifyTimeMs*float64(w.Endorsers) + w.Bytes*signatureBytes
+}
+
+// SummaryOptions configures the rankings in a Summary
+type SummaryOptions struct {
+	Baseline string
+	Weights  CostWeights
+}
+
+// DefaultSummaryOptions ranks relative to ECDSA with the default cost weights
+func DefaultSummaryOptions() SummaryOptions {
+	return SummaryOptions{
+		Baseline: msp.ECDSA.String(),
+		Weights:  DefaultCostWeights(),
+	}
+}
+
+// RankEntry is one algorithm's position in a ranking
+type RankEntry struct {
+	Rank             int     `json:"rank"`
+	Algorithm        string  `json:"algorithm"`
+	Value            float64 `json:"value"`
+	Unit             string  `json:"unit"`
+	VsBaseline       float64 `json:"vs_baseline,omitempty"`
+	SecurityCategory int     `json:"nist_security_category"`
+}
+
+// MetricRanking orders all algorithms by one metric, lowest first
+type MetricRanking struct {
+	Metric  string      `json:"metric"`
+	Entries []RankEntry `json:"entries"`
+}
+
+// SetSummaryOptions changes the baseline and cost weights used by GenerateSummary
+func (mc *MetricsCollector) SetSummaryOptions(opts SummaryOptions) {
+	mc.summaryOptions = opts
+}
+
+// rankResults builds the full ranking of every metric and the composite cost ranking
+func rankResults(results []msp.CryptoMetrics, opts SummaryOptions) ([]MetricRanking, []RankEntry) {
+	baseline, hasBaseline := findResult(results, opts.Baseline)
+
+	var rankings []MetricRanking
+	for _, op := range Operations {
+		unit, _ := OperationUnit(op)
+		value := func(r msp.CryptoMetrics) float64 {
+			v, _ := OperationValue(r, op)
+			return v
+		}
+		rankings = append(rankings, MetricRanking{
+			Metric:  op,
+			Entries: rankBy(results, value, unit, baseline, hasBaseline),
+		})
+	}
+
+	cost := rankBy(results, opts.Weights.Cost, "cost", baseline, hasBaseline)
+	return rankings, cost
+}
+
+// rankBy orders results by ascending value and computes ratios against the baseline
+func rankBy(results []msp.CryptoMetrics, value func(msp.CryptoMetrics) float64, unit string, baseline msp.CryptoMetrics, hasBaseline bool) []RankEntry {
+	entries := make([]RankEntry, len(results))
+	for i, r := range results {
+		entries[i] = RankEntry{
+			Algorithm:        r.Algorithm,
+			Value:            value(r),
+			Unit:             unit,
+			SecurityCategory: securityCategory(r.Algorithm),
+		}
+		if hasBaseline && value(baseline) > 0 {
+			entries[i].VsBaseline = entries[i].Value / value(baseline)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Value < entries[j].Value })
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries
+}
+
+// securityCategory returns the NIST category of a named algorithm, or 0 if unknown
+func securityCategory(algorithm string) int {
+	sa, err := msp.ParseSignatureAlgorithm(algorithm)
+	if err != nil {
+		return 0
+	}
+	return sa.NISTSecurityCategory()
+}
+
+// printRankings prints every ranking with ratios against the baseline
+func printRankings(summary Summary) {
+	fmt.Printf("\nRankings (ratio vs %s, NIST category in brackets):\n", summary.Baseline)
+	for _, ranking := range summary.Rankings {
+		fmt.Printf("  %s:\n", operationTitle(ranking.Metric))
+		printRankEntries(ranking.Entries)
+	}
+
+	w := summary.CostWeights
+	fmt.Printf("\nCost per Transaction (sign×%g + verify×%g×%d endorsers + bytes×%g×%d blocks):\n",
+		w.Sign, w.Verify, w.Endorsers, w.Bytes, w.Blocks)
+	printRankEntries(summary.CostRanking)
+}
+
+// printRankEntries prints one line per ranked algorithm
+func printRankEntries(entries []RankEntry) {
+	for _, e := range entries {
+		ratio := "    -"
+		if e.VsBaseline > 0 {
+			ratio = fmt.Sprintf("%5.2fx", e.VsBaseline)
+		}
+		value := FormatSignificant(e.Value, 4)
+		if e.Unit == "bytes" {
+			value = strconv.Itoa(int(e.Value))
+		}
+		fmt.Printf("    %d. %-12s %12s %-5s %s  [%s]\n",
+			e.Rank, e.Algorithm, value, e.Unit, ratio, categoryLabel(e.SecurityCategory))
+	}
+}
+
+// categoryLabel describes a NIST security category for display
+func categoryLabel(category int) string {
+	if category == 0 {
+		return "classical"
+	}
+	return fmt.Sprintf("category %d", category)
+}
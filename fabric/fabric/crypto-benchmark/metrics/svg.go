@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+)
+
+// chartPalette holds the series colours used by every chart
+var chartPalette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// Common chart geometry in SVG user units
+const (
+	chartWidth        = 640
+	chartHeight       = 320
+	chartMarginLeft   = 70
+	chartMarginRight  = 20
+	chartMarginTop    = 40
+	chartMarginBottom = 50
+	chartTicks        = 5
+)
+
+// chartSeries is one named set of bar values, one per category
+type chartSeries struct {
+	Name   string
+	Values []float64
+}
+
+// chartLine is one named polyline in data coordinates
+type chartLine struct {
+	Name   string
+	Points [][2]float64
+}
+
+// svgBarChart renders a grouped bar chart with one group per category
+func svgBarChart(title, unit string, categories []string, series []chartSeries) template.HTML {
+	var maxValue float64
+	for _, s := range series {
+		for _, v := range s.Values {
+			maxValue = math.Max(maxValue, v)
+		}
+	}
+	yMax := niceCeiling(maxValue)
+
+	var b strings.Builder
+	writeChartFrame(&b, title, unit, yMax)
+
+	plotW := float64(chartWidth - chartMarginLeft - chartMarginRight)
+	plotH := float64(chartHeight - chartMarginTop - chartMarginBottom)
+	groupW := plotW / float64(len(categories))
+	barW := groupW * 0.8 / float64(len(series))
+
+	for i, category := range categories {
+		groupX := float64(chartMarginLeft) + groupW*float64(i)
+		for j, s := range series {
+			if i >= len(s.Values) {
+				continue
+			}
+			h := s.Values[i] / yMax * plotH
+			x := groupX + groupW*0.1 + barW*float64(j)
+			y := float64(chartMarginTop) + plotH - h
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s %s: %s %s</title></rect>`,
+				x, y, barW, h, chartPalette[j%len(chartPalette)],
+				html.EscapeString(category), html.EscapeString(s.Name), FormatSignificant(s.Values[i], 4), html.EscapeString(unit))
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" font-size="12">%s</text>`,
+			groupX+groupW/2, chartHeight-chartMarginBottom+18, html.EscapeString(category))
+	}
+
+	names := make([]string, len(series))
+	for i, s := range series {
+		names[i] = s.Name
+	}
+	writeLegend(&b, names)
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// svgLineChart renders polylines on linear axes from zero to the given maxima
+func svgLineChart(title, xUnit, yUnit string, lines []chartLine, xMax, yMax float64) template.HTML {
+	xMax = niceCeiling(xMax)
+	yMax = niceCeiling(yMax)
+
+	var b strings.Builder
+	writeChartFrame(&b, title, yUnit, yMax)
+
+	plotW := float64(chartWidth - chartMarginLeft - chartMarginRight)
+	plotH := float64(chartHeight - chartMarginTop - chartMarginBottom)
+
+	for i := 0; i <= chartTicks; i++ {
+		value := xMax * float64(i) / chartTicks
+		x := float64(chartMarginLeft) + plotW*float64(i)/chartTicks
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" font-size="11">%s</text>`,
+			x, chartHeight-chartMarginBottom+16, FormatSignificant(value, 3))
+	}
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" font-size="12">%s</text>`,
+		float64(chartMarginLeft)+plotW/2, chartHeight-8, html.EscapeString(xUnit))
+
+	names := make([]string, len(lines))
+	for i, line := range lines {
+		names[i] = line.Name
+		points := make([]string, len(line.Points))
+		for j, p := range line.Points {
+			x := float64(chartMarginLeft) + math.Min(p[0]/xMax, 1)*plotW
+			y := float64(chartMarginTop) + plotH - math.Min(p[1]/yMax, 1)*plotH
+			points[j] = fmt.Sprintf("%.1f,%.1f", x, y)
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`,
+			chartPalette[i%len(chartPalette)], strings.Join(points, " "))
+	}
+
+	writeLegend(&b, names)
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// writeChartFrame writes the SVG header, title, y axis grid and labels
+func writeChartFrame(b *strings.Builder, title, yUnit string, yMax float64) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" font-family="sans-serif">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(b, `<text x="%d" y="20" font-size="14" font-weight="bold">%s</text>`, chartMarginLeft, html.EscapeString(title))
+
+	plotH := float64(chartHeight - chartMarginTop - chartMarginBottom)
+	for i := 0; i <= chartTicks; i++ {
+		value := yMax * float64(i) / chartTicks
+		y := float64(chartMarginTop) + plotH - plotH*float64(i)/chartTicks
+		fmt.Fprintf(b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#ddd"/>`,
+			chartMarginLeft, chartWidth-chartMarginRight, y, y)
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end" font-size="11">%s</text>`,
+			chartMarginLeft-6, y+4, FormatSignificant(value, 3))
+	}
+	fmt.Fprintf(b, `<text x="14" y="%d" font-size="12" transform="rotate(-90 14 %d)" text-anchor="middle">%s</text>`,
+		chartHeight/2, chartHeight/2, html.EscapeString(yUnit))
+}
+
+// writeLegend writes one coloured entry per series name in the top right corner
+func writeLegend(b *strings.Builder, names []string) {
+	for i, name := range names {
+		x := chartWidth - chartMarginRight - 150
+		y := chartMarginTop + 14*i
+		fmt.Fprintf(b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`, x, y, chartPalette[i%len(chartPalette)])
+		fmt.Fprintf(b, `<text x="%d" y="%d" font-size="11">%s</text>`, x+14, y+9, html.EscapeString(name))
+	}
+}
+
+// niceCeiling rounds a positive value up to 1, 2 or 5 times a power of ten
+func niceCeiling(value float64) float64 {
+	if value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if value <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// histogramLine bins samples into the given range and returns a step outline of bin fractions
+func histogramLine(samples []float64, xMax float64, bins int) [][2]float64 {
+	counts := make([]float64, bins)
+	width := xMax / float64(bins)
+	for _, s := range samples {
+		bin := int(s / width)
+		if bin >= bins {
+			bin = bins - 1 // Overflow is folded into the last bin
+		}
+		if bin < 0 {
+			bin = 0
+		}
+		counts[bin]++
+	}
+
+	points := make([][2]float64, 0, 2*bins+2)
+	points = append(points, [2]float64{0, 0})
+	for i, c := range counts {
+		fraction := c / float64(len(samples))
+		points = append(points, [2]float64{width * float64(i), fraction}, [2]float64{width * float64(i+1), fraction})
+	}
+	points = append(points, [2]float64{xMax, 0})
+	return points
+}
+
+// cdfLine returns the empirical cumulative distribution of the samples
+func cdfLine(samples []float64) [][2]float64 {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+
+	points := make([][2]float64, len(sorted)+1)
+	points[0] = [2]float64{0, 0}
+	for i, s := range sorted {
+		points[i+1] = [2]float64{s, float64(i+1) / float64(len(sorted))}
+	}
+	return points
+}
+
+// Percentile returns the p-th percentile (0-100) of the samples by nearest rank
+func Percentile(samples []float64, p float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
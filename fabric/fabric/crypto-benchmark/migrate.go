@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto-benchmark/metrics"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// runMigrate implements the migrate command and returns the process exit code
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	outputDir := fs.String("out", "", "Directory for upgraded files (default: rewrite in place)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: benchmark migrate [flags] <results.json> [results.json...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if *outputDir != "" {
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			log.Fatalf("Failed to create output directory: %v", err)
+		}
+	}
+
+	for _, filename := range fs.Args() {
+		target := filename
+		if *outputDir != "" {
+			target = filepath.Join(*outputDir, filepath.Base(filename))
+		}
+
+		file, err := metrics.MigrateFile(filename, target)
+		if err != nil {
+			log.Fatalf("Failed to migrate %s: %v", filename, err)
+		}
+		fmt.Printf("%s: %s v%d -> v%d (%s)\n", filename, file.Kind, file.OriginalVersion, metrics.SchemaVersion, target)
+	}
+
+	return 0
+}
@@ -0,0 +1,108 @@
+package msp
+
+import (
+	"runtime"
+	"sync"
+)
+
+// BatchItem is one signature to verify in a batch
+type BatchItem struct {
+	PublicKey []byte
+	Message   []byte
+	Signature []byte
+}
+
+// BatchOptions configures BatchVerify
+type BatchOptions struct {
+	// Workers is the number of goroutines verifying in parallel; 0 uses GOMAXPROCS
+	// and 1 verifies sequentially
+	Workers int
+	// NoKeyCache imports the public key of every item separately instead of
+	// once per distinct key, to measure what the cache saves
+	NoKeyCache bool
+}
+
+// BatchVerify verifies many signatures of one algorithm and reports the
+// outcome of each. Neither ECDSA nor ML-DSA has an algebraic batch check, so
+// the speedups are structural: each distinct public key is decoded once and
+// shared by every item signed with it, and items are spread over workers.
+// An item with a malformed key or an invalid signature is reported as false;
+// the error is only set when the batch cannot be verified at all.
+func BatchVerify(algorithm SignatureAlgorithm, items []BatchItem, opts BatchOptions) ([]bool, error) {
+	switch algorithm {
+	case ECDSA, MLDSA44, MLDSA65, MLDSA87, LMSHSS, XMSSMT:
+	default:
+		return nil, newError("batch verify", algorithm, ErrUnsupportedAlgorithm, "")
+	}
+
+	// Decode each distinct key once, before the workers start, so that they
+	// only ever read the verifiers
+	verifiers := make([]*EnhancedMSP, len(items))
+	if !opts.NoKeyCache {
+		cache := make(map[string]*EnhancedMSP)
+		for i, item := range items {
+			verifier, seen := cache[string(item.PublicKey)]
+			if !seen {
+				verifier, _ = NewEnhancedMSPFromPublicKey(algorithm, item.PublicKey)
+				cache[string(item.PublicKey)] = verifier
+			}
+			verifiers[i] = verifier
+		}
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	results := make([]bool, len(items))
+	verifyItem := func(i int) {
+		verifier := verifiers[i]
+		if opts.NoKeyCache {
+			verifier, _ = NewEnhancedMSPFromPublicKey(algorithm, items[i].PublicKey)
+		}
+		if verifier == nil {
+			return
+		}
+		results[i], _ = verifier.Verify(items[i].Message, items[i].Signature)
+	}
+
+	if workers <= 1 {
+		for i := range items {
+			verifyItem(i)
+		}
+		return results, nil
+	}
+
+	// Workers take contiguous chunks, which keeps items of one signer together
+	var wg sync.WaitGroup
+	chunk := (len(items) + workers - 1) / workers
+	for start := 0; start < len(items); start += chunk {
+		end := start + chunk
+		if end > len(items) {
+			end = len(items)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				verifyItem(i)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// AllValid reports whether every result of a batch is true
+func AllValid(results []bool) bool {
+	for _, valid := range results {
+		if !valid {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package msp
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/cloudflare/circl/sign/bls"
+)
+
+// BLSPublicKey is a BLS12-381 public key in G1 (48 bytes), with signatures in
+// G2 (96 bytes), the minimal-public-key-size variant used for aggregation
+type BLSPublicKey = bls.PublicKey[bls.KeyG1SigG2]
+
+// BLSKeyPair is a BLS12-381 key pair. BLS is not a SignatureAlgorithm of the
+// MSP: it is classical, not post-quantum, and is here only as the aggregation
+// baseline that batch verification of ECDSA and ML-DSA is compared against.
+type BLSKeyPair struct {
+	PrivateKey *bls.PrivateKey[bls.KeyG1SigG2]
+	PublicKey  *BLSPublicKey
+}
+
+// NewBLSKeyPair generates a BLS key pair from 32 bytes of fresh key material
+func NewBLSKeyPair() (*BLSKeyPair, error) {
+	ikm := make([]byte, 32)
+	if _, err := rand.Read(ikm); err != nil {
+		return nil, err
+	}
+	privateKey, err := bls.KeyGen[bls.KeyG1SigG2](ikm, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate BLS key pair: %v", err)
+	}
+	return &BLSKeyPair{PrivateKey: privateKey, PublicKey: privateKey.PublicKey()}, nil
+}
+
+// Sign signs a message in the basic scheme, where aggregated messages must be
+// distinct. Fabric endorsements are, since each covers the endorser's identity.
+func (k *BLSKeyPair) Sign(message []byte) []byte {
+	return bls.Sign(k.PrivateKey, message)
+}
+
+// GetPublicKeyBytes returns the compressed public key
+func (k *BLSKeyPair) GetPublicKeyBytes() ([]byte, error) {
+	return k.PublicKey.MarshalBinary()
+}
+
+// ParseBLSPublicKey decodes a compressed public key and checks that it is a
+// valid point of the prime-order subgroup
+func ParseBLSPublicKey(publicKeyBytes []byte) (*BLSPublicKey, error) {
+	publicKey := new(BLSPublicKey)
+	if err := publicKey.UnmarshalBinary(publicKeyBytes); err != nil {
+		return nil, fmt.Errorf("invalid BLS public key: %v", err)
+	}
+	if !publicKey.Validate() {
+		return nil, fmt.Errorf("invalid BLS public key: not in the prime-order subgroup")
+	}
+	return publicKey, nil
+}
+
+// VerifyBLS verifies a single BLS signature
+func VerifyBLS(publicKey *BLSPublicKey, message, signature []byte) bool {
+	return bls.Verify(publicKey, message, signature)
+}
+
+// AggregateBLS combines signatures into one signature of the same size
+func AggregateBLS(signatures [][]byte) ([]byte, error) {
+	sigs := make([]bls.Signature, len(signatures))
+	for i, s := range signatures {
+		sigs[i] = s
+	}
+	return bls.Aggregate(bls.KeyG1SigG2{}, sigs)
+}
+
+// VerifyAggregateBLS verifies an aggregate signature over distinct messages,
+// the i-th signed by the i-th key, with one pairing per message plus one
+func VerifyAggregateBLS(publicKeys []*BLSPublicKey, messages [][]byte, aggregate []byte) bool {
+	return bls.VerifyAggregate(publicKeys, messages, aggregate)
+}
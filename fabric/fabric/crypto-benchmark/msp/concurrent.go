@@ -0,0 +1,136 @@
+package msp
+
+import (
+	"context"
+	"io"
+)
+
+// Signer signs with one key pair of an MSP. It never changes once created: a
+// Rekey or Close of the MSP it was taken from does not affect it, so a gateway
+// can share one Signer between all its goroutines and every signature it makes
+// verifies with its Verifier.
+type Signer struct {
+	msp *EnhancedMSP // Never exposed, so its keys are never swapped
+}
+
+// Verifier verifies signatures with one public key. It never changes once
+// created and can be shared between goroutines.
+type Verifier struct {
+	msp *EnhancedMSP // Never exposed, so its key is never swapped
+}
+
+// Signer returns an immutable signer with the MSP's current key pair. The
+// signer shares the private key, which Close then leaves to it, and has a
+// public key of its own.
+func (msp *EnhancedMSP) Signer() (*Signer, error) {
+	msp.mu.Lock()
+	defer msp.mu.Unlock()
+	if msp.keyPair == nil {
+		return nil, msp.newError("create signer", ErrMissingKey, "MSP has no private key")
+	}
+	publicKey, err := msp.copyPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	msp.keyShared = true
+	snapshot := newEnhancedMSP(msp.algorithm, msp.options())
+	snapshot.setKeys(msp.keyPair, publicKey)
+	snapshot.keyShared = true
+	return &Signer{msp: snapshot}, nil
+}
+
+// Verifier returns an immutable verifier with a copy of the MSP's current
+// public key
+func (msp *EnhancedMSP) Verifier() (*Verifier, error) {
+	msp.mu.RLock()
+	defer msp.mu.RUnlock()
+	if msp.publicKey == nil {
+		return nil, msp.newError("create verifier", ErrMissingKey, "MSP has no public key")
+	}
+	publicKey, err := msp.copyPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &EnhancedMSP{algorithm: msp.algorithm, tracerProvider: msp.tracerProvider}
+	snapshot.setKeys(nil, publicKey)
+	return &Verifier{msp: snapshot}, nil
+}
+
+// copyPublicKey decodes the encoded public key again, so that a snapshot
+// shares no key memory with the MSP. The caller holds msp.mu.
+func (msp *EnhancedMSP) copyPublicKey() (interface{}, error) {
+	publicKeyBytes, err := msp.publicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	return msp.parsePublicKey(publicKeyBytes)
+}
+
+// NewVerifier creates an immutable verifier from an encoded public key
+func NewVerifier(algorithm SignatureAlgorithm, publicKeyBytes []byte) (*Verifier, error) {
+	msp, err := NewEnhancedMSPFromPublicKey(algorithm, publicKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{msp: msp}, nil
+}
+
+// Algorithm returns the signature algorithm of the key pair
+func (s *Signer) Algorithm() SignatureAlgorithm {
+	return s.msp.algorithm
+}
+
+// PublicKeyBytes returns the encoded public key
+func (s *Signer) PublicKeyBytes() ([]byte, error) {
+	return s.msp.GetPublicKeyBytes()
+}
+
+// Sign signs a message as EnhancedMSP.Sign does
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	return s.msp.Sign(message)
+}
+
+// SignWithContextString signs a message bound to a context string as
+// EnhancedMSP.SignWithContextString does
+func (s *Signer) SignWithContextString(ctx context.Context, message, contextString []byte) ([]byte, error) {
+	return s.msp.SignWithContextString(ctx, message, contextString)
+}
+
+// SignReader signs the data read from r as EnhancedMSP.SignReader does
+func (s *Signer) SignReader(ctx context.Context, r io.Reader, contextString []byte) ([]byte, error) {
+	return s.msp.SignReader(ctx, r, contextString)
+}
+
+// Verifier returns a verifier for the signer's public key
+func (s *Signer) Verifier() *Verifier {
+	// The snapshot always has a public key
+	verifier, _ := s.msp.Verifier()
+	return verifier
+}
+
+// Algorithm returns the signature algorithm of the public key
+func (v *Verifier) Algorithm() SignatureAlgorithm {
+	return v.msp.algorithm
+}
+
+// PublicKeyBytes returns the encoded public key
+func (v *Verifier) PublicKeyBytes() ([]byte, error) {
+	return v.msp.GetPublicKeyBytes()
+}
+
+// Verify verifies a signature as EnhancedMSP.Verify does
+func (v *Verifier) Verify(message, signature []byte) (bool, error) {
+	return v.msp.Verify(message, signature)
+}
+
+// VerifyWithContextString verifies a signature made under a context string as
+// EnhancedMSP.VerifyWithContextString does
+func (v *Verifier) VerifyWithContextString(ctx context.Context, message, signature, contextString []byte) (bool, error) {
+	return v.msp.VerifyWithContextString(ctx, message, signature, contextString)
+}
+
+// VerifyReader verifies a signature of the data read from r as
+// EnhancedMSP.VerifyReader does
+func (v *Verifier) VerifyReader(ctx context.Context, r io.Reader, signature, contextString []byte) (bool, error) {
+	return v.msp.VerifyReader(ctx, r, signature, contextString)
+}
@@ -0,0 +1,362 @@
+package msp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tests of the concurrency guarantees of EnhancedMSP, Signer and Verifier.
+// They check results, but mainly exist to run under the race detector:
+//
+//	go test -race ./msp -run Concurrent
+//
+// Stateful algorithms are left out, since every Rekey would build new trees.
+
+// concurrentAlgorithms are the algorithms the concurrency tests run with
+var concurrentAlgorithms = []SignatureAlgorithm{ECDSA, MLDSA44}
+
+const (
+	concurrentGoroutines = 4
+	concurrentIterations = 10
+)
+
+// runConcurrently runs every function from its own goroutines and returns the
+// first error any of them returned
+func runConcurrently(fns ...func(g int) error) error {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, fn := range fns {
+		for g := 0; g < concurrentGoroutines; g++ {
+			wg.Add(1)
+			go func(fn func(int) error, g int) {
+				defer wg.Done()
+				if err := fn(g); err != nil {
+					once.Do(func() { firstErr = err })
+				}
+			}(fn, g)
+		}
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// signAndVerify signs a message with a signer and checks it with a verifier
+func signAndVerify(signer *Signer, verifier *Verifier, message []byte) error {
+	signature, err := signer.Sign(message)
+	if err != nil {
+		return fmt.Errorf("sign: %w", err)
+	}
+	if valid, err := verifier.Verify(message, signature); !valid {
+		return fmt.Errorf("signature of %q does not verify: %w", message, err)
+	}
+	return nil
+}
+
+// TestConcurrentOperations runs signing, verification, snapshots, clones and
+// key swaps of an identity MSP and a verify-only peer MSP at once
+func TestConcurrentOperations(t *testing.T) {
+	for _, algorithm := range concurrentAlgorithms {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			identity, err := NewEnhancedMSP(algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer identity.Close()
+			publicKey, err := identity.GetPublicKeyBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			peer, err := NewEnhancedMSPFromPublicKey(algorithm, publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = runConcurrently(
+				// Signatures through the MSP itself may be made with a key
+				// that a Rekey has since replaced, so only errors count
+				func(g int) error {
+					for i := 0; i < concurrentIterations; i++ {
+						message := []byte(fmt.Sprintf("identity %d/%d", g, i))
+						signature, err := identity.Sign(message)
+						if err != nil {
+							return fmt.Errorf("identity sign: %w", err)
+						}
+						if _, err := identity.Verify(message, signature); err != nil && !errors.Is(err, ErrVerificationFailed) {
+							return fmt.Errorf("identity verify: %w", err)
+						}
+					}
+					return nil
+				},
+				// A Signer and its Verifier always hold the same key pair
+				func(g int) error {
+					for i := 0; i < concurrentIterations; i++ {
+						signer, err := identity.Signer()
+						if err != nil {
+							return err
+						}
+						if err := signAndVerify(signer, signer.Verifier(), []byte(fmt.Sprintf("snapshot %d/%d", g, i))); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+				func(g int) error {
+					for i := 0; i < concurrentIterations; i++ {
+						verifier, err := peer.Verifier()
+						if err != nil {
+							return err
+						}
+						message := []byte(fmt.Sprintf("peer %d/%d", g, i))
+						if _, err := peer.Verify(message, make([]byte, 64)); err == nil {
+							return errors.New("peer accepted a zero signature")
+						}
+						if _, err := verifier.PublicKeyBytes(); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+				func(g int) error {
+					for i := 0; i < concurrentIterations; i++ {
+						clone, err := identity.Clone()
+						if err != nil {
+							return fmt.Errorf("clone: %w", err)
+						}
+						message := []byte(fmt.Sprintf("clone %d/%d", g, i))
+						signature, err := clone.Sign(message)
+						if err == nil {
+							var valid bool
+							if valid, err = clone.Verify(message, signature); !valid {
+								err = fmt.Errorf("clone's signature does not verify: %w", err)
+							}
+						}
+						clone.Close()
+						if err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+				// The identity rekeys and the peer follows its new public key
+				func(g int) error {
+					for i := 0; i < concurrentIterations/2; i++ {
+						if err := identity.Rekey(); err != nil {
+							return fmt.Errorf("rekey: %w", err)
+						}
+						publicKey, err := identity.GetPublicKeyBytes()
+						if err != nil {
+							return err
+						}
+						if err := peer.SetPublicKey(publicKey); err != nil {
+							return fmt.Errorf("set public key: %w", err)
+						}
+					}
+					return nil
+				},
+				func(g int) error {
+					for i := 0; i < concurrentIterations; i++ {
+						identity.SetTracerProvider(trace.NewNoopTracerProvider())
+						peer.SetTracerProvider(nil)
+					}
+					return nil
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Once the swaps are over the peer holds the identity's last key
+			signer, err := identity.Signer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			publicKey, err = identity.GetPublicKeyBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := peer.SetPublicKey(publicKey); err != nil {
+				t.Fatal(err)
+			}
+			verifier, err := peer.Verifier()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := signAndVerify(signer, verifier, []byte("after the swaps")); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestConcurrentCloseAndVerifier closes an MSP while Verifiers taken from it
+// and the MSP itself verify. Neither may see the wiped private key: an ML-DSA
+// public key decoded from the private key shares its matrix A.
+func TestConcurrentCloseAndVerifier(t *testing.T) {
+	for _, algorithm := range concurrentAlgorithms {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			m, err := NewEnhancedMSP(algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			message := []byte("signed before Close")
+			signature, err := m.Sign(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifier, err := m.Verifier()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verify := func(g int) error {
+				for i := 0; i < concurrentIterations; i++ {
+					if valid, err := verifier.Verify(message, signature); !valid {
+						return fmt.Errorf("verifier: %w", err)
+					}
+					if valid, err := m.Verify(message, signature); !valid {
+						return fmt.Errorf("MSP: %w", err)
+					}
+				}
+				return nil
+			}
+			closeMSP := func(g int) error { return m.Close() }
+			if err := runConcurrently(verify, closeMSP); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := m.Sign(message); !errors.Is(err, ErrMissingKey) {
+				t.Errorf("closed MSP signed: %v", err)
+			}
+			if _, err := m.GetPrivateKeyBytes(); err == nil {
+				t.Error("closed MSP returned its private key")
+			}
+			after, err := m.Verifier()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid, err := after.Verify(message, signature); !valid {
+				t.Errorf("verifier taken after Close rejected the signature: %v", err)
+			}
+		})
+	}
+}
+
+// TestConcurrentCloseAndSigner closes an MSP while Signers taken from it sign.
+// Close must leave the key they share intact.
+func TestConcurrentCloseAndSigner(t *testing.T) {
+	for _, algorithm := range concurrentAlgorithms {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			m, err := NewEnhancedMSP(algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer, err := m.Signer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifier := signer.Verifier()
+
+			sign := func(g int) error {
+				for i := 0; i < concurrentIterations; i++ {
+					if err := signAndVerify(signer, verifier, []byte(fmt.Sprintf("signer %d/%d", g, i))); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			closeMSP := func(g int) error { return m.Close() }
+			if err := runConcurrently(sign, closeMSP); err != nil {
+				t.Fatal(err)
+			}
+			if err := signAndVerify(signer, verifier, []byte("after Close")); err != nil {
+				t.Fatalf("signer stopped working once its MSP was closed: %v", err)
+			}
+			if _, err := m.Signer(); !errors.Is(err, ErrMissingKey) {
+				t.Errorf("closed MSP gave a signer: %v", err)
+			}
+		})
+	}
+}
+
+// TestCloseWipesUnsharedKey checks that Close wipes a private key no Signer
+// holds, and that the MSP's own public key is not affected
+func TestCloseWipesUnsharedKey(t *testing.T) {
+	m, err := NewEnhancedMSP(MLDSA44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.RLock()
+	keyPair := m.keyPair.(*WorkingMLDSAKeyPair)
+	m.mu.RUnlock()
+	message := []byte("signed before Close")
+	signature, err := m.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if keyPair.PrivateKey != nil {
+		t.Error("Close left the private key in place")
+	}
+	if valid, err := m.Verify(message, signature); !valid {
+		t.Errorf("closed MSP rejected its own signature: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+// TestRekeyWipesUnsharedKey checks that Rekey wipes the old private key when
+// no Signer holds it, and leaves it to a Signer that does
+func TestRekeyWipesUnsharedKey(t *testing.T) {
+	m, err := NewEnhancedMSP(MLDSA44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	currentKeyPair := func() *WorkingMLDSAKeyPair {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return m.keyPair.(*WorkingMLDSAKeyPair)
+	}
+
+	unshared := currentKeyPair()
+	if err := m.Rekey(); err != nil {
+		t.Fatal(err)
+	}
+	if unshared.PrivateKey != nil {
+		t.Error("Rekey left the unshared private key in place")
+	}
+
+	shared := currentKeyPair()
+	signer, err := m.Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Rekey(); err != nil {
+		t.Fatal(err)
+	}
+	if shared.PrivateKey == nil {
+		t.Fatal("Rekey wiped a private key a Signer holds")
+	}
+	if err := signAndVerify(signer, signer.Verifier(), []byte("after Rekey")); err != nil {
+		t.Errorf("signer stopped working once its MSP was rekeyed: %v", err)
+	}
+	message := []byte("with the new key")
+	signature, err := m.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, err := m.Verify(message, signature); !valid {
+		t.Errorf("rekeyed MSP rejected its own signature: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package msp
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+)
+
+// SeedSize is the size of a key generation seed: the ML-DSA seed ξ of FIPS 204,
+// and the HKDF input for ECDSA, LMS/HSS and XMSS^MT
+const SeedSize = 32
+
+// MinMasterSeedSize is the shortest master seed accepted by NewSeedSequence
+const MinMasterSeedSize = 16
+
+// ecdsaSeedInfo separates ECDSA private key derivation from other HKDF uses
+const ecdsaSeedInfo = "crypto-benchmark ECDSA P-256 private key"
+
+// SigningMode selects between randomised and deterministic signatures
+type SigningMode int
+
+const (
+	// SigningHedged mixes fresh randomness into every signature: hedged ML-DSA
+	// as FIPS 204 recommends, and randomised-nonce ECDSA
+	SigningHedged SigningMode = iota
+	// SigningDeterministic produces the same signature for the same key and
+	// message: ML-DSA with an all-zero rnd, and RFC 6979 ECDSA
+	SigningDeterministic
+)
+
+// String returns the name of the signing mode
+func (m SigningMode) String() string {
+	switch m {
+	case SigningHedged:
+		return "hedged"
+	case SigningDeterministic:
+		return "deterministic"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseSigningMode returns the signing mode with the given name
+func ParseSigningMode(name string) (SigningMode, error) {
+	for _, m := range []SigningMode{SigningHedged, SigningDeterministic} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown signing mode: %s", name)
+}
+
+// SeedSequence derives a reproducible stream of key generation seeds from a
+// master seed, so that every key of a run can be regenerated. The n-th seed
+// for a label is HKDF-Expand(HKDF-Extract(master), "crypto-benchmark/<label>/<n>").
+type SeedSequence struct {
+	mu       sync.Mutex
+	prk      []byte
+	counters map[string]uint64
+}
+
+// NewSeedSequence creates a sequence from a master seed
+func NewSeedSequence(master []byte) (*SeedSequence, error) {
+	if len(master) < MinMasterSeedSize {
+		return nil, fmt.Errorf("master seed is %d bytes, need at least %d", len(master), MinMasterSeedSize)
+	}
+	prk, err := hkdf.Extract(sha256.New, master, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SeedSequence{prk: prk, counters: make(map[string]uint64)}, nil
+}
+
+// Next returns the next seed for a label. Each label counts separately, so
+// adding keys of one algorithm does not change the keys of another.
+func (s *SeedSequence) Next(label string) ([]byte, error) {
+	s.mu.Lock()
+	n := s.counters[label]
+	s.counters[label] = n + 1
+	s.mu.Unlock()
+
+	info := "crypto-benchmark/" + label + "/" + strconv.FormatUint(n, 10)
+	return hkdf.Expand(sha256.New, s.prk, info, SeedSize)
+}
+
+// NewEnhancedMSPFromSeed creates an MSP whose key pair is derived from a
+// 32-byte seed. For ML-DSA the seed is ξ of FIPS 204 ML-DSA.KeyGen_internal,
+// so the keys match any conforming implementation; for ECDSA the private
+// scalar is derived with HKDF-SHA256 as described in ecdsaKeyFromSeed.
+func NewEnhancedMSPFromSeed(algorithm SignatureAlgorithm, seed []byte, signing SigningMode) (*EnhancedMSP, error) {
+	msp := &EnhancedMSP{algorithm: algorithm, signing: signing}
+	if err := msp.generateKeyPairFromSeed(seed); err != nil {
+		return nil, fmt.Errorf("failed to derive key pair: %w", err)
+	}
+	return msp, nil
+}
+
+// generateKeyPairFromSeed derives the key pair of the selected algorithm from a seed
+func (msp *EnhancedMSP) generateKeyPairFromSeed(seed []byte) error {
+	if len(seed) != SeedSize {
+		return msp.newError("derive key pair", ErrMalformedKey, "seed is %d bytes, expected %d", len(seed), SeedSize)
+	}
+
+	switch msp.algorithm {
+	case ECDSA:
+		key, err := ecdsaKeyFromSeed(seed)
+		if err != nil {
+			return err
+		}
+		msp.setKeys(key, &key.PublicKey)
+		return nil
+	case MLDSA44, MLDSA65, MLDSA87:
+		keyPair, err := NewWorkingMLDSAKeyPairFromSeed(msp.algorithm.mldsaSecurityLevel(), seed)
+		if err != nil {
+			return err
+		}
+		keyPair.Hedged = msp.signing == SigningHedged
+		return msp.setMLDSAKeys(keyPair)
+	case LMSHSS, XMSSMT:
+		return msp.generateStatefulKeyPair(seed)
+	default:
+		return msp.newError("derive key pair", ErrUnsupportedAlgorithm, "")
+	}
+}
+
+// ecdsaKeyFromSeed derives a P-256 private key from a seed. Forty bytes of
+// HKDF-SHA256 output are reduced to d = v mod (n-1) + 1, the extra-random-bits
+// method of FIPS 186-5 A.2.1, which keeps the bias below 2^-64.
+func ecdsaKeyFromSeed(seed []byte) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	size := (curve.Params().BitSize + 7) / 8
+
+	v, err := hkdf.Key(sha256.New, seed, nil, ecdsaSeedInfo, size+8)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(v)
+
+	nMinusOne := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	d := new(big.Int).SetBytes(v)
+	d.Mod(d, nMinusOne)
+	d.Add(d, big.NewInt(1))
+
+	// ecdh computes the public point in constant time
+	scalar := d.FillBytes(make([]byte, size))
+	defer clear(scalar)
+	ecdhKey, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, err
+	}
+	point := ecdhKey.PublicKey().Bytes()
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(point[1 : 1+size]),
+			Y:     new(big.Int).SetBytes(point[1+size:]),
+		},
+		D: d,
+	}, nil
+}
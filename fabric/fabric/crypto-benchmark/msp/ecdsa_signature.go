@@ -0,0 +1,57 @@
+package msp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// ecdsaSignature is the ASN.1 encoding of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// marshalECDSASignature encodes r and s as an ASN.1 signature
+func marshalECDSASignature(r, s *big.Int) ([]byte, error) {
+	return asn1.Marshal(ecdsaSignature{R: r, S: s})
+}
+
+// unmarshalECDSASignature decodes an ASN.1 signature, rejecting trailing data
+// and non-positive values
+func unmarshalECDSASignature(raw []byte) (*big.Int, *big.Int, error) {
+	sig := new(ecdsaSignature)
+	rest, err := asn1.Unmarshal(raw, sig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal ECDSA signature: %v", err)
+	}
+	if len(rest) != 0 {
+		return nil, nil, fmt.Errorf("trailing data after ECDSA signature")
+	}
+	if sig.R == nil || sig.S == nil || sig.R.Sign() <= 0 || sig.S.Sign() <= 0 {
+		return nil, nil, fmt.Errorf("invalid ECDSA signature: r and s must be positive")
+	}
+	return sig.R, sig.S, nil
+}
+
+// isLowS reports whether s is at most half the curve order. Fabric rejects
+// high-S signatures because (r, n-s) is a second valid signature for the same
+// message, which would make transaction signatures malleable.
+func isLowS(curve elliptic.Curve, s *big.Int) bool {
+	halfOrder := new(big.Int).Rsh(curve.Params().N, 1)
+	return s.Cmp(halfOrder) <= 0
+}
+
+// toLowS re-encodes an ASN.1 signature with s replaced by n-s when s is high
+func toLowS(key *ecdsa.PublicKey, signature []byte) ([]byte, error) {
+	r, s, err := unmarshalECDSASignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	if isLowS(key.Curve, s) {
+		return signature, nil
+	}
+	s = new(big.Int).Sub(key.Params().N, s)
+	return marshalECDSASignature(r, s)
+}
@@ -1,13 +1,22 @@
 package msp
 
 import (
+	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
+
+	"crypto-benchmark/hbs"
+
+	"github.com/cloudflare/circl/sign"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SignatureAlgorithm represents the supported signature algorithms
@@ -18,6 +27,8 @@ const (
 	MLDSA44
 	MLDSA65
 	MLDSA87
+	LMSHSS
+	XMSSMT
 )
 
 // String returns the string representation of the signature algorithm
@@ -31,11 +42,60 @@ func (sa SignatureAlgorithm) String() string {
 		return "ML-DSA-65"
 	case MLDSA87:
 		return "ML-DSA-87"
+	case LMSHSS:
+		return "LMS-HSS"
+	case XMSSMT:
+		return "XMSS-MT"
 	default:
 		return "Unknown"
 	}
 }
 
+// NISTSecurityCategory returns the NIST PQC security category of the algorithm,
+// or 0 for classical algorithms that offer no quantum resistance
+func (sa SignatureAlgorithm) NISTSecurityCategory() int {
+	switch sa {
+	case MLDSA44:
+		return 2
+	case MLDSA65:
+		return 3
+	case MLDSA87, LMSHSS, XMSSMT:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// SupportsContextString reports whether signatures can be bound to a FIPS 204
+// context string, which only ML-DSA takes as an input
+func (sa SignatureAlgorithm) SupportsContextString() bool {
+	return sa.mldsaSecurityLevel() != 0
+}
+
+// mldsaSecurityLevel returns the ML-DSA parameter set number, or 0 for other algorithms
+func (sa SignatureAlgorithm) mldsaSecurityLevel() int {
+	switch sa {
+	case MLDSA44:
+		return 44
+	case MLDSA65:
+		return 65
+	case MLDSA87:
+		return 87
+	default:
+		return 0
+	}
+}
+
+// ParseSignatureAlgorithm returns the algorithm with the given string representation
+func ParseSignatureAlgorithm(name string) (SignatureAlgorithm, error) {
+	for _, sa := range []SignatureAlgorithm{ECDSA, MLDSA44, MLDSA65, MLDSA87, LMSHSS, XMSSMT} {
+		if sa.String() == name {
+			return sa, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, name)
+}
+
 // CryptoMetrics holds the performance metrics for cryptographic operations
 type CryptoMetrics struct {
 	Algorithm       string  `json:"algorithm"`
@@ -46,42 +106,150 @@ type CryptoMetrics struct {
 	PrivateKeyBytes int     `json:"private_key_bytes"`
 	SignatureBytes  int     `json:"signature_bytes"`
 	Timestamp       string  `json:"timestamp"`
+
+	// Raw per-iteration timings, kept for significance testing between runs
+	KeygenSamplesMs []float64 `json:"keygen_samples_ms,omitempty"`
+	SignSamplesMs   []float64 `json:"sign_samples_ms,omitempty"`
+	VerifySamplesMs []float64 `json:"verify_samples_ms,omitempty"`
+
+	// Stateful hash-based algorithms only: the signatures one key can make and
+	// the durable state update cost, averaged over every signature
+	MaxSignatures     uint64  `json:"max_signatures,omitempty"`
+	StateUpdateTimeMs float64 `json:"state_update_time_ms,omitempty"`
+
+	// Where the private keys were held when not in process memory, such as
+	// a PKCS#11 token
+	KeyProvider string `json:"key_provider,omitempty"`
 }
 
-// EnhancedMSP provides support for both ECDSA and ML-DSA signature algorithms
+// EnhancedMSP provides support for ECDSA, ML-DSA and stateful hash-based
+// signature algorithms. It is safe for concurrent use: signing, verification
+// and key swaps with Rekey or SetPublicKey may run from any number of
+// goroutines, and each operation sees either the old keys or the new ones.
+// Signer and Verifier give immutable views of the current keys.
 type EnhancedMSP struct {
 	algorithm SignatureAlgorithm
+
+	mu        sync.RWMutex // Guards keyPair, publicKey, keyShared and tracerProvider
 	keyPair   interface{}
 	publicKey interface{}
+	keyShared bool // A Signer holds keyPair too, so Close must not wipe it
+
+	tracerProvider trace.TracerProvider
+	signing        SigningMode
+	seeds          *SeedSequence
+	stateStore     hbs.StateStore
+	stateReserve   uint64
+	keyProvider    KeyProvider
+}
+
+// Options configures how an MSP generates keys and signs
+type Options struct {
+	// Seeds derives keys from a seed sequence instead of crypto/rand, so that
+	// the keys of a run can be regenerated. Nil uses fresh randomness.
+	Seeds *SeedSequence
+	// Signing selects hedged or deterministic signatures
+	Signing SigningMode
+	// TracerProvider records spans, nil uses the global OpenTelemetry provider
+	TracerProvider trace.TracerProvider
+	// StateStore records the used one-time keys of LMS/HSS and XMSS^MT keys.
+	// Nil keeps the state in memory, which is only safe for throwaway keys.
+	StateStore hbs.StateStore
+	// StateReserve is how many one-time keys a stateful key reserves per state
+	// update; 0 or 1 updates the state before every signature
+	StateReserve uint64
+	// KeyProvider generates keys outside the process, such as in an HSM. Nil
+	// generates software keys.
+	KeyProvider KeyProvider
 }
 
 // NewEnhancedMSP creates a new MSP instance with the specified algorithm
 func NewEnhancedMSP(algorithm SignatureAlgorithm) (*EnhancedMSP, error) {
-	msp := &EnhancedMSP{
-		algorithm: algorithm,
-	}
+	return NewEnhancedMSPContext(context.Background(), algorithm, nil)
+}
+
+// NewEnhancedMSPContext creates a new MSP instance, recording key generation as a
+// span under ctx. A nil provider uses the global OpenTelemetry tracer provider.
+func NewEnhancedMSPContext(ctx context.Context, algorithm SignatureAlgorithm, provider trace.TracerProvider) (*EnhancedMSP, error) {
+	return NewEnhancedMSPWithOptions(ctx, algorithm, Options{TracerProvider: provider})
+}
 
+// NewEnhancedMSPWithOptions creates a new MSP instance configured by opts,
+// recording key generation as a span under ctx
+func NewEnhancedMSPWithOptions(ctx context.Context, algorithm SignatureAlgorithm, opts Options) (*EnhancedMSP, error) {
+	msp := newEnhancedMSP(algorithm, opts)
+
+	_, span := startSpan(ctx, msp.tracer(), SpanKeyGen, algorithm)
 	err := msp.generateKeyPair()
+	endSpan(span, "ok", err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate key pair: %v", err)
+		return nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
+
+	return msp, nil
+}
+
+// newEnhancedMSP creates an MSP without keys, configured by opts
+func newEnhancedMSP(algorithm SignatureAlgorithm, opts Options) *EnhancedMSP {
+	return &EnhancedMSP{
+		algorithm:      algorithm,
+		tracerProvider: opts.TracerProvider,
+		signing:        opts.Signing,
+		seeds:          opts.Seeds,
+		stateStore:     opts.StateStore,
+		stateReserve:   opts.StateReserve,
+		keyProvider:    opts.KeyProvider,
+	}
+}
+
+// NewEnhancedMSPFromPublicKey creates a verify-only MSP from an encoded public key,
+// as a peer does for a certificate received from another organisation. Signing
+// with the returned MSP fails because it holds no private key.
+func NewEnhancedMSPFromPublicKey(algorithm SignatureAlgorithm, publicKeyBytes []byte) (*EnhancedMSP, error) {
+	msp := &EnhancedMSP{algorithm: algorithm}
+	if err := msp.setPublicKeyFromBytes(publicKeyBytes); err != nil {
+		return nil, fmt.Errorf("failed to import public key: %w", err)
 	}
+	return msp, nil
+}
 
+// NewEnhancedMSPFromPrivateKey creates an MSP from a private key encoded as
+// GetPrivateKeyBytes returns it, deriving the public key from it. A stateful
+// key resumes from the state recorded in opts.StateStore. The MSP keeps no
+// reference to privateKeyBytes, which the caller should clear after use.
+func NewEnhancedMSPFromPrivateKey(algorithm SignatureAlgorithm, privateKeyBytes []byte, opts Options) (*EnhancedMSP, error) {
+	msp := newEnhancedMSP(algorithm, opts)
+	if err := msp.setPrivateKeyFromBytes(privateKeyBytes); err != nil {
+		return nil, fmt.Errorf("failed to import private key: %w", err)
+	}
 	return msp, nil
 }
 
-// generateKeyPair generates a key pair based on the selected algorithm
+// generateKeyPair generates a key pair based on the selected algorithm, from the
+// next seed of the MSP's seed sequence if it has one
 func (msp *EnhancedMSP) generateKeyPair() error {
+	if msp.keyProvider != nil {
+		return msp.generateExternalKeyPair()
+	}
+	if msp.seeds != nil {
+		seed, err := msp.seeds.Next(msp.algorithm.String())
+		if err != nil {
+			return err
+		}
+		// Every key type keeps its own copy of what it needs from the seed
+		defer clear(seed)
+		return msp.generateKeyPairFromSeed(seed)
+	}
+
 	switch msp.algorithm {
 	case ECDSA:
 		return msp.generateECDSAKeyPair()
-	case MLDSA44:
-		return msp.generateMLDSAKeyPair(44)
-	case MLDSA65:
-		return msp.generateMLDSAKeyPair(65)
-	case MLDSA87:
-		return msp.generateMLDSAKeyPair(87)
+	case MLDSA44, MLDSA65, MLDSA87:
+		return msp.generateMLDSAKeyPair(msp.algorithm.mldsaSecurityLevel())
+	case LMSHSS, XMSSMT:
+		return msp.generateStatefulKeyPair(nil)
 	default:
-		return fmt.Errorf("unsupported algorithm: %v", msp.algorithm)
+		return msp.newError("generate key pair", ErrUnsupportedAlgorithm, "")
 	}
 }
 
@@ -92,8 +260,7 @@ func (msp *EnhancedMSP) generateECDSAKeyPair() error {
 		return err
 	}
 
-	msp.keyPair = key
-	msp.publicKey = &key.PublicKey
+	msp.setKeys(key, &key.PublicKey)
 	return nil
 }
 
@@ -102,125 +269,349 @@ func (msp *EnhancedMSP) generateMLDSAKeyPair(securityLevel int) error {
 	// Use real ML-DSA implementation with Cloudflare CIRCL
 	keyPair, err := NewWorkingMLDSAKeyPair(securityLevel)
 	if err != nil {
-		return fmt.Errorf("failed to generate real ML-DSA key pair: %v", err)
+		return fmt.Errorf("failed to generate real ML-DSA key pair: %w", err)
 	}
+	keyPair.Hedged = msp.signing == SigningHedged
 
-	msp.keyPair = keyPair
-	msp.publicKey = keyPair
+	return msp.setMLDSAKeys(keyPair)
+}
+
+// setMLDSAKeys publishes an ML-DSA key pair with a public key of its own, so
+// that verification never shares memory with the private key that Close wipes
+func (msp *EnhancedMSP) setMLDSAKeys(keyPair *WorkingMLDSAKeyPair) error {
+	publicKey, err := keyPair.verifyOnly()
+	if err != nil {
+		return fmt.Errorf("failed to decode ML-DSA public key: %v", err)
+	}
+	msp.setKeys(keyPair, publicKey)
 	return nil
 }
 
 // Sign signs a message using the configured algorithm
 func (msp *EnhancedMSP) Sign(message []byte) ([]byte, error) {
-	hasher := sha256.New()
-	hasher.Write(message)
-	hash := hasher.Sum(nil)
+	return msp.SignContext(context.Background(), message)
+}
+
+// SignContext signs a message and records the operation as a span under ctx
+func (msp *EnhancedMSP) SignContext(ctx context.Context, message []byte) ([]byte, error) {
+	return msp.SignWithContextString(ctx, message, nil)
+}
+
+// SignWithContextString signs a message bound to a FIPS 204 context string, so
+// that the signature only verifies under the same context. See Domains for the
+// recommended context of each Fabric message type. ECDSA has no context input
+// and accepts only an empty context string.
+func (msp *EnhancedMSP) SignWithContextString(ctx context.Context, message, contextString []byte) ([]byte, error) {
+	_, span := startSpan(ctx, msp.tracer(), SpanSign, msp.algorithm, contextAttributes(message, contextString)...)
+	signature, err := msp.sign(message, contextString)
+	span.SetAttributes(AttrSignatureSize.Int(len(signature)))
+	endSpan(span, "ok", err)
+	return signature, err
+}
+
+// sign hashes and signs a message using the configured algorithm
+func (msp *EnhancedMSP) sign(message, contextString []byte) ([]byte, error) {
+	if err := msp.checkContextString("sign", contextString); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(message)
+	return msp.signHash(hash[:], contextString)
+}
+
+// signHash signs the SHA-256 hash of a message under a checked context string
+func (msp *EnhancedMSP) signHash(hash, contextString []byte) ([]byte, error) {
+	msp.mu.RLock()
+	defer msp.mu.RUnlock()
+	if signer, ok := msp.keyPair.(KeySigner); ok {
+		return msp.signExternal(signer, hash, contextString)
+	}
 
 	switch msp.algorithm {
 	case ECDSA:
 		return msp.signECDSA(hash)
 	case MLDSA44, MLDSA65, MLDSA87:
-		return msp.signMLDSA(hash)
+		return msp.signMLDSA(hash, contextString)
+	case LMSHSS, XMSSMT:
+		return msp.signStateful(hash)
 	default:
-		return nil, fmt.Errorf("unsupported algorithm for signing: %v", msp.algorithm)
+		return nil, msp.newError("sign", ErrUnsupportedAlgorithm, "")
 	}
 }
 
-// signECDSA signs a hash using ECDSA
+// signECDSA signs a hash using ECDSA, normalising to low-S as Fabric requires.
+// Deterministic signing derives the nonce as RFC 6979 specifies.
 func (msp *EnhancedMSP) signECDSA(hash []byte) ([]byte, error) {
-	key := msp.keyPair.(*ecdsa.PrivateKey)
-	signature, err := ecdsa.SignASN1(rand.Reader, key, hash)
+	key, ok := msp.keyPair.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, msp.keyError("sign", msp.keyPair)
+	}
+	var signature []byte
+	var err error
+	if msp.signing == SigningDeterministic {
+		// A nil random source selects RFC 6979 nonces
+		signature, err = key.Sign(nil, hash, crypto.SHA256)
+	} else {
+		signature, err = ecdsa.SignASN1(rand.Reader, key, hash)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return signature, nil
+	return toLowS(&key.PublicKey, signature)
 }
 
-// signMLDSA signs a hash using real ML-DSA
-func (msp *EnhancedMSP) signMLDSA(hash []byte) ([]byte, error) {
-	keyPair := msp.keyPair.(*WorkingMLDSAKeyPair)
-	signature := keyPair.Sign(hash)
-	return signature, nil
+// signMLDSA signs a hash using real ML-DSA under a context string
+func (msp *EnhancedMSP) signMLDSA(hash, contextString []byte) ([]byte, error) {
+	keyPair, ok := msp.keyPair.(*WorkingMLDSAKeyPair)
+	if !ok {
+		return nil, msp.keyError("sign", msp.keyPair)
+	}
+	if keyPair.PrivateKey == nil {
+		return nil, msp.newError("sign", ErrMissingKey, "")
+	}
+	return keyPair.SignWithContext(hash, contextString)
 }
 
-// Verify verifies a signature using the configured algorithm
+// Verify verifies a signature using the configured algorithm. A signature that
+// does not verify returns false with an error matching ErrVerificationFailed,
+// or ErrMalformedSignature if it does not even decode; errors.Is tells them
+// apart from key and algorithm errors.
 func (msp *EnhancedMSP) Verify(message, signature []byte) (bool, error) {
-	hasher := sha256.New()
-	hasher.Write(message)
-	hash := hasher.Sum(nil)
+	return msp.VerifyContext(context.Background(), message, signature)
+}
 
+// VerifyContext verifies a signature and records the operation as a span under ctx
+func (msp *EnhancedMSP) VerifyContext(ctx context.Context, message, signature []byte) (bool, error) {
+	return msp.VerifyWithContextString(ctx, message, signature, nil)
+}
+
+// VerifyWithContextString verifies a signature made with SignWithContextString
+// under the same context string
+func (msp *EnhancedMSP) VerifyWithContextString(ctx context.Context, message, signature, contextString []byte) (bool, error) {
+	attrs := append(contextAttributes(message, contextString), AttrSignatureSize.Int(len(signature)))
+	_, span := startSpan(ctx, msp.tracer(), SpanVerify, msp.algorithm, attrs...)
+	valid, err := msp.verify(message, signature, contextString)
+	result, spanErr := "valid", err
+	if errors.Is(err, ErrVerificationFailed) || errors.Is(err, ErrMalformedSignature) {
+		// A bad signature is an outcome of verification, not a failure of it
+		result, spanErr = "invalid", nil
+	}
+	endSpan(span, result, spanErr)
+	return valid, err
+}
+
+// verify hashes the message and verifies a signature using the configured algorithm
+func (msp *EnhancedMSP) verify(message, signature, contextString []byte) (bool, error) {
+	if err := msp.checkContextString("verify", contextString); err != nil {
+		return false, err
+	}
+
+	hash := sha256.Sum256(message)
+	return msp.verifyHash(hash[:], signature, contextString)
+}
+
+// verifyHash verifies a signature of the SHA-256 hash of a message under a
+// checked context string
+func (msp *EnhancedMSP) verifyHash(hash, signature, contextString []byte) (bool, error) {
+	msp.mu.RLock()
+	defer msp.mu.RUnlock()
 	switch msp.algorithm {
 	case ECDSA:
 		return msp.verifyECDSA(hash, signature)
 	case MLDSA44, MLDSA65, MLDSA87:
-		return msp.verifyMLDSA(hash, signature)
+		return msp.verifyMLDSA(hash, signature, contextString)
+	case LMSHSS, XMSSMT:
+		return msp.verifyStateful(hash, signature)
 	default:
-		return false, fmt.Errorf("unsupported algorithm for verification: %v", msp.algorithm)
+		return false, msp.newError("verify", ErrUnsupportedAlgorithm, "")
 	}
 }
 
-// verifyECDSA verifies an ECDSA signature
+// verifyECDSA verifies an ECDSA signature, rejecting malformed and high-S encodings
 func (msp *EnhancedMSP) verifyECDSA(hash, signature []byte) (bool, error) {
-	publicKey := msp.publicKey.(*ecdsa.PublicKey)
-	valid := ecdsa.VerifyASN1(publicKey, hash, signature)
-	return valid, nil
+	publicKey, ok := msp.publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return false, msp.keyError("verify", msp.publicKey)
+	}
+
+	r, s, err := unmarshalECDSASignature(signature)
+	if err != nil {
+		return false, msp.newError("verify", ErrMalformedSignature, "%v", err)
+	}
+	if !isLowS(publicKey.Curve, s) {
+		return false, msp.newError("verify", ErrMalformedSignature, "high-S signature")
+	}
+	if !ecdsa.Verify(publicKey, hash, r, s) {
+		return false, msp.newError("verify", ErrVerificationFailed, "")
+	}
+	return true, nil
 }
 
-// verifyMLDSA verifies a real ML-DSA signature
-func (msp *EnhancedMSP) verifyMLDSA(hash, signature []byte) (bool, error) {
-	keyPair := msp.keyPair.(*WorkingMLDSAKeyPair)
-	valid := keyPair.Verify(hash, signature)
-	return valid, nil
+// verifyMLDSA verifies a real ML-DSA signature under a context string
+func (msp *EnhancedMSP) verifyMLDSA(hash, signature, contextString []byte) (bool, error) {
+	keyPair, ok := msp.publicKey.(*WorkingMLDSAKeyPair)
+	if !ok || keyPair.PublicKey == nil {
+		return false, msp.keyError("verify", msp.publicKey)
+	}
+	if len(signature) != keyPair.GetSignatureSize() {
+		return false, msp.newError("verify", ErrMalformedSignature, "signature is %d bytes, expected %d", len(signature), keyPair.GetSignatureSize())
+	}
+	if !keyPair.VerifyWithContext(hash, signature, contextString) {
+		return false, msp.newError("verify", ErrVerificationFailed, "")
+	}
+	return true, nil
 }
 
 // GetPublicKeyBytes returns the public key as bytes
 func (msp *EnhancedMSP) GetPublicKeyBytes() ([]byte, error) {
+	msp.mu.RLock()
+	defer msp.mu.RUnlock()
+	return msp.publicKeyBytes()
+}
+
+// publicKeyBytes encodes the public key; the caller holds msp.mu
+func (msp *EnhancedMSP) publicKeyBytes() ([]byte, error) {
 	switch msp.algorithm {
 	case ECDSA:
 		return msp.getECDSAPublicKeyBytes()
 	case MLDSA44, MLDSA65, MLDSA87:
 		return msp.getMLDSAPublicKeyBytes()
+	case LMSHSS, XMSSMT:
+		return msp.getStatefulPublicKeyBytes()
 	default:
-		return nil, fmt.Errorf("unsupported algorithm for public key extraction: %v", msp.algorithm)
+		return nil, msp.newError("encode public key", ErrUnsupportedAlgorithm, "")
 	}
 }
 
 // getECDSAPublicKeyBytes returns ECDSA public key as bytes
 func (msp *EnhancedMSP) getECDSAPublicKeyBytes() ([]byte, error) {
-	publicKey := msp.publicKey.(*ecdsa.PublicKey)
+	publicKey, ok := msp.publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, msp.keyError("encode public key", msp.publicKey)
+	}
 	return x509.MarshalPKIXPublicKey(publicKey)
 }
 
 // getMLDSAPublicKeyBytes returns real ML-DSA public key as bytes
 func (msp *EnhancedMSP) getMLDSAPublicKeyBytes() ([]byte, error) {
-	keyPair := msp.keyPair.(*WorkingMLDSAKeyPair)
+	keyPair, ok := msp.publicKey.(*WorkingMLDSAKeyPair)
+	if !ok || keyPair.PublicKey == nil {
+		return nil, msp.keyError("encode public key", msp.publicKey)
+	}
 	return keyPair.GetPublicKeyBytes(), nil
 }
 
 // GetPrivateKeyBytes returns the private key as bytes
 func (msp *EnhancedMSP) GetPrivateKeyBytes() ([]byte, error) {
+	msp.mu.RLock()
+	defer msp.mu.RUnlock()
+	return msp.privateKeyBytes()
+}
+
+// privateKeyBytes encodes the private key; the caller holds msp.mu
+func (msp *EnhancedMSP) privateKeyBytes() ([]byte, error) {
+	if _, ok := msp.keyPair.(KeySigner); ok {
+		return nil, msp.newError("encode private key", ErrKeyNotExtractable, "")
+	}
 	switch msp.algorithm {
 	case ECDSA:
 		return msp.getECDSAPrivateKeyBytes()
 	case MLDSA44, MLDSA65, MLDSA87:
 		return msp.getMLDSAPrivateKeyBytes()
+	case LMSHSS, XMSSMT:
+		return msp.getStatefulPrivateKeyBytes()
 	default:
-		return nil, fmt.Errorf("unsupported algorithm for private key extraction: %v", msp.algorithm)
+		return nil, msp.newError("encode private key", ErrUnsupportedAlgorithm, "")
 	}
 }
 
 // getECDSAPrivateKeyBytes returns ECDSA private key as bytes
 func (msp *EnhancedMSP) getECDSAPrivateKeyBytes() ([]byte, error) {
-	key := msp.keyPair.(*ecdsa.PrivateKey)
+	key, ok := msp.keyPair.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, msp.keyError("encode private key", msp.keyPair)
+	}
 	return x509.MarshalECPrivateKey(key)
 }
 
 // getMLDSAPrivateKeyBytes returns real ML-DSA private key as bytes
 func (msp *EnhancedMSP) getMLDSAPrivateKeyBytes() ([]byte, error) {
-	keyPair := msp.keyPair.(*WorkingMLDSAKeyPair)
+	keyPair, ok := msp.keyPair.(*WorkingMLDSAKeyPair)
+	if !ok {
+		return nil, msp.keyError("encode private key", msp.keyPair)
+	}
+	if keyPair.PrivateKey == nil {
+		return nil, msp.newError("encode private key", ErrMissingKey, "")
+	}
 	return keyPair.GetPrivateKeyBytes(), nil
 }
 
+// Close wipes the private key and drops it, so that the MSP can still verify
+// with the public key but can no longer sign. It waits for signatures in
+// progress. Verifiers taken from the MSP hold public keys of their own and are
+// unaffected. Signers taken from the current key pair share its private key,
+// so Close then only drops it: the Signers keep signing, and the key is
+// released with the last of them. Closing twice is harmless.
+//
+// ECDSA keys are wiped through their big.Int scalar; crypto/ecdsa keeps its
+// own copy of the scalar for signing, which only the garbage collector
+// releases. An external key is destroyed if its KeySigner has a Destroy
+// method, as PKCS#11 session keys do, and otherwise stays on its token. A
+// stateful key's state stays in its state store.
+func (msp *EnhancedMSP) Close() error {
+	msp.mu.Lock()
+	defer msp.mu.Unlock()
+	var err error
+	if !msp.keyShared {
+		err = destroyKeyPair(msp.keyPair)
+	}
+	msp.keyPair = nil
+	return err
+}
+
+// destroyKeyPair wipes a private key that nothing else holds, as Close
+// describes
+func destroyKeyPair(keyPair interface{}) error {
+	switch key := keyPair.(type) {
+	case *ecdsa.PrivateKey:
+		if key.D != nil {
+			clear(key.D.Bits())
+			key.D.SetInt64(0)
+		}
+	case *WorkingMLDSAKeyPair:
+		key.Destroy()
+	case *hbs.Signer:
+		key.Key().Destroy()
+	case interface{ Destroy() error }:
+		if err := key.Destroy(); err != nil {
+			return fmt.Errorf("failed to destroy external key: %w", err)
+		}
+	}
+	return nil
+}
+
+// privateKeySize returns the size of the encoded private key without
+// encoding the key itself where the size is known in advance
+func (msp *EnhancedMSP) privateKeySize() (int, error) {
+	switch msp.algorithm {
+	case MLDSA44, MLDSA65, MLDSA87:
+		scheme, err := MLDSAScheme(msp.algorithm.mldsaSecurityLevel())
+		if err != nil {
+			return 0, err
+		}
+		return scheme.PrivateKeySize(), nil
+	case LMSHSS, XMSSMT:
+		return hbs.SeedSize, nil
+	}
+	// The DER encoding of an ECDSA key varies with the key
+	privateKeyBytes, err := msp.GetPrivateKeyBytes()
+	if err != nil {
+		return 0, err
+	}
+	defer clear(privateKeyBytes)
+	return len(privateKeyBytes), nil
+}
+
 // Benchmark performs comprehensive benchmarking of the cryptographic operations
 // Uses fresh instances and unique messages to avoid caching effects
 func (msp *EnhancedMSP) Benchmark(testMessage []byte, iterations int) (*CryptoMetrics, error) {
@@ -234,7 +625,7 @@ func (msp *EnhancedMSP) Benchmark(testMessage []byte, iterations int) (*CryptoMe
 	for i := 0; i < iterations; i++ {
 		// Create a fresh MSP instance for each key generation
 		start := time.Now()
-		freshMSP, err := NewEnhancedMSP(msp.algorithm)
+		freshMSP, err := msp.newInstance()
 		keygenTime := time.Since(start)
 		if err != nil {
 			return nil, fmt.Errorf("key generation failed: %v", err)
@@ -245,16 +636,55 @@ func (msp *EnhancedMSP) Benchmark(testMessage []byte, iterations int) (*CryptoMe
 			keygenTime = time.Microsecond
 		}
 		keygenTimes[i] = keygenTime
-		_ = freshMSP // Use variable to avoid unused error
+		if err := freshMSP.Close(); err != nil {
+			return nil, fmt.Errorf("failed to release generated key: %v", err)
+		}
 	}
 	metrics.KeygenTimeMs = float64(calculateAverageDuration(keygenTimes).Nanoseconds()) / 1e6
+	metrics.KeygenSamplesMs = durationsToMs(keygenTimes)
+
+	// A stateful key signs with consecutive one-time keys, as it does in
+	// production, so one fresh key makes every signature of the run and the
+	// verifiers hold only its public key. A key per iteration would cost
+	// seconds each.
+	newSigner := msp.newInstance
+	newVerifier := msp.newInstance
+	var statefulSigner *EnhancedMSP
+	if msp.algorithm.IsStateful() {
+		var err error
+		if statefulSigner, err = msp.newInstance(); err != nil {
+			return nil, fmt.Errorf("failed to create stateful signing MSP: %v", err)
+		}
+		newSigner = func() (*EnhancedMSP, error) { return statefulSigner, nil }
+		defer statefulSigner.Close()
+	}
+	// Every fresh signer is closed as soon as it is done with, so that a run
+	// does not leave thousands of private keys in the heap, or session keys
+	// on a key provider's token
+	release := func(signer *EnhancedMSP) error {
+		if signer == statefulSigner {
+			return nil
+		}
+		if err := signer.Close(); err != nil {
+			return fmt.Errorf("failed to release signing key: %v", err)
+		}
+		return nil
+	}
+	if msp.algorithm.IsStateful() || msp.keyProvider != nil {
+		// Verification uses the public key alone, in software, as a peer
+		// does; generating keys for the verifiers would only time the provider
+		provider := msp.provider()
+		newVerifier = func() (*EnhancedMSP, error) {
+			return &EnhancedMSP{algorithm: msp.algorithm, tracerProvider: provider}, nil
+		}
+	}
 
 	// Benchmark signing - use fresh instances to avoid caching
 	signTimes := make([]time.Duration, iterations)
 	var signature []byte
 	for i := 0; i < iterations; i++ {
 		// Create a fresh MSP instance for each signing
-		freshMSP, err := NewEnhancedMSP(msp.algorithm)
+		freshMSP, err := newSigner()
 		if err != nil {
 			return nil, fmt.Errorf("failed to create fresh MSP for signing: %v", err)
 		}
@@ -262,6 +692,9 @@ func (msp *EnhancedMSP) Benchmark(testMessage []byte, iterations int) (*CryptoMe
 		start := time.Now()
 		sig, err := freshMSP.Sign(testMessage)
 		signTime := time.Since(start)
+		if releaseErr := release(freshMSP); err == nil {
+			err = releaseErr
+		}
 		if err != nil {
 			return nil, fmt.Errorf("signing failed: %v", err)
 		}
@@ -274,32 +707,44 @@ func (msp *EnhancedMSP) Benchmark(testMessage []byte, iterations int) (*CryptoMe
 		signature = sig // Keep the last signature for verification
 	}
 	metrics.SignTimeMs = float64(calculateAverageDuration(signTimes).Nanoseconds()) / 1e6
+	metrics.SignSamplesMs = durationsToMs(signTimes)
 
 	// Benchmark verification - use fresh instances and unique messages to avoid caching
 	verifyTimes := make([]time.Duration, iterations)
 	for i := 0; i < iterations; i++ {
 		// Create fresh MSP instances for each verification
-		verifyMSP, err := NewEnhancedMSP(msp.algorithm)
+		verifyMSP, err := newVerifier()
 		if err != nil {
 			return nil, fmt.Errorf("failed to create verification MSP: %v", err)
 		}
+		// The verifier only needs the signer's public key
+		verifyMSP.Close()
 
-		signingMSP, err := NewEnhancedMSP(msp.algorithm)
+		signingMSP, err := newSigner()
 		if err != nil {
 			return nil, fmt.Errorf("failed to create signing MSP: %v", err)
 		}
 
-		// Create unique message for each verification to avoid caching
-		uniqueMessage := append(testMessage, []byte(fmt.Sprintf("_%d_%d", i, time.Now().UnixNano()))...)
+		// Create unique message for each verification to avoid caching. Seeded
+		// runs leave out the clock so that every signature can be reproduced.
+		suffix := fmt.Sprintf("_%d_%d", i, time.Now().UnixNano())
+		if msp.seeds != nil {
+			suffix = fmt.Sprintf("_%d", i)
+		}
+		uniqueMessage := append(testMessage, []byte(suffix)...)
 
 		// Sign with the signing MSP
 		sig, err := signingMSP.Sign(uniqueMessage)
 		if err != nil {
+			release(signingMSP)
 			return nil, fmt.Errorf("signing failed for verification: %v", err)
 		}
 
 		// Get the public key from the signing MSP
 		publicKeyBytes, err := signingMSP.GetPublicKeyBytes()
+		if releaseErr := release(signingMSP); err == nil {
+			err = releaseErr
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to get public key for verification: %v", err)
 		}
@@ -344,6 +789,7 @@ func (msp *EnhancedMSP) Benchmark(testMessage []byte, iterations int) (*CryptoMe
 		verifyTimes[i] = verifyTime
 	}
 	metrics.VerifyTimeMs = float64(calculateAverageDuration(verifyTimes).Nanoseconds()) / 1e6
+	metrics.VerifySamplesMs = durationsToMs(verifyTimes)
 
 	// Measure key sizes
 	publicKeyBytes, err := msp.GetPublicKeyBytes()
@@ -352,18 +798,60 @@ func (msp *EnhancedMSP) Benchmark(testMessage []byte, iterations int) (*CryptoMe
 	}
 	metrics.PublicKeyBytes = len(publicKeyBytes)
 
-	privateKeyBytes, err := msp.GetPrivateKeyBytes()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get private key bytes: %v", err)
+	// An external private key has no size the process can see
+	if msp.keyProvider != nil {
+		metrics.KeyProvider = msp.keyProvider.Name()
+	} else {
+		privateKeySize, err := msp.privateKeySize()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get private key size: %v", err)
+		}
+		metrics.PrivateKeyBytes = privateKeySize
 	}
-	metrics.PrivateKeyBytes = len(privateKeyBytes)
 
 	// Measure signature size
 	metrics.SignatureBytes = len(signature)
 
+	if statefulSigner != nil {
+		signer, ok := statefulSigner.keyPair.(*hbs.Signer)
+		if !ok {
+			return nil, statefulSigner.keyError("read state updates", statefulSigner.keyPair)
+		}
+		_, updateTime := signer.StateUpdates()
+		metrics.MaxSignatures = msp.algorithm.MaxSignatures()
+		metrics.StateUpdateTimeMs = float64(updateTime.Nanoseconds()) / 1e6 / float64(2*iterations)
+	}
+
 	return metrics, nil
 }
 
+// newInstance creates a fresh MSP of the same algorithm and options, drawing the
+// next keys from the seed sequence when the MSP is seeded
+func (msp *EnhancedMSP) newInstance() (*EnhancedMSP, error) {
+	msp.mu.RLock()
+	opts := msp.options()
+	msp.mu.RUnlock()
+	return NewEnhancedMSPWithOptions(context.Background(), msp.algorithm, opts)
+}
+
+// options returns the options the MSP was created with. The caller holds
+// msp.mu, which guards the tracer provider.
+func (msp *EnhancedMSP) options() Options {
+	return Options{
+		Seeds:          msp.seeds,
+		Signing:        msp.signing,
+		TracerProvider: msp.tracerProvider,
+		StateStore:     msp.stateStore,
+		StateReserve:   msp.stateReserve,
+		KeyProvider:    msp.keyProvider,
+	}
+}
+
+// GetSigningMode returns whether the MSP signs hedged or deterministically
+func (msp *EnhancedMSP) GetSigningMode() SigningMode {
+	return msp.signing
+}
+
 // calculateAverageDuration calculates the average duration from a slice of durations
 func calculateAverageDuration(durations []time.Duration) time.Duration {
 	if len(durations) == 0 {
@@ -378,49 +866,214 @@ func calculateAverageDuration(durations []time.Duration) time.Duration {
 	return total / time.Duration(len(durations))
 }
 
+// durationsToMs converts a slice of durations to milliseconds
+func durationsToMs(durations []time.Duration) []float64 {
+	ms := make([]float64, len(durations))
+	for i, d := range durations {
+		ms[i] = float64(d.Nanoseconds()) / 1e6
+	}
+	return ms
+}
+
 // GetAlgorithm returns the current algorithm
 func (msp *EnhancedMSP) GetAlgorithm() SignatureAlgorithm {
 	return msp.algorithm
 }
 
-// setPublicKeyFromBytes sets the public key from bytes (for verification benchmarking)
-func (msp *EnhancedMSP) setPublicKeyFromBytes(publicKeyBytes []byte) error {
+// Clone returns an MSP with its own copy of the key material, decoded from the
+// encoded keys so that no memory is shared with the original. An external key
+// cannot be copied and is shared, as with a Signer.
+func (msp *EnhancedMSP) Clone() (*EnhancedMSP, error) {
+	msp.mu.Lock()
+	defer msp.mu.Unlock()
+	publicKeyBytes, err := msp.publicKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	if signer, ok := msp.keyPair.(KeySigner); ok {
+		// The external key cannot be copied; the clone signs with the same
+		// one, so neither Close may destroy it
+		clone, err := NewEnhancedMSPFromKeySigner(msp.algorithm, signer, msp.options())
+		if err != nil {
+			return nil, err
+		}
+		msp.keyShared = true
+		clone.keyShared = true
+		return clone, nil
+	}
+	privateKeyBytes, err := msp.privateKeyBytes()
+	if err != nil {
+		// Verify-only MSPs clone to verify-only MSPs
+		clone := newEnhancedMSP(msp.algorithm, msp.options())
+		if err := clone.setPublicKeyFromBytes(publicKeyBytes); err != nil {
+			return nil, err
+		}
+		return clone, nil
+	}
+
+	defer clear(privateKeyBytes)
+
+	// A stateful clone signs through the same state store, so the two never
+	// use the same one-time key
+	return NewEnhancedMSPFromPrivateKey(msp.algorithm, privateKeyBytes, msp.options())
+}
+
+// setPrivateKeyFromBytes sets the key pair from an encoded private key
+func (msp *EnhancedMSP) setPrivateKeyFromBytes(privateKeyBytes []byte) error {
 	switch msp.algorithm {
 	case ECDSA:
-		return msp.setECDSAPublicKeyFromBytes(publicKeyBytes)
+		key, err := x509.ParseECPrivateKey(privateKeyBytes)
+		if err != nil {
+			return msp.newError("import private key", ErrMalformedKey, "%v", err)
+		}
+		if key.Curve != elliptic.P256() {
+			return msp.newError("import private key", ErrKeyMismatch, "ECDSA private key is on %s, expected P-256", key.Curve.Params().Name)
+		}
+		msp.setKeys(key, &key.PublicKey)
+		return nil
 	case MLDSA44, MLDSA65, MLDSA87:
-		return msp.setMLDSAPublicKeyFromBytes(publicKeyBytes)
+		scheme, err := MLDSAScheme(msp.algorithm.mldsaSecurityLevel())
+		if err != nil {
+			return err
+		}
+		if len(privateKeyBytes) != scheme.PrivateKeySize() {
+			return msp.mldsaSizeError("import private key", "private key", len(privateKeyBytes), sign.Scheme.PrivateKeySize)
+		}
+		keyPair, err := NewWorkingMLDSAKeyPairFromPrivateKey(msp.algorithm.mldsaSecurityLevel(), privateKeyBytes)
+		if err != nil {
+			return err
+		}
+		keyPair.Hedged = msp.signing == SigningHedged
+		return msp.setMLDSAKeys(keyPair)
+	case LMSHSS, XMSSMT:
+		key, err := msp.algorithm.StatefulScheme().NewPrivateKey(privateKeyBytes)
+		if err != nil {
+			return msp.newError("import private key", ErrMalformedKey, "%v", err)
+		}
+		msp.setStatefulKey(key)
+		return nil
 	default:
-		return fmt.Errorf("unsupported algorithm for public key setting: %v", msp.algorithm)
+		return msp.newError("import private key", ErrUnsupportedAlgorithm, "")
 	}
 }
 
-// setECDSAPublicKeyFromBytes sets ECDSA public key from bytes
-func (msp *EnhancedMSP) setECDSAPublicKeyFromBytes(publicKeyBytes []byte) error {
-	publicKey, err := x509.ParsePKIXPublicKey(publicKeyBytes)
+// setPublicKeyFromBytes sets the public key from bytes (for verification
+// benchmarking), keeping the private key of this MSP for signing
+func (msp *EnhancedMSP) setPublicKeyFromBytes(publicKeyBytes []byte) error {
+	publicKey, err := msp.parsePublicKey(publicKeyBytes)
 	if err != nil {
 		return err
 	}
+	msp.mu.Lock()
+	defer msp.mu.Unlock()
+	msp.publicKey = publicKey
+	return nil
+}
 
-	ecdsaPublicKey, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return fmt.Errorf("not an ECDSA public key")
+// SetPublicKey replaces the public key of a verify-only MSP, as a peer does
+// when another organisation renews its certificate. Verifications in progress
+// finish with the old key. An MSP with a private key changes keys with Rekey.
+func (msp *EnhancedMSP) SetPublicKey(publicKeyBytes []byte) error {
+	publicKey, err := msp.parsePublicKey(publicKeyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to import public key: %w", err)
+	}
+	msp.mu.Lock()
+	defer msp.mu.Unlock()
+	if msp.keyPair != nil {
+		return msp.newError("set public key", ErrKeyMismatch, "MSP holds a private key; use Rekey to replace its keys")
 	}
+	msp.publicKey = publicKey
+	return nil
+}
 
-	msp.publicKey = ecdsaPublicKey
+// Rekey replaces the key pair with a fresh one generated with the MSP's
+// options, so that a running identity can change keys without stopping its
+// signers. Signatures in progress finish with the old key. The old private
+// key is then wiped as Close wipes it, unless Signers taken from the MSP
+// still sign with it.
+func (msp *EnhancedMSP) Rekey() error {
+	next, err := msp.newInstance()
+	if err != nil {
+		return err
+	}
+	msp.mu.Lock()
+	defer msp.mu.Unlock()
+	old, shared := msp.keyPair, msp.keyShared
+	msp.keyPair = next.keyPair
+	msp.publicKey = next.publicKey
+	msp.keyShared = false
+	if !shared {
+		return destroyKeyPair(old)
+	}
 	return nil
 }
 
-// setMLDSAPublicKeyFromBytes sets ML-DSA public key from bytes
-func (msp *EnhancedMSP) setMLDSAPublicKeyFromBytes(publicKeyBytes []byte) error {
-	keyPair := msp.keyPair.(*WorkingMLDSAKeyPair)
+// setKeys publishes a key pair together with its public key, so that
+// concurrent operations see either the old keys or the new ones
+func (msp *EnhancedMSP) setKeys(keyPair, publicKey interface{}) {
+	msp.mu.Lock()
+	defer msp.mu.Unlock()
+	msp.keyPair = keyPair
+	msp.publicKey = publicKey
+	msp.keyShared = false
+}
 
-	// Create a new public key from bytes
-	publicKey := keyPair.Mode.PublicKeyFromBytes(publicKeyBytes)
+// parsePublicKey decodes an encoded public key of the MSP's algorithm
+func (msp *EnhancedMSP) parsePublicKey(publicKeyBytes []byte) (interface{}, error) {
+	switch msp.algorithm {
+	case ECDSA:
+		return msp.parseECDSAPublicKey(publicKeyBytes)
+	case MLDSA44, MLDSA65, MLDSA87:
+		return msp.parseMLDSAPublicKey(publicKeyBytes)
+	case LMSHSS, XMSSMT:
+		return msp.parseStatefulPublicKey(publicKeyBytes)
+	default:
+		return nil, msp.newError("import public key", ErrUnsupportedAlgorithm, "")
+	}
+}
 
-	// Update the key pair with the new public key
-	keyPair.PublicKey = publicKey
-	msp.publicKey = keyPair
+// parseECDSAPublicKey decodes a PKIX-encoded P-256 public key
+func (msp *EnhancedMSP) parseECDSAPublicKey(publicKeyBytes []byte) (*ecdsa.PublicKey, error) {
+	publicKey, err := x509.ParsePKIXPublicKey(publicKeyBytes)
+	if err != nil {
+		return nil, msp.newError("import public key", ErrMalformedKey, "%v", err)
+	}
 
-	return nil
+	ecdsaPublicKey, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, msp.newError("import public key", ErrKeyMismatch, "not an ECDSA public key but a %T", publicKey)
+	}
+	if ecdsaPublicKey.Curve != elliptic.P256() {
+		return nil, msp.newError("import public key", ErrKeyMismatch, "ECDSA public key is on %s, expected P-256", ecdsaPublicKey.Curve.Params().Name)
+	}
+	return ecdsaPublicKey, nil
+}
+
+// parseMLDSAPublicKey decodes an ML-DSA public key into a verify-only key pair
+func (msp *EnhancedMSP) parseMLDSAPublicKey(publicKeyBytes []byte) (*WorkingMLDSAKeyPair, error) {
+	scheme, err := MLDSAScheme(msp.algorithm.mldsaSecurityLevel())
+	if err != nil {
+		return nil, err
+	}
+
+	// The encoding has a fixed size per parameter set, so a key from another
+	// parameter set or a truncated key is rejected here
+	if len(publicKeyBytes) != scheme.PublicKeySize() {
+		return nil, msp.mldsaSizeError("import public key", "public key", len(publicKeyBytes), sign.Scheme.PublicKeySize)
+	}
+	return NewWorkingMLDSAKeyPairFromPublicKey(msp.algorithm.mldsaSecurityLevel(), publicKeyBytes)
+}
+
+// mldsaSizeError reports an ML-DSA key of the wrong size: the size of another
+// parameter set is a key mismatch, any other size a malformed key
+func (msp *EnhancedMSP) mldsaSizeError(op, what string, size int, sizeOf func(sign.Scheme) int) error {
+	for _, other := range []SignatureAlgorithm{MLDSA44, MLDSA65, MLDSA87} {
+		scheme, _ := MLDSAScheme(other.mldsaSecurityLevel())
+		if other != msp.algorithm && sizeOf(scheme) == size {
+			return msp.newError(op, ErrKeyMismatch, "%d-byte %s is %s", size, what, other.String())
+		}
+	}
+	scheme, _ := MLDSAScheme(msp.algorithm.mldsaSecurityLevel())
+	return msp.newError(op, ErrMalformedKey, "%s is %d bytes, expected %d", what, size, sizeOf(scheme))
 }
@@ -0,0 +1,83 @@
+package msp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors of MSP operations, for errors.Is. The MSP wraps them in an
+// *Error that names the operation and algorithm, so that a service can tell a
+// bad signature apart from a corrupt key in its alerts and audit logs.
+var (
+	// ErrUnsupportedAlgorithm is returned for an algorithm or parameter set
+	// the MSP does not implement
+	ErrUnsupportedAlgorithm = errors.New("unsupported signature algorithm")
+	// ErrMalformedKey is returned for a key encoding that does not decode
+	ErrMalformedKey = errors.New("malformed key")
+	// ErrMalformedSignature is returned for a signature that does not decode,
+	// including a non-canonical high-S ECDSA signature
+	ErrMalformedSignature = errors.New("malformed signature")
+	// ErrKeyMismatch is returned for a well-formed key of another algorithm,
+	// curve or parameter set than the MSP's, or a public key set on an MSP
+	// whose private key it would not match
+	ErrKeyMismatch = errors.New("key does not match the algorithm")
+	// ErrMissingKey is returned when the MSP lacks the key an operation needs,
+	// such as signing with a verify-only or closed MSP
+	ErrMissingKey = errors.New("no key for the operation")
+	// ErrInvalidContextString is returned for a context string longer than
+	// FIPS 204 allows, or any context string for an algorithm without one
+	ErrInvalidContextString = errors.New("invalid context string")
+	// ErrVerificationFailed is returned by Verify for a well-formed signature
+	// that is not valid for the message and public key
+	ErrVerificationFailed = errors.New("signature verification failed")
+	// ErrKeyNotExtractable is returned when the private key of an MSP is held
+	// by a KeySigner, such as an HSM, that never releases it
+	ErrKeyNotExtractable = errors.New("private key is held by an external signer and cannot be extracted")
+)
+
+// Error is a failed MSP operation. errors.Is matches it against its Kind and
+// the errors its cause wraps.
+type Error struct {
+	Op        string // Operation, such as "sign" or "import public key"
+	Algorithm SignatureAlgorithm
+	Kind      error // One of the sentinel errors
+	Err       error // Underlying cause, or nil
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s %s: %v", e.Algorithm.String(), e.Op, e.Kind)
+	}
+	return fmt.Sprintf("%s %s: %v: %v", e.Algorithm.String(), e.Op, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// newError returns an *Error of the MSP's algorithm, with a cause formatted
+// from format and args if format is not empty
+func (msp *EnhancedMSP) newError(op string, kind error, format string, args ...interface{}) error {
+	return newError(op, msp.algorithm, kind, format, args...)
+}
+
+// newError returns an *Error of an algorithm, for operations without an MSP
+func newError(op string, algorithm SignatureAlgorithm, kind error, format string, args ...interface{}) error {
+	e := &Error{Op: op, Algorithm: algorithm, Kind: kind}
+	if format != "" {
+		e.Err = fmt.Errorf(format, args...)
+	}
+	return e
+}
+
+// keyError explains why the key an operation needs is unusable: the MSP holds
+// none, or it holds a key of another type
+func (msp *EnhancedMSP) keyError(op string, key interface{}) error {
+	if key == nil {
+		return msp.newError(op, ErrMissingKey, "")
+	}
+	return msp.newError(op, ErrKeyMismatch, "MSP holds a %T", key)
+}
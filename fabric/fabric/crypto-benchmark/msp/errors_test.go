@@ -0,0 +1,92 @@
+package msp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestErrorKinds checks that failures outside signing and verification wrap
+// the sentinel errors.Is callers match on
+func TestErrorKinds(t *testing.T) {
+	signing, err := NewEnhancedMSP(MLDSA44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := signing.GetPublicKeyBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyOnly, err := NewEnhancedMSPFromPublicKey(MLDSA44, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := NewWorkingMLDSAKeyPair(44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destroyed, err := NewWorkingMLDSAKeyPair(44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destroyed.Destroy()
+	external, err := NewEnhancedMSPFromKeySigner(MLDSA44, &softwareSigner{keyPair: keyPair}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		err  func() error
+		kind error
+	}{
+		{"malformed public key", func() error { return verifyOnly.SetPublicKey(publicKey[1:]) }, ErrMalformedKey},
+		{"public key of another parameter set", func() error {
+			other, err := NewEnhancedMSP(MLDSA65)
+			if err != nil {
+				return err
+			}
+			otherKey, _ := other.GetPublicKeyBytes()
+			return verifyOnly.SetPublicKey(otherKey)
+		}, ErrKeyMismatch},
+		{"public key of a signing MSP", func() error { return signing.SetPublicKey(publicKey) }, ErrKeyMismatch},
+		{"signer of a verify-only MSP", func() error { _, err := verifyOnly.Signer(); return err }, ErrMissingKey},
+		{"verifier of an MSP without keys", func() error {
+			_, err := newEnhancedMSP(MLDSA44, Options{}).Verifier()
+			return err
+		}, ErrMissingKey},
+		{"batch of an unknown algorithm", func() error {
+			_, err := BatchVerify(SignatureAlgorithm(-1), nil, BatchOptions{})
+			return err
+		}, ErrUnsupportedAlgorithm},
+		{"key pair context string too long", func() error {
+			_, err := keyPair.SignWithContext([]byte("message"), bytes.Repeat([]byte{'c'}, MaxContextStringSize+1))
+			return err
+		}, ErrInvalidContextString},
+		{"destroyed key pair", func() error { _, err := destroyed.Sign([]byte("message")); return err }, ErrMissingKey},
+		{"key pair seed of the wrong size", func() error {
+			_, err := NewWorkingMLDSAKeyPairFromSeed(44, make([]byte, 31))
+			return err
+		}, ErrMalformedKey},
+		{"MSP seed of the wrong size", func() error {
+			_, err := NewEnhancedMSPFromSeed(MLDSA44, make([]byte, SeedSize-1), SigningDeterministic)
+			return err
+		}, ErrMalformedKey},
+		{"context string with an external key", func() error {
+			_, err := external.SignWithContextString(context.Background(), []byte("message"), []byte("context"))
+			return err
+		}, ErrInvalidContextString},
+		{"private key of an external key", func() error { _, err := external.GetPrivateKeyBytes(); return err }, ErrKeyNotExtractable},
+	}
+	for _, tt := range tests {
+		err := tt.err()
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("%s: error %v does not match %v", tt.name, err, tt.kind)
+		}
+		var mspErr *Error
+		if !errors.As(err, &mspErr) {
+			t.Errorf("%s: error %v is not an *Error", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package msp
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+)
+
+// KeySigner is a private key held outside the process, such as in an HSM,
+// which signs without exposing the key. A KeySigner may also have a
+// Destroy() error method, which Close and Rekey call once no other MSP or
+// Signer shares the key, so that keys generated per MSP do not pile up on
+// the token.
+type KeySigner interface {
+	// PublicKey returns the public key encoded as GetPublicKeyBytes returns it
+	PublicKey() []byte
+	// Sign signs a SHA-256 message hash as the MSP would with a software key:
+	// an ASN.1 ECDSA signature of the hash, or an ML-DSA signature of the hash
+	// with the empty context string
+	Sign(hash []byte) ([]byte, error)
+}
+
+// KeyProvider generates keys outside the process, so that the benchmark
+// harness can run on HSM-resident keys
+type KeyProvider interface {
+	// Name describes where keys are held, for the results file
+	Name() string
+	// GenerateKey generates a key pair of an algorithm
+	GenerateKey(algorithm SignatureAlgorithm) (KeySigner, error)
+}
+
+// NewEnhancedMSPFromKeySigner creates an MSP that signs with an external key.
+// The token decides how signatures are randomised, so opts.Signing does not
+// apply, and the MSP supports the empty context string only.
+func NewEnhancedMSPFromKeySigner(algorithm SignatureAlgorithm, signer KeySigner, opts Options) (*EnhancedMSP, error) {
+	msp := newEnhancedMSP(algorithm, opts)
+	if err := msp.setKeySigner(signer); err != nil {
+		return nil, err
+	}
+	return msp, nil
+}
+
+// generateExternalKeyPair generates the key pair with the MSP's key provider
+func (msp *EnhancedMSP) generateExternalKeyPair() error {
+	if msp.seeds != nil {
+		return fmt.Errorf("keys from %s cannot be derived from a seed", msp.keyProvider.Name())
+	}
+	signer, err := msp.keyProvider.GenerateKey(msp.algorithm)
+	if err != nil {
+		return fmt.Errorf("failed to generate %s key pair with %s: %w", msp.algorithm.String(), msp.keyProvider.Name(), err)
+	}
+	return msp.setKeySigner(signer)
+}
+
+// setKeySigner imports the signer's public key and signs with it from then on
+func (msp *EnhancedMSP) setKeySigner(signer KeySigner) error {
+	if msp.algorithm.IsStateful() {
+		return msp.newError("import external key", ErrUnsupportedAlgorithm, "stateful keys cannot be held by an external signer")
+	}
+	publicKey, err := msp.parsePublicKey(signer.PublicKey())
+	if err != nil {
+		return fmt.Errorf("external %s public key: %w", msp.algorithm.String(), err)
+	}
+	msp.setKeys(signer, publicKey)
+	return nil
+}
+
+// signExternal signs a hash with an external key, normalising ECDSA
+// signatures to low-S as for software keys
+func (msp *EnhancedMSP) signExternal(signer KeySigner, hash, contextString []byte) ([]byte, error) {
+	if len(contextString) > 0 {
+		return nil, msp.newError("sign", ErrInvalidContextString, "external keys only sign with the empty context string")
+	}
+	signature, err := signer.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	if msp.algorithm == ECDSA {
+		publicKey, ok := msp.publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, msp.keyError("sign", msp.publicKey)
+		}
+		return toLowS(publicKey, signature)
+	}
+	return signature, nil
+}
@@ -0,0 +1,131 @@
+package msp
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// softwareSigner is a KeySigner backed by an in-process ML-DSA key pair that
+// counts how often it is destroyed
+type softwareSigner struct {
+	keyPair    *WorkingMLDSAKeyPair
+	destroyed  int
+	destroyErr error
+}
+
+func (s *softwareSigner) PublicKey() []byte { return s.keyPair.GetPublicKeyBytes() }
+
+func (s *softwareSigner) Sign(hash []byte) ([]byte, error) { return s.keyPair.Sign(hash) }
+
+func (s *softwareSigner) Destroy() error {
+	s.destroyed++
+	return s.destroyErr
+}
+
+// softwareProvider generates softwareSigners and keeps them for inspection
+type softwareProvider struct {
+	signers []*softwareSigner
+}
+
+func (p *softwareProvider) Name() string { return "software" }
+
+func (p *softwareProvider) GenerateKey(algorithm SignatureAlgorithm) (KeySigner, error) {
+	keyPair, err := NewWorkingMLDSAKeyPair(algorithm.mldsaSecurityLevel())
+	if err != nil {
+		return nil, err
+	}
+	signer := &softwareSigner{keyPair: keyPair}
+	p.signers = append(p.signers, signer)
+	return signer, nil
+}
+
+func newSoftwareSigner(t *testing.T) *softwareSigner {
+	t.Helper()
+	keyPair, err := NewWorkingMLDSAKeyPair(44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &softwareSigner{keyPair: keyPair}
+}
+
+// TestCloseDestroysExternalKey checks that Close destroys an external key
+// once, and only when no clone or Signer shares it
+func TestCloseDestroysExternalKey(t *testing.T) {
+	signer := newSoftwareSigner(t)
+	m, err := NewEnhancedMSPFromKeySigner(MLDSA44, signer, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if signer.destroyed != 1 {
+		t.Errorf("Close destroyed the external key %d times, expected once", signer.destroyed)
+	}
+
+	shared := newSoftwareSigner(t)
+	m, err = NewEnhancedMSPFromKeySigner(MLDSA44, shared, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone, err := m.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clone.Sign([]byte("message")); err != nil {
+		t.Errorf("clone stopped signing once the original was closed: %v", err)
+	}
+	if err := clone.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if shared.destroyed != 0 {
+		t.Error("Close destroyed an external key that a clone shares")
+	}
+
+	failing := newSoftwareSigner(t)
+	failing.destroyErr = errors.New("token error")
+	m, err = NewEnhancedMSPFromKeySigner(MLDSA44, failing, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); !errors.Is(err, failing.destroyErr) {
+		t.Errorf("Close returned %v, expected the Destroy error", err)
+	}
+}
+
+// TestBenchmarkDestroysProviderKeys checks that a benchmark run with a key
+// provider destroys every key it generates, and that Rekey destroys the key
+// it replaces
+func TestBenchmarkDestroysProviderKeys(t *testing.T) {
+	provider := &softwareProvider{}
+	m, err := NewEnhancedMSPWithOptions(context.Background(), MLDSA44, Options{KeyProvider: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Benchmark([]byte("message"), 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Rekey(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every key but the MSP's current one is destroyed, exactly once
+	current := provider.signers[len(provider.signers)-1]
+	for i, signer := range provider.signers {
+		expected := 1
+		if signer == current {
+			expected = 0
+		}
+		if signer.destroyed != expected {
+			t.Errorf("key %d of %d destroyed %d times, expected %d", i+1, len(provider.signers), signer.destroyed, expected)
+		}
+	}
+}
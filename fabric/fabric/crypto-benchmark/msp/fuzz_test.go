@@ -0,0 +1,197 @@
+package msp
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// Fuzz targets for every MSP entry point that parses untrusted bytes. Each
+// takes an algorithm byte, so that one corpus covers every algorithm, and is
+// seeded with the reference key pair and signature of that algorithm:
+//
+//	go test ./msp -run '^$' -fuzz FuzzVerifySignature -fuzztime 30s
+//
+// The reference keys are derived from a fixed seed and sign deterministically,
+// so a failing input that go test saves under testdata/fuzz reproduces.
+
+// fuzzAlgorithms are the algorithms an algorithm byte selects from
+var fuzzAlgorithms = []SignatureAlgorithm{ECDSA, MLDSA44, MLDSA65, MLDSA87, LMSHSS, XMSSMT}
+
+func fuzzAlgorithm(b byte) SignatureAlgorithm {
+	return fuzzAlgorithms[int(b)%len(fuzzAlgorithms)]
+}
+
+// fuzzFixture is a reference key pair, message and signature
+type fuzzFixture struct {
+	signer     *EnhancedMSP
+	verifier   *EnhancedMSP
+	publicKey  []byte
+	privateKey []byte
+	message    []byte
+	signature  []byte
+}
+
+var (
+	fuzzFixturesOnce sync.Once
+	fuzzFixtures     map[SignatureAlgorithm]*fuzzFixture
+)
+
+// referenceFixture returns the reference fixture of an algorithm. Every
+// fixture is created on first use, the stateful ones first: their key
+// generation hashes a million times, and SHA-256 has been seen to run an
+// order of magnitude slower for a while after an ML-DSA signature.
+func referenceFixture(t testing.TB, algorithm SignatureAlgorithm) *fuzzFixture {
+	fuzzFixturesOnce.Do(func() {
+		fuzzFixtures = make(map[SignatureAlgorithm]*fuzzFixture)
+		for _, a := range []SignatureAlgorithm{LMSHSS, XMSSMT, ECDSA, MLDSA44, MLDSA65, MLDSA87} {
+			f, err := newFuzzFixture(a)
+			if err != nil {
+				t.Fatalf("%s reference fixture: %v", a, err)
+			}
+			fuzzFixtures[a] = f
+		}
+	})
+	f, ok := fuzzFixtures[algorithm]
+	if !ok {
+		t.Fatalf("no %s reference fixture", algorithm)
+	}
+	return f
+}
+
+// newFuzzFixture derives a reference key pair from a fixed seed and signs the
+// reference message
+func newFuzzFixture(algorithm SignatureAlgorithm) (*fuzzFixture, error) {
+	seed := bytes.Repeat([]byte{byte(algorithm) + 1}, SeedSize)
+	signer, err := NewEnhancedMSPFromSeed(algorithm, seed, SigningDeterministic)
+	if err != nil {
+		return nil, err
+	}
+	f := &fuzzFixture{signer: signer, message: []byte("Fuzzing reference message")}
+	if f.publicKey, err = signer.GetPublicKeyBytes(); err != nil {
+		return nil, err
+	}
+	if f.privateKey, err = signer.GetPrivateKeyBytes(); err != nil {
+		return nil, err
+	}
+	if f.signature, err = signer.Sign(f.message); err != nil {
+		return nil, err
+	}
+	if f.verifier, err = NewEnhancedMSPFromPublicKey(algorithm, f.publicKey); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// addSeeds adds the value each algorithm's fixture gives, whole, truncated
+// and empty, to the seed corpus
+func addSeeds(f *testing.F, value func(*fuzzFixture) []byte) {
+	for i, algorithm := range fuzzAlgorithms {
+		data := value(referenceFixture(f, algorithm))
+		f.Add(byte(i), data)
+		f.Add(byte(i), data[:len(data)/2])
+	}
+	f.Add(byte(0), []byte(nil))
+}
+
+// checkKind fails unless err matches one of the sentinel errors
+func checkKind(t *testing.T, err error, kinds ...error) {
+	t.Helper()
+	for _, kind := range kinds {
+		if errors.Is(err, kind) {
+			return
+		}
+	}
+	t.Fatalf("error %q matches none of %v", err, kinds)
+}
+
+// FuzzImportPublicKey imports untrusted public key bytes: PKIX DER for ECDSA,
+// raw FIPS 204 keys for ML-DSA and RFC 8554/8391 keys for LMS-HSS and
+// XMSS-MT. A key that imports re-encodes to the same bytes, cannot sign, and
+// accepts the reference signature only if it is the reference key.
+func FuzzImportPublicKey(f *testing.F) {
+	addSeeds(f, func(x *fuzzFixture) []byte { return x.publicKey })
+	f.Fuzz(func(t *testing.T, a byte, data []byte) {
+		algorithm := fuzzAlgorithm(a)
+		ref := referenceFixture(t, algorithm)
+		verifier, err := NewEnhancedMSPFromPublicKey(algorithm, data)
+		if err != nil {
+			checkKind(t, err, ErrMalformedKey, ErrKeyMismatch)
+			return
+		}
+		encoded, err := verifier.GetPublicKeyBytes()
+		if err != nil {
+			t.Fatalf("imported key cannot be re-encoded: %v", err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Fatalf("imported key re-encodes differently")
+		}
+		if _, err := verifier.Sign(ref.message); !errors.Is(err, ErrMissingKey) {
+			t.Fatalf("verify-only MSP signed: %v", err)
+		}
+		if valid, _ := verifier.Verify(ref.message, ref.signature); valid && !bytes.Equal(data, ref.publicKey) {
+			t.Fatalf("another key accepted the reference signature")
+		}
+	})
+}
+
+// FuzzImportPrivateKey imports untrusted private key bytes: SEC 1 DER parsed
+// by x509.ParseECPrivateKey for ECDSA, expanded FIPS 204 keys for ML-DSA and
+// seeds for LMS-HSS and XMSS-MT. A key that imports signs a message that its
+// own public key verifies.
+func FuzzImportPrivateKey(f *testing.F) {
+	addSeeds(f, func(x *fuzzFixture) []byte { return x.privateKey })
+	f.Fuzz(func(t *testing.T, a byte, data []byte) {
+		algorithm := fuzzAlgorithm(a)
+		ref := referenceFixture(t, algorithm)
+		m, err := NewEnhancedMSPFromPrivateKey(algorithm, data, Options{Signing: SigningDeterministic})
+		if err != nil {
+			checkKind(t, err, ErrMalformedKey, ErrKeyMismatch)
+			return
+		}
+		defer m.Close()
+		signature, err := m.Sign(ref.message)
+		if err != nil {
+			t.Fatalf("imported key cannot sign: %v", err)
+		}
+		if valid, err := m.Verify(ref.message, signature); !valid {
+			t.Fatalf("imported key's signature does not verify: %v", err)
+		}
+	})
+}
+
+// FuzzVerifySignature verifies untrusted signature bytes of the reference
+// message: ASN.1 DER for ECDSA, FIPS 204 for ML-DSA, and RFC 8554/8391
+// signatures for LMS-HSS and XMSS-MT. Signatures are non-malleable, and
+// Fabric rejects high-S ECDSA, so only the reference signature may verify.
+func FuzzVerifySignature(f *testing.F) {
+	addSeeds(f, func(x *fuzzFixture) []byte { return x.signature })
+	f.Fuzz(func(t *testing.T, a byte, data []byte) {
+		ref := referenceFixture(t, fuzzAlgorithm(a))
+		valid, err := ref.verifier.Verify(ref.message, data)
+		if !valid {
+			checkKind(t, err, ErrMalformedSignature, ErrVerificationFailed)
+			return
+		}
+		if !bytes.Equal(data, ref.signature) {
+			t.Fatalf("modified signature verified")
+		}
+	})
+}
+
+// FuzzVerifyMessage verifies the reference signature over an untrusted message
+func FuzzVerifyMessage(f *testing.F) {
+	addSeeds(f, func(x *fuzzFixture) []byte { return x.message })
+	f.Fuzz(func(t *testing.T, a byte, data []byte) {
+		ref := referenceFixture(t, fuzzAlgorithm(a))
+		valid, err := ref.verifier.Verify(data, ref.signature)
+		if !valid {
+			checkKind(t, err, ErrVerificationFailed)
+			return
+		}
+		if !bytes.Equal(data, ref.message) {
+			t.Fatalf("signature verified for a different message")
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package msp
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"crypto-benchmark/hbs"
+)
+
+// StatefulScheme returns the parameter set of a stateful hash-based
+// algorithm, or nil for other algorithms
+func (sa SignatureAlgorithm) StatefulScheme() hbs.Scheme {
+	switch sa {
+	case LMSHSS:
+		return hbs.HSSSHA256H10L2
+	case XMSSMT:
+		return hbs.XMSSMTSHA2H20D2
+	default:
+		return nil
+	}
+}
+
+// IsStateful reports whether the algorithm is a stateful hash-based scheme,
+// whose private key must never sign twice with the same one-time key
+func (sa SignatureAlgorithm) IsStateful() bool {
+	return sa.StatefulScheme() != nil
+}
+
+// MaxSignatures returns how many signatures one key of a stateful algorithm
+// can make, or 0 for algorithms without a limit
+func (sa SignatureAlgorithm) MaxSignatures() uint64 {
+	if scheme := sa.StatefulScheme(); scheme != nil {
+		return scheme.MaxSignatures()
+	}
+	return 0
+}
+
+// hbsPublicKey is an encoded LMS/HSS or XMSS^MT public key
+type hbsPublicKey struct {
+	scheme hbs.Scheme
+	bytes  []byte
+}
+
+// generateStatefulKeyPair derives a stateful key from a seed, or from fresh
+// randomness if seed is nil, and binds it to the MSP's state store
+func (msp *EnhancedMSP) generateStatefulKeyPair(seed []byte) error {
+	scheme := msp.algorithm.StatefulScheme()
+	var key hbs.PrivateKey
+	var err error
+	if seed == nil {
+		key, err = hbs.GenerateKey(scheme, rand.Reader)
+	} else {
+		key, err = scheme.NewPrivateKey(seed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate %s key pair: %w", msp.algorithm.String(), err)
+	}
+	msp.setStatefulKey(key)
+	return nil
+}
+
+// setStatefulKey wraps a private key in a signer that reserves its indices in
+// the MSP's state store, an in-memory store if none was configured
+func (msp *EnhancedMSP) setStatefulKey(key hbs.PrivateKey) {
+	if msp.stateStore == nil {
+		msp.stateStore = hbs.NewMemoryStateStore()
+	}
+	msp.setKeys(hbs.NewSigner(key, msp.stateStore, msp.stateReserve), &hbsPublicKey{scheme: key.Scheme(), bytes: key.PublicKey()})
+}
+
+// signStateful signs a hash with the next unused one-time key
+func (msp *EnhancedMSP) signStateful(hash []byte) ([]byte, error) {
+	signer, ok := msp.keyPair.(*hbs.Signer)
+	if !ok {
+		return nil, msp.keyError("sign", msp.keyPair)
+	}
+	return signer.Sign(hash)
+}
+
+// verifyStateful verifies an LMS/HSS or XMSS^MT signature of a hash
+func (msp *EnhancedMSP) verifyStateful(hash, signature []byte) (bool, error) {
+	publicKey, ok := msp.publicKey.(*hbsPublicKey)
+	if !ok {
+		return false, msp.keyError("verify", msp.publicKey)
+	}
+	if len(signature) != publicKey.scheme.SignatureSize() {
+		return false, msp.newError("verify", ErrMalformedSignature, "signature is %d bytes, expected %d", len(signature), publicKey.scheme.SignatureSize())
+	}
+	if !publicKey.scheme.Verify(publicKey.bytes, hash, signature) {
+		return false, msp.newError("verify", ErrVerificationFailed, "")
+	}
+	return true, nil
+}
+
+// getStatefulPublicKeyBytes returns the encoded public key
+func (msp *EnhancedMSP) getStatefulPublicKeyBytes() ([]byte, error) {
+	publicKey, ok := msp.publicKey.(*hbsPublicKey)
+	if !ok {
+		return nil, msp.keyError("encode public key", msp.publicKey)
+	}
+	return append([]byte(nil), publicKey.bytes...), nil
+}
+
+// getStatefulPrivateKeyBytes returns the seed of the private key. It carries
+// no state: a key rebuilt from it is only safe with the same state store.
+func (msp *EnhancedMSP) getStatefulPrivateKeyBytes() ([]byte, error) {
+	signer, ok := msp.keyPair.(*hbs.Signer)
+	if !ok {
+		return nil, msp.keyError("encode private key", msp.keyPair)
+	}
+	return signer.Key().Seed(), nil
+}
+
+// parseStatefulPublicKey decodes an LMS/HSS or XMSS^MT public key, which must
+// have the size and type codes of the algorithm's parameter set
+func (msp *EnhancedMSP) parseStatefulPublicKey(publicKeyBytes []byte) (*hbsPublicKey, error) {
+	scheme := msp.algorithm.StatefulScheme()
+	if err := scheme.CheckPublicKey(publicKeyBytes); err != nil {
+		// The right size with the wrong type codes is another parameter set
+		kind := ErrMalformedKey
+		if len(publicKeyBytes) == scheme.PublicKeySize() {
+			kind = ErrKeyMismatch
+		}
+		for _, other := range []SignatureAlgorithm{LMSHSS, XMSSMT} {
+			if other.StatefulScheme().CheckPublicKey(publicKeyBytes) == nil {
+				kind = ErrKeyMismatch
+			}
+		}
+		return nil, msp.newError("import public key", kind, "%v", err)
+	}
+	return &hbsPublicKey{scheme: scheme, bytes: append([]byte(nil), publicKeyBytes...)}, nil
+}
+
+// SignaturesRemaining returns how many more signatures a stateful MSP can make
+func (msp *EnhancedMSP) SignaturesRemaining() (uint64, error) {
+	msp.mu.RLock()
+	defer msp.mu.RUnlock()
+	signer, ok := msp.keyPair.(*hbs.Signer)
+	if !ok {
+		return 0, msp.keyError("count remaining signatures", msp.keyPair)
+	}
+	return signer.Remaining()
+}
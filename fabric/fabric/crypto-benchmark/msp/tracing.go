@@ -0,0 +1,84 @@
+package msp
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies spans created by this package
+const instrumentationName = "crypto-benchmark/msp"
+
+// Span names and attribute keys recorded around MSP operations
+const (
+	SpanKeyGen = "msp.keygen"
+	SpanSign   = "msp.sign"
+	SpanVerify = "msp.verify"
+
+	AttrAlgorithm     = attribute.Key("crypto.algorithm")
+	AttrMessageSize   = attribute.Key("crypto.message.size")
+	AttrSignatureSize = attribute.Key("crypto.signature.size")
+	AttrResult        = attribute.Key("crypto.result")
+	AttrContextString = attribute.Key("crypto.context")
+)
+
+// SetTracerProvider makes the MSP record spans with the given provider instead of
+// the global one registered with otel.SetTracerProvider. Operations already
+// started finish with the provider they began with.
+func (msp *EnhancedMSP) SetTracerProvider(provider trace.TracerProvider) {
+	msp.mu.Lock()
+	defer msp.mu.Unlock()
+	msp.tracerProvider = provider
+}
+
+// provider returns the MSP's own tracer provider, nil if it uses the global one
+func (msp *EnhancedMSP) provider() trace.TracerProvider {
+	msp.mu.RLock()
+	defer msp.mu.RUnlock()
+	return msp.tracerProvider
+}
+
+// tracer returns the tracer for this MSP; without any provider configured the
+// global no-op provider makes tracing free
+func (msp *EnhancedMSP) tracer() trace.Tracer {
+	return tracerFrom(msp.provider())
+}
+
+// tracerFrom returns a tracer from the provider, falling back to the global provider
+func tracerFrom(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(instrumentationName)
+}
+
+// startSpan starts a span carrying the algorithm attribute
+func startSpan(ctx context.Context, tracer trace.Tracer, name string, algorithm SignatureAlgorithm, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, AttrAlgorithm.String(algorithm.String()))
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// contextAttributes returns the message size and, when one is set, the context
+// string attributes of a sign or verify span. Context strings are public labels
+// such as those in Domains, so they are recorded verbatim.
+func contextAttributes(message, contextString []byte) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{AttrMessageSize.Int(len(message))}
+	if len(contextString) > 0 {
+		attrs = append(attrs, AttrContextString.String(string(contextString)))
+	}
+	return attrs
+}
+
+// endSpan records the outcome of an operation and ends the span
+func endSpan(span trace.Span, result string, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		result = "error"
+	}
+	span.SetAttributes(AttrResult.String(result))
+	span.End()
+}
@@ -0,0 +1,158 @@
+package msp
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestTracerProvider returns a synchronous SDK provider whose spans are
+// kept in the returned in-memory exporter
+func newTestTracerProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return provider, exporter
+}
+
+// expectedSpan is the name, attributes and status a span must have
+type expectedSpan struct {
+	name   string
+	attrs  map[attribute.Key]attribute.Value
+	status codes.Code
+}
+
+// checkSpans compares the exported spans, in order, with the expected ones.
+// Attributes not listed are ignored.
+func checkSpans(t *testing.T, spans tracetest.SpanStubs, expected []expectedSpan) {
+	t.Helper()
+	if len(spans) != len(expected) {
+		t.Fatalf("exported %d spans, expected %d", len(spans), len(expected))
+	}
+	for i, want := range expected {
+		span := spans[i]
+		if span.Name != want.name {
+			t.Errorf("span %d is %q, expected %q", i, span.Name, want.name)
+			continue
+		}
+		got := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes {
+			got[kv.Key] = kv.Value
+		}
+		for key, value := range want.attrs {
+			if got[key] != value {
+				t.Errorf("%s: %s = %v, expected %v", span.Name, key, got[key].Emit(), value.Emit())
+			}
+		}
+		if span.Status.Code != want.status {
+			t.Errorf("%s: status %v, expected %v", span.Name, span.Status.Code, want.status)
+		}
+	}
+}
+
+// TestTracingSpans checks the span each MSP operation records, including a
+// verification that fails
+func TestTracingSpans(t *testing.T) {
+	for _, algorithm := range []SignatureAlgorithm{ECDSA, MLDSA44} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			provider, exporter := newTestTracerProvider(t)
+			ctx := context.Background()
+			m, err := NewEnhancedMSPContext(ctx, algorithm, provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			message := []byte("traced message")
+			signature, err := m.SignContext(ctx, message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid, err := m.VerifyContext(ctx, message, signature); !valid {
+				t.Fatalf("signature does not verify: %v", err)
+			}
+			tampered := append([]byte(nil), message...)
+			tampered[0] ^= 1
+			if valid, _ := m.VerifyContext(ctx, tampered, signature); valid {
+				t.Fatal("signature verifies a tampered message")
+			}
+
+			name := attribute.StringValue(algorithm.String())
+			size := attribute.IntValue(len(message))
+			signatureSize := attribute.IntValue(len(signature))
+			expected := []expectedSpan{
+				{SpanKeyGen, map[attribute.Key]attribute.Value{
+					AttrAlgorithm: name,
+					AttrResult:    attribute.StringValue("ok"),
+				}, codes.Unset},
+				{SpanSign, map[attribute.Key]attribute.Value{
+					AttrAlgorithm:     name,
+					AttrMessageSize:   size,
+					AttrSignatureSize: signatureSize,
+					AttrResult:        attribute.StringValue("ok"),
+				}, codes.Unset},
+				{SpanVerify, map[attribute.Key]attribute.Value{
+					AttrAlgorithm:     name,
+					AttrMessageSize:   size,
+					AttrSignatureSize: signatureSize,
+					AttrResult:        attribute.StringValue("valid"),
+				}, codes.Unset},
+				// A signature that does not verify is an outcome, not an error
+				{SpanVerify, map[attribute.Key]attribute.Value{
+					AttrAlgorithm:     name,
+					AttrMessageSize:   size,
+					AttrSignatureSize: signatureSize,
+					AttrResult:        attribute.StringValue("invalid"),
+				}, codes.Unset},
+			}
+			checkSpans(t, exporter.GetSpans(), expected)
+		})
+	}
+}
+
+// TestTracingContextAndErrors checks the context string attribute and that a
+// failed operation records its error
+func TestTracingContextAndErrors(t *testing.T) {
+	provider, exporter := newTestTracerProvider(t)
+	ctx := context.Background()
+	m, err := NewEnhancedMSP(MLDSA44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	m.SetTracerProvider(provider)
+
+	message := []byte("traced message")
+	contextString := []byte(Domains[0].Context)
+	signature, err := m.SignWithContextString(ctx, message, contextString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SignWithContextString(ctx, message, make([]byte, MaxContextStringSize+1)); err == nil {
+		t.Fatal("signed with an oversized context string")
+	}
+
+	name := attribute.StringValue(MLDSA44.String())
+	checkSpans(t, exporter.GetSpans(), []expectedSpan{
+		{SpanSign, map[attribute.Key]attribute.Value{
+			AttrAlgorithm:     name,
+			AttrMessageSize:   attribute.IntValue(len(message)),
+			AttrSignatureSize: attribute.IntValue(len(signature)),
+			AttrContextString: attribute.StringValue(Domains[0].Context),
+			AttrResult:        attribute.StringValue("ok"),
+		}, codes.Unset},
+		{SpanSign, map[attribute.Key]attribute.Value{
+			AttrAlgorithm:     name,
+			AttrSignatureSize: attribute.IntValue(0),
+			AttrResult:        attribute.StringValue("error"),
+		}, codes.Error},
+	})
+	if events := exporter.GetSpans()[1].Events; len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("failed signing recorded events %v, expected one exception", events)
+	}
+}
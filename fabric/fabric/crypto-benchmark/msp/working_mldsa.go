@@ -1,37 +1,59 @@
 package msp
 
 import (
-	"crypto/rand"
 	"fmt"
 
-	"github.com/cloudflare/circl/sign/dilithium"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
 )
 
-// WorkingMLDSAKeyPair represents a working ML-DSA key pair using Cloudflare CIRCL
+// WorkingMLDSAKeyPair represents a working ML-DSA key pair using Cloudflare CIRCL.
+// Sign and Verify are safe for concurrent use, provided that the fields are
+// not changed once the key pair is shared. Destroy is not: it must only run
+// once nothing else uses the key pair.
 type WorkingMLDSAKeyPair struct {
 	SecurityLevel int
-	PrivateKey    dilithium.PrivateKey
-	PublicKey     dilithium.PublicKey
-	Mode          dilithium.Mode
+	PrivateKey    sign.PrivateKey
+	PublicKey     sign.PublicKey
+	Scheme        sign.Scheme
+	Hedged        bool // Mix fresh randomness into signatures instead of the all-zero rnd
 }
 
-// NewWorkingMLDSAKeyPair creates a new working ML-DSA key pair
-func NewWorkingMLDSAKeyPair(securityLevel int) (*WorkingMLDSAKeyPair, error) {
-	var mode dilithium.Mode
-
+// MLDSAScheme returns the FIPS 204 parameter set for a security level
+func MLDSAScheme(securityLevel int) (sign.Scheme, error) {
 	switch securityLevel {
 	case 44:
-		mode = dilithium.Mode2 // ML-DSA-44 (Dilithium2)
+		return mldsa44.Scheme(), nil
 	case 65:
-		mode = dilithium.Mode3 // ML-DSA-65 (Dilithium3)
+		return mldsa65.Scheme(), nil
 	case 87:
-		mode = dilithium.Mode5 // ML-DSA-87 (Dilithium5)
+		return mldsa87.Scheme(), nil
 	default:
-		return nil, fmt.Errorf("unsupported ML-DSA security level: %d", securityLevel)
+		return nil, fmt.Errorf("%w: ML-DSA security level %d", ErrUnsupportedAlgorithm, securityLevel)
+	}
+}
+
+// algorithm returns the signature algorithm of the key pair's security level
+func (k *WorkingMLDSAKeyPair) algorithm() SignatureAlgorithm {
+	for _, sa := range []SignatureAlgorithm{MLDSA44, MLDSA65, MLDSA87} {
+		if sa.mldsaSecurityLevel() == k.SecurityLevel {
+			return sa
+		}
+	}
+	return SignatureAlgorithm(-1)
+}
+
+// NewWorkingMLDSAKeyPair creates a new working ML-DSA key pair
+func NewWorkingMLDSAKeyPair(securityLevel int) (*WorkingMLDSAKeyPair, error) {
+	scheme, err := MLDSAScheme(securityLevel)
+	if err != nil {
+		return nil, err
 	}
 
 	// Generate real key pair using CIRCL
-	publicKey, privateKey, err := mode.GenerateKey(rand.Reader)
+	publicKey, privateKey, err := scheme.GenerateKey()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate ML-DSA key pair: %v", err)
 	}
@@ -40,43 +62,212 @@ func NewWorkingMLDSAKeyPair(securityLevel int) (*WorkingMLDSAKeyPair, error) {
 		SecurityLevel: securityLevel,
 		PrivateKey:    privateKey,
 		PublicKey:     publicKey,
-		Mode:          mode,
+		Scheme:        scheme,
 	}, nil
 }
 
-// Sign signs a message using the real ML-DSA implementation
-func (k *WorkingMLDSAKeyPair) Sign(message []byte) []byte {
-	// Use real Dilithium signing from CIRCL library
-	return k.Mode.Sign(k.PrivateKey, message)
+// NewWorkingMLDSAKeyPairFromSeed derives a key pair from the 32-byte seed ξ of
+// FIPS 204, so the same seed gives the same keys in every conforming implementation
+func NewWorkingMLDSAKeyPairFromSeed(securityLevel int, seed []byte) (*WorkingMLDSAKeyPair, error) {
+	scheme, err := MLDSAScheme(securityLevel)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != scheme.SeedSize() {
+		keyPair := &WorkingMLDSAKeyPair{SecurityLevel: securityLevel}
+		return nil, newError("derive key pair", keyPair.algorithm(), ErrMalformedKey, "seed is %d bytes, expected %d", len(seed), scheme.SeedSize())
+	}
+
+	publicKey, privateKey := scheme.DeriveKey(seed)
+	return &WorkingMLDSAKeyPair{
+		SecurityLevel: securityLevel,
+		PrivateKey:    privateKey,
+		PublicKey:     publicKey,
+		Scheme:        scheme,
+	}, nil
+}
+
+// NewWorkingMLDSAKeyPairFromPrivateKey decodes an expanded FIPS 204 private
+// key and derives its public key, rejecting a key that fails the pairwise
+// consistency test
+func NewWorkingMLDSAKeyPairFromPrivateKey(securityLevel int, privateKeyBytes []byte) (*WorkingMLDSAKeyPair, error) {
+	scheme, err := MLDSAScheme(securityLevel)
+	if err != nil {
+		return nil, err
+	}
+	keyPair := &WorkingMLDSAKeyPair{SecurityLevel: securityLevel, Scheme: scheme}
+	if len(privateKeyBytes) != scheme.PrivateKeySize() {
+		return nil, newError("import private key", keyPair.algorithm(), ErrMalformedKey, "private key is %d bytes, expected %d", len(privateKeyBytes), scheme.PrivateKeySize())
+	}
+	privateKey, err := scheme.UnmarshalBinaryPrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, newError("import private key", keyPair.algorithm(), ErrMalformedKey, "%v", err)
+	}
+	publicKey, ok := privateKey.Public().(sign.PublicKey)
+	if !ok {
+		return nil, newError("import private key", keyPair.algorithm(), ErrMalformedKey, "private key has no public key")
+	}
+	keyPair.PrivateKey, keyPair.PublicKey = privateKey, publicKey
+	if !keyPair.consistent() {
+		return nil, newError("import private key", keyPair.algorithm(), ErrMalformedKey, "private key does not match its public key")
+	}
+	return keyPair, nil
+}
+
+// NewWorkingMLDSAKeyPairFromPublicKey decodes a FIPS 204 public key into a
+// key pair that can only verify
+func NewWorkingMLDSAKeyPairFromPublicKey(securityLevel int, publicKeyBytes []byte) (*WorkingMLDSAKeyPair, error) {
+	scheme, err := MLDSAScheme(securityLevel)
+	if err != nil {
+		return nil, err
+	}
+	keyPair := &WorkingMLDSAKeyPair{SecurityLevel: securityLevel, Scheme: scheme}
+	if len(publicKeyBytes) != scheme.PublicKeySize() {
+		return nil, newError("import public key", keyPair.algorithm(), ErrMalformedKey, "public key is %d bytes, expected %d", len(publicKeyBytes), scheme.PublicKeySize())
+	}
+	keyPair.PublicKey, err = scheme.UnmarshalBinaryPublicKey(publicKeyBytes)
+	if err != nil {
+		return nil, newError("import public key", keyPair.algorithm(), ErrMalformedKey, "%v", err)
+	}
+	return keyPair, nil
+}
+
+// Sign signs a message using the real ML-DSA implementation with an empty context.
+// Signatures are deterministic unless Hedged is set.
+func (k *WorkingMLDSAKeyPair) Sign(message []byte) ([]byte, error) {
+	return k.SignWithContext(message, nil)
 }
 
-// Verify verifies a signature using the real ML-DSA implementation
+// SignWithContext signs a message bound to a FIPS 204 context string of at
+// most MaxContextStringSize bytes
+func (k *WorkingMLDSAKeyPair) SignWithContext(message, contextString []byte) ([]byte, error) {
+	if len(contextString) > MaxContextStringSize {
+		return nil, newError("sign", k.algorithm(), ErrInvalidContextString, "context string is %d bytes, at most %d allowed", len(contextString), MaxContextStringSize)
+	}
+	if k.PrivateKey == nil {
+		return nil, newError("sign", k.algorithm(), ErrMissingKey, "private key has been destroyed")
+	}
+	if !k.Hedged {
+		// Use real FIPS 204 signing from CIRCL library
+		opts := &sign.SignatureOpts{Context: string(contextString)}
+		return k.Scheme.Sign(k.PrivateKey, message, opts), nil
+	}
+
+	// The scheme interface only signs deterministically, so hedged signing
+	// goes through the parameter set's own package
+	signature := make([]byte, k.Scheme.SignatureSize())
+	var err error
+	switch sk := k.PrivateKey.(type) {
+	case *mldsa44.PrivateKey:
+		err = mldsa44.SignTo(sk, message, contextString, true, signature)
+	case *mldsa65.PrivateKey:
+		err = mldsa65.SignTo(sk, message, contextString, true, signature)
+	case *mldsa87.PrivateKey:
+		err = mldsa87.SignTo(sk, message, contextString, true, signature)
+	default:
+		err = fmt.Errorf("unsupported ML-DSA private key type %T", sk)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hedged ML-DSA signing failed: %v", err)
+	}
+	return signature, nil
+}
+
+// consistent signs and verifies a fixed message deterministically, as the
+// pairwise consistency test of FIPS 140-3 does. An expanded private key also
+// holds tr, the hash of its public key, and t0, so a key whose bytes were
+// altered can still decode yet make signatures that never verify.
+func (k *WorkingMLDSAKeyPair) consistent() bool {
+	message := []byte("ML-DSA pairwise consistency test")
+	signature := k.Scheme.Sign(k.PrivateKey, message, nil)
+	return k.Scheme.Verify(k.PublicKey, message, signature, nil)
+}
+
+// verifyOnly returns a key pair holding only the public key, decoded afresh:
+// circl's public key shares the expanded matrix A with the private key it
+// came from, so one taken from the private key would verify wrongly once
+// Destroy overwrites it
+func (k *WorkingMLDSAKeyPair) verifyOnly() (*WorkingMLDSAKeyPair, error) {
+	encoded, err := k.PublicKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := k.Scheme.UnmarshalBinaryPublicKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkingMLDSAKeyPair{SecurityLevel: k.SecurityLevel, PublicKey: publicKey, Scheme: k.Scheme}, nil
+}
+
+// Verify verifies a signature using the real ML-DSA implementation with an empty context
 func (k *WorkingMLDSAKeyPair) Verify(message, signature []byte) bool {
-	// Use real Dilithium verification from CIRCL library
-	return k.Mode.Verify(k.PublicKey, message, signature)
+	return k.VerifyWithContext(message, signature, nil)
+}
+
+// VerifyWithContext verifies a signature made with the given FIPS 204 context
+// string; a signature made under any other context does not verify
+func (k *WorkingMLDSAKeyPair) VerifyWithContext(message, signature, contextString []byte) bool {
+	// circl ignores bytes past the signature size, which would make the
+	// encoding malleable, so the length must match exactly
+	if len(signature) != k.Scheme.SignatureSize() || len(contextString) > MaxContextStringSize {
+		return false
+	}
+	// Use real FIPS 204 verification from CIRCL library
+	opts := &sign.SignatureOpts{Context: string(contextString)}
+	return k.Scheme.Verify(k.PublicKey, message, signature, opts)
 }
 
 // GetPublicKeyBytes returns the public key as bytes
 func (k *WorkingMLDSAKeyPair) GetPublicKeyBytes() []byte {
-	return k.PublicKey.Bytes()
+	data, _ := k.PublicKey.MarshalBinary()
+	return data
 }
 
-// GetPrivateKeyBytes returns the private key as bytes
+// GetPrivateKeyBytes returns the private key as bytes, or nil once destroyed
 func (k *WorkingMLDSAKeyPair) GetPrivateKeyBytes() []byte {
-	return k.PrivateKey.Bytes()
+	if k.PrivateKey == nil {
+		return nil
+	}
+	data, _ := k.PrivateKey.MarshalBinary()
+	return data
 }
 
 // GetSignatureSize returns the signature size for the security level
 func (k *WorkingMLDSAKeyPair) GetSignatureSize() int {
-	return k.Mode.SignatureSize()
+	return k.Scheme.SignatureSize()
 }
 
 // GetPublicKeySize returns the public key size for the security level
 func (k *WorkingMLDSAKeyPair) GetPublicKeySize() int {
-	return k.Mode.PublicKeySize()
+	return k.Scheme.PublicKeySize()
 }
 
 // GetPrivateKeySize returns the private key size for the security level
 func (k *WorkingMLDSAKeyPair) GetPrivateKeySize() int {
-	return k.Mode.PrivateKeySize()
+	return k.Scheme.PrivateKeySize()
+}
+
+// Destroy overwrites the private key in place and drops it, leaving a key pair
+// that can still verify but no longer sign. The MarshalBinary encodings of
+// GetPrivateKeyBytes are copies that the caller must clear itself.
+func (k *WorkingMLDSAKeyPair) Destroy() {
+	if k.PrivateKey == nil {
+		return
+	}
+	// The public key is decoded afresh before the matrix A it shares with the
+	// private key is overwritten
+	if k.PublicKey != nil {
+		if verifier, err := k.verifyOnly(); err == nil {
+			k.PublicKey = verifier.PublicKey
+		}
+	}
+	switch sk := k.PrivateKey.(type) {
+	case *mldsa44.PrivateKey:
+		*sk = mldsa44.PrivateKey{}
+	case *mldsa65.PrivateKey:
+		*sk = mldsa65.PrivateKey{}
+	case *mldsa87.PrivateKey:
+		*sk = mldsa87.PrivateKey{}
+	}
+	k.PrivateKey = nil
 }
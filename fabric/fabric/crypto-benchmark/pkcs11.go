@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto-benchmark/hsm"
+	"crypto-benchmark/msp"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// tokenConfig is the PKCS#11 token selected by the --pkcs11 flags, or none
+type tokenConfig struct {
+	token *hsm.Token
+}
+
+// openTokenConfig logs in to the token if a module is given
+func openTokenConfig(module string, slot int, label string) (tokenConfig, error) {
+	if module == "" {
+		return tokenConfig{}, nil
+	}
+	token, err := hsm.Open(hsm.Config{Module: module, Slot: slot, TokenLabel: label})
+	if err != nil {
+		return tokenConfig{}, err
+	}
+	return tokenConfig{token: token}, nil
+}
+
+// apply makes the MSPs of a run generate their keys on the token
+func (c tokenConfig) apply(opts msp.Options) msp.Options {
+	if c.token != nil {
+		opts.KeyProvider = c.token
+	}
+	return opts
+}
+
+// close logs out of the token
+func (c tokenConfig) close() {
+	if c.token != nil {
+		c.token.Close()
+	}
+}
+
+// runPKCS11 implements the pkcs11 command: integration checks of the PKCS#11
+// backend against a real token such as SoftHSMv2. It returns the process exit
+// code.
+func runPKCS11(args []string) int {
+	fs := flag.NewFlagSet("pkcs11", flag.ExitOnError)
+	module := fs.String("module", "", "PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so")
+	slot := fs.Int("slot", -1, "Slot ID (-1 for any slot)")
+	label := fs.String("token-label", "", "Token label (empty for any token)")
+	algorithmList := fs.String("algorithms", "ECDSA,ML-DSA-44,ML-DSA-65,ML-DSA-87", "Comma-separated algorithms to check; those the token lacks are skipped")
+	iterations := fs.Int("iterations", 20, "Benchmark iterations per algorithm, on the token and in software")
+	fs.Parse(args)
+
+	if *module == "" {
+		log.Fatalf("--module is required; the user PIN is read from $%s", hsm.PINEnv)
+	}
+	token, err := hsm.Open(hsm.Config{Module: *module, Slot: *slot, TokenLabel: *label})
+	if err != nil {
+		log.Fatalf("Failed to open token: %v", err)
+	}
+	defer token.Close()
+
+	fmt.Printf("PKCS#11 integration checks on %s\n\n", token.Name())
+	failed, checked := 0, 0
+	type comparison struct {
+		algorithm       msp.SignatureAlgorithm
+		token, software *msp.CryptoMetrics
+	}
+	var comparisons []comparison
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		if !token.Supports(algorithm) {
+			fmt.Printf("- %s skipped: the token does not list its mechanisms\n", algorithm)
+			continue
+		}
+		checked++
+
+		for _, check := range []struct {
+			name string
+			run  func(*hsm.Token, msp.SignatureAlgorithm) error
+		}{
+			{"session key signs through the MSP without extraction", checkSessionKey},
+			{"token key is stored, found by label and deleted", checkTokenKey},
+		} {
+			if err := check.run(token, algorithm); err != nil {
+				fmt.Printf("  ✗ %s: %s: %v\n", algorithm, check.name, err)
+				failed++
+				continue
+			}
+			fmt.Printf("  ✓ %s: %s\n", algorithm, check.name)
+		}
+
+		message := []byte("PKCS#11 benchmark message")
+		onToken, err := msp.NewEnhancedMSPWithOptions(context.Background(), algorithm, msp.Options{KeyProvider: token})
+		if err != nil {
+			log.Fatalf("Failed to create %s MSP on the token: %v", algorithm, err)
+		}
+		tokenMetrics, err := onToken.Benchmark(message, *iterations)
+		if err != nil {
+			fmt.Printf("  ✗ %s: benchmark on the token: %v\n", algorithm, err)
+			failed++
+			continue
+		}
+		inSoftware, err := msp.NewEnhancedMSP(algorithm)
+		if err != nil {
+			log.Fatalf("Failed to create %s MSP: %v", algorithm, err)
+		}
+		softwareMetrics, err := inSoftware.Benchmark(message, *iterations)
+		if err != nil {
+			log.Fatalf("Software benchmark failed for %s: %v", algorithm, err)
+		}
+		comparisons = append(comparisons, comparison{algorithm, tokenMetrics, softwareMetrics})
+	}
+
+	if len(comparisons) > 0 {
+		fmt.Printf("\n%-12s %-9s %12s %12s %12s\n", "Algorithm", "Keys", "Keygen (ms)", "Sign (ms)", "Verify (ms)")
+		for _, c := range comparisons {
+			for _, row := range []struct {
+				keys string
+				m    *msp.CryptoMetrics
+			}{{"token", c.token}, {"software", c.software}} {
+				fmt.Printf("%-12s %-9s %12.3f %12.3f %12.3f\n", c.algorithm, row.keys, row.m.KeygenTimeMs, row.m.SignTimeMs, row.m.VerifyTimeMs)
+			}
+		}
+		fmt.Println("Verification runs in software against the token's public key in both rows.")
+	}
+
+	if checked == 0 {
+		fmt.Println("\nNo algorithm could be checked on this token")
+		return 1
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d check(s) failed\n", failed)
+		return 1
+	}
+	fmt.Println("\n✓ All PKCS#11 checks passed")
+	return 0
+}
+
+// checkSessionKey signs through an MSP whose key the token generated, and
+// checks that the key cannot be extracted and that the signatures verify in
+// software against the public key alone
+func checkSessionKey(token *hsm.Token, algorithm msp.SignatureAlgorithm) error {
+	m, err := msp.NewEnhancedMSPWithOptions(context.Background(), algorithm, msp.Options{KeyProvider: token})
+	if err != nil {
+		return err
+	}
+	if _, err := m.GetPrivateKeyBytes(); !errors.Is(err, msp.ErrKeyNotExtractable) {
+		return fmt.Errorf("GetPrivateKeyBytes returned %v, expected ErrKeyNotExtractable", err)
+	}
+	if err := checkStoredKey(m, []byte("PKCS#11 session key")); err != nil {
+		return err
+	}
+	clone, err := m.Clone()
+	if err != nil {
+		return fmt.Errorf("clone failed: %v", err)
+	}
+	if err := checkStoredKey(clone, []byte("PKCS#11 cloned key")); err != nil {
+		return fmt.Errorf("clone: %v", err)
+	}
+
+	message := []byte("PKCS#11 tamper check")
+	signature, err := m.Sign(message)
+	if err != nil {
+		return err
+	}
+	publicKey, err := m.GetPublicKeyBytes()
+	if err != nil {
+		return err
+	}
+	verifier, err := msp.NewEnhancedMSPFromPublicKey(algorithm, publicKey)
+	if err != nil {
+		return err
+	}
+	if valid, _ := verifier.Verify(append(message, '!'), signature); valid {
+		return fmt.Errorf("signature verifies for a tampered message")
+	}
+	if _, err := m.SignWithContextString(context.Background(), message, []byte("fabric/proposal/v1")); err == nil {
+		return fmt.Errorf("signing with a context string succeeded")
+	}
+	return nil
+}
+
+// checkTokenKey generates a labelled key on the token, finds it again, signs
+// with it and deletes it
+func checkTokenKey(token *hsm.Token, algorithm msp.SignatureAlgorithm) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	label := fmt.Sprintf("crypto-benchmark-%s-%s", algorithm, hex.EncodeToString(suffix))
+
+	generated, err := token.GenerateTokenKey(algorithm, label)
+	if err != nil {
+		return err
+	}
+	defer token.DeleteKey(label)
+	if err := token.CheckNotExtractable(generated); err != nil {
+		return err
+	}
+
+	found, err := token.FindKey(algorithm, label)
+	if err != nil {
+		return err
+	}
+	if string(found.PublicKey()) != string(generated.PublicKey()) {
+		return fmt.Errorf("key found by label has a different public key")
+	}
+	m, err := msp.NewEnhancedMSPFromKeySigner(algorithm, found, msp.Options{})
+	if err != nil {
+		return err
+	}
+	if err := checkStoredKey(m, []byte("PKCS#11 token key")); err != nil {
+		return err
+	}
+
+	if err := token.DeleteKey(label); err != nil {
+		return err
+	}
+	if _, err := token.FindKey(algorithm, label); err == nil {
+		return fmt.Errorf("key still found after deletion")
+	}
+	return nil
+}
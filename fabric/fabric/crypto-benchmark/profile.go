@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+)
+
+// phaseProfiler captures profiles around each algorithm's benchmark phase. Files
+// share the results file's name so a regression can be traced to the exact run:
+//
+//	crypto_benchmark_<timestamp>_<algorithm>.cpu.pprof
+//	crypto_benchmark_<timestamp>_<algorithm>.heap.pprof
+//	crypto_benchmark_<timestamp>_<algorithm>.heap-start.pprof
+//	crypto_benchmark_<timestamp>_<algorithm>.trace
+type phaseProfiler struct {
+	prefix string // Results file path without its extension
+	cpu    bool
+	heap   bool
+	trace  bool
+}
+
+// enabled reports whether any profile is requested
+func (p phaseProfiler) enabled() bool {
+	return p.cpu || p.heap || p.trace
+}
+
+// filename returns the path of one profile for an algorithm
+func (p phaseProfiler) filename(algorithm, suffix string) string {
+	return fmt.Sprintf("%s_%s.%s", p.prefix, algorithm, suffix)
+}
+
+// start begins profiling an algorithm's phase. The returned function stops every
+// profile and returns the files that were written.
+func (p phaseProfiler) start(algorithm string) (func() ([]string, error), error) {
+	var files []string
+	var cpuFile, traceFile *os.File
+
+	// The heap profile is cumulative for the process, so a snapshot taken before
+	// the phase lets `go tool pprof -base` isolate the phase's allocations
+	if p.heap {
+		base := p.filename(algorithm, "heap-start.pprof")
+		if err := writeHeapProfile(base); err != nil {
+			return nil, err
+		}
+		files = append(files, base)
+	}
+
+	if p.cpu {
+		name := p.filename(algorithm, "cpu.pprof")
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %v", err)
+		}
+		cpuFile = f
+		files = append(files, name)
+	}
+
+	if p.trace {
+		name := p.filename(algorithm, "trace")
+		f, err := os.Create(name)
+		if err != nil {
+			stopCPUProfile(cpuFile)
+			return nil, fmt.Errorf("failed to create execution trace: %v", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stopCPUProfile(cpuFile)
+			return nil, fmt.Errorf("failed to start execution trace: %v", err)
+		}
+		traceFile = f
+		files = append(files, name)
+	}
+
+	stop := func() ([]string, error) {
+		var errs []string
+
+		if traceFile != nil {
+			trace.Stop()
+			if err := traceFile.Close(); err != nil {
+				errs = append(errs, fmt.Sprintf("execution trace: %v", err))
+			}
+		}
+		if err := stopCPUProfile(cpuFile); err != nil {
+			errs = append(errs, fmt.Sprintf("CPU profile: %v", err))
+		}
+		if p.heap {
+			name := p.filename(algorithm, "heap.pprof")
+			if err := writeHeapProfile(name); err != nil {
+				errs = append(errs, err.Error())
+			}
+			files = append(files, name)
+		}
+
+		if len(errs) > 0 {
+			return files, fmt.Errorf("failed to write profiles for %s: %s", algorithm, strings.Join(errs, "; "))
+		}
+		return files, nil
+	}
+	return stop, nil
+}
+
+// run profiles fn as an algorithm's phase, if any profile is requested. The
+// profiles are stopped even when fn fails, so that the files of a failed run
+// are complete and can show why it failed.
+func (p phaseProfiler) run(algorithm string, fn func() error) ([]string, error) {
+	if !p.enabled() {
+		return nil, fn()
+	}
+	stop, err := p.start(algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start profiling: %v", err)
+	}
+	fnErr := fn()
+	files, err := stop()
+	if fnErr != nil {
+		return files, fnErr
+	}
+	return files, err
+}
+
+// stopCPUProfile stops a running CPU profile and closes its file, if any
+func stopCPUProfile(f *os.File) error {
+	if f == nil {
+		return nil
+	}
+	pprof.StopCPUProfile()
+	return f.Close()
+}
+
+// writeHeapProfile writes a heap profile after a GC so that live data is current
+func writeHeapProfile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create heap profile: %v", err)
+	}
+	defer f.Close()
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write heap profile: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto-benchmark/metrics"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// runReport implements the report command and returns the process exit code
+func runReport(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	output := fs.String("out", "results/report.html", "HTML report file to write")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: benchmark report [flags] <results.json> [results.json...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var inputs []metrics.ReportInput
+	for _, filename := range fs.Args() {
+		result, err := metrics.LoadResults(filename)
+		if err != nil {
+			log.Fatalf("Failed to load results: %v", err)
+		}
+		inputs = append(inputs, metrics.ReportInput{Name: filepath.Base(filename), Result: *result})
+	}
+
+	if err := metrics.SaveHTMLReport(*output, inputs); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	fmt.Printf("Report written to: %s\n", *output)
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto-benchmark/hbs"
+	"crypto-benchmark/msp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// stateConfig is the stateful key configuration selected by --state-dir and
+// --state-reserve
+type stateConfig struct {
+	dir     string
+	store   hbs.StateStore
+	reserve uint64
+}
+
+// openStateConfig opens the state directory, or keeps state in memory if dir is empty
+func openStateConfig(dir string, reserve uint64) (stateConfig, error) {
+	c := stateConfig{dir: dir, reserve: reserve}
+	if dir == "" {
+		c.store = hbs.NewMemoryStateStore()
+		return c, nil
+	}
+	store, err := hbs.OpenFileStateStore(dir)
+	if err != nil {
+		return c, err
+	}
+	c.store = store
+	return c, nil
+}
+
+// apply adds the state store to the MSP options of a run
+func (c stateConfig) apply(opts msp.Options) msp.Options {
+	opts.StateStore = c.store
+	opts.StateReserve = c.reserve
+	return opts
+}
+
+// runStateful implements the stateful command and returns the process exit code
+func runStateful(args []string) int {
+	fs := flag.NewFlagSet("stateful", flag.ExitOnError)
+	algorithmList := fs.String("algorithms", "LMS-HSS,XMSS-MT", "Comma-separated stateful algorithms")
+	signatures := fs.Int("signatures", 256, "Signatures per algorithm and store")
+	reserveList := fs.String("reserves", "1,16,256", "Comma-separated indices reserved per file state update")
+	stateDir := fs.String("state-dir", "", "Directory for the file state stores (default a temporary directory, removed afterwards)")
+	jsonOut := fs.String("json", "", "Also write the results as JSON to this file")
+	fs.Parse(args)
+
+	if *signatures < 1 {
+		log.Fatalf("--signatures must be at least 1")
+	}
+	var reserves []uint64
+	for _, s := range splitList(*reserveList) {
+		reserve, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || reserve < 1 {
+			log.Fatalf("Invalid reservation size: %s", s)
+		}
+		reserves = append(reserves, reserve)
+	}
+
+	dir := *stateDir
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "crypto-benchmark-state-")
+		if err != nil {
+			log.Fatalf("Failed to create state directory: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	}
+
+	fmt.Printf("Stateful signature state update study: %d signatures per store, state in %s\n", *signatures, dir)
+
+	var results []hbs.StateMetrics
+	for _, name := range splitList(*algorithmList) {
+		algorithm, err := msp.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatalf("Invalid algorithm list: %v", err)
+		}
+		if !algorithm.IsStateful() {
+			log.Fatalf("%s is not a stateful algorithm", name)
+		}
+		scheme := algorithm.StatefulScheme()
+
+		// The in-memory store is the floor: signing with no durable update
+		metrics, err := hbs.BenchmarkState(scheme, hbs.NewMemoryStateStore(), "memory", 1, *signatures)
+		if err != nil {
+			log.Fatalf("State study failed for %s: %v", name, err)
+		}
+		results = append(results, *metrics)
+
+		for _, reserve := range reserves {
+			store, err := hbs.OpenFileStateStore(filepath.Join(dir, fmt.Sprintf("%s-reserve-%d", algorithm, reserve)))
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			metrics, err := hbs.BenchmarkState(scheme, store, "file", reserve, *signatures)
+			if err != nil {
+				log.Fatalf("State study failed for %s: %v", name, err)
+			}
+			results = append(results, *metrics)
+		}
+	}
+
+	fmt.Printf("\n%-44s %-7s %7s %8s %10s %12s %12s %7s %8s\n",
+		"Scheme", "Store", "Reserve", "Updates", "Sign (ms)", "Update (ms)", "Per sig (ms)", "Share", "Skipped")
+	for _, m := range results {
+		fmt.Printf("%-44s %-7s %7d %8d %10.3f %12.3f %12.4f %6.1f%% %8d\n",
+			m.Scheme, m.Store, m.Reserve, m.StateUpdates, m.SignTimeMs, m.StateUpdateTimeMs,
+			m.StateUpdatePerSigMs, m.StateUpdateSharePct, m.SkippedOnRestart)
+	}
+	fmt.Println("\nSkipped indices are reserved but unused when the signer restarts; they are lost, never reused.")
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+		if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+			log.Fatalf("Failed to write results: %v", err)
+		}
+		fmt.Printf("Results saved to: %s\n", *jsonOut)
+	}
+	return 0
+}
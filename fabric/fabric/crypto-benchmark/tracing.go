@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto-benchmark/msp"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newInMemoryTracerProvider returns a synchronous provider whose spans are kept
+// in the returned exporter, for inspecting instrumentation without a collector.
+// It lives here rather than in msp so that the package only depends on the
+// OpenTelemetry API, not the SDK.
+func newInMemoryTracerProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return provider, exporter
+}
+
+// validateTracing checks that key generation, signing and verification each emit
+// one span with the expected attributes, using the in-process exporter
+func validateTracing(algorithm msp.SignatureAlgorithm) error {
+	provider, exporter := newInMemoryTracerProvider()
+	defer provider.Shutdown(context.Background())
+
+	ctx := context.Background()
+	mspInstance, err := msp.NewEnhancedMSPContext(ctx, algorithm, provider)
+	if err != nil {
+		return fmt.Errorf("traced MSP creation failed: %v", err)
+	}
+	defer mspInstance.Close()
+
+	message := []byte("Tracing validation message")
+	signature, err := mspInstance.SignContext(ctx, message)
+	if err != nil {
+		return fmt.Errorf("traced signing failed: %v", err)
+	}
+	if _, err := mspInstance.VerifyContext(ctx, message, signature); err != nil {
+		return fmt.Errorf("traced verification failed: %v", err)
+	}
+
+	expected := []struct {
+		name  string
+		attrs map[attribute.Key]attribute.Value
+	}{
+		{msp.SpanKeyGen, map[attribute.Key]attribute.Value{
+			msp.AttrAlgorithm: attribute.StringValue(algorithm.String()),
+			msp.AttrResult:    attribute.StringValue("ok"),
+		}},
+		{msp.SpanSign, map[attribute.Key]attribute.Value{
+			msp.AttrAlgorithm:     attribute.StringValue(algorithm.String()),
+			msp.AttrMessageSize:   attribute.IntValue(len(message)),
+			msp.AttrSignatureSize: attribute.IntValue(len(signature)),
+			msp.AttrResult:        attribute.StringValue("ok"),
+		}},
+		{msp.SpanVerify, map[attribute.Key]attribute.Value{
+			msp.AttrAlgorithm:     attribute.StringValue(algorithm.String()),
+			msp.AttrMessageSize:   attribute.IntValue(len(message)),
+			msp.AttrSignatureSize: attribute.IntValue(len(signature)),
+			msp.AttrResult:        attribute.StringValue("valid"),
+		}},
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != len(expected) {
+		return fmt.Errorf("expected %d spans, got %d", len(expected), len(spans))
+	}
+
+	for i, want := range expected {
+		span := spans[i]
+		if span.Name != want.name {
+			return fmt.Errorf("span %d is %q, expected %q", i, span.Name, want.name)
+		}
+
+		got := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes {
+			got[kv.Key] = kv.Value
+		}
+		for key, value := range want.attrs {
+			if got[key] != value {
+				return fmt.Errorf("span %s attribute %s is %q, expected %q", span.Name, key, got[key].Emit(), value.Emit())
+			}
+		}
+	}
+
+	return nil
+}